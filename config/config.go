@@ -17,6 +17,14 @@ type BaseConf struct {
 	LogPath string `yaml:"log_path"`    // log file path
 	Passwd  string `yaml:"requirepass"` // password of redis
 	Slaveof string `yaml:"slaveof"`     // slave of other redis
+	// Count of logical databases
+	Databases int `yaml:"databases"`
+	// Allow DEBUG command, used by tests
+	EnableDebug bool `yaml:"enable-debug-command"`
+	// Use a clock that only moves by DEBUG ADVANCE-TIME instead of real time
+	FakeClock bool `yaml:"fake-clock"`
+	// Classes of keyspace events published, letters of redis, empty to disable
+	NotifyKeyspaceEvents string `yaml:"notify-keyspace-events"`
 }
 
 var baseConf *BaseConf
@@ -61,6 +69,29 @@ func GetPasswd() string {
 	return baseConf.Passwd
 }
 
+// GetDatabases : Get count of logical databases, 16 if not configured
+func GetDatabases() int {
+	if baseConf.Databases <= 0 {
+		return 16
+	}
+	return baseConf.Databases
+}
+
+// GetEnableDebug : Whether DEBUG command is allowed
+func GetEnableDebug() bool {
+	return baseConf.EnableDebug
+}
+
+// GetFakeClock : Whether server uses fake clock
+func GetFakeClock() bool {
+	return baseConf.FakeClock
+}
+
+// GetNotifyKeyspaceEvents : Get classes of keyspace events published
+func GetNotifyKeyspaceEvents() string {
+	return baseConf.NotifyKeyspaceEvents
+}
+
 // GetSlave : Get slave of ip:port config
 func GetSlave() string {
 	return baseConf.Slaveof
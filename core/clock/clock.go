@@ -0,0 +1,86 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock : Source of time of server, keyspace and expiration
+type Clock interface {
+	Now() time.Time
+}
+
+// Advancer : Clock that can be moved forward manually
+type Advancer interface {
+	Clock
+	// Advance : Move clock forward
+	Advance(duration time.Duration)
+	// Forward : Move clock forward without calling listeners, for callers
+	// running the jobs of listeners by themselves
+	Forward(duration time.Duration)
+	// OnAdvance : Register function called every time clock is advanced
+	OnAdvance(listener func())
+}
+
+// UnixMs : Current unix time of clock in milliseconds
+func UnixMs(clock Clock) int64 {
+	return clock.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// SystemClock : Clock of real time
+type SystemClock struct{}
+
+// NewSystemClock : Create clock of real time
+func NewSystemClock() *SystemClock {
+	return &SystemClock{}
+}
+
+// Now : Current real time
+func (c *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock : Clock that only moves when advanced, for deterministic tests
+type FakeClock struct {
+	mutex     sync.RWMutex
+	now       time.Time
+	listeners []func()
+}
+
+// NewFakeClock : Create fake clock starting at time start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now : Current time of fake clock
+func (c *FakeClock) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.now
+}
+
+// Advance : Move clock forward, listeners are called after time is updated
+func (c *FakeClock) Advance(duration time.Duration) {
+	c.Forward(duration)
+	c.mutex.RLock()
+	listeners := c.listeners
+	c.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// Forward : Move clock forward without calling listeners
+func (c *FakeClock) Forward(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(duration)
+}
+
+// OnAdvance : Register function called every time clock is advanced
+func (c *FakeClock) OnAdvance(listener func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
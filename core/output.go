@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+// OUTPUT_QUEUE_LIMIT : Max bytes queued for writer goroutine of connection,
+// client is disconnected if pushed responses exceed it, and replies of next
+// command wait until queue drains below it
+const OUTPUT_QUEUE_LIMIT = 32 * 1024 * 1024
+
+// Errors of output queue
+var (
+	ErrOutputClosed   = errors.New("Output of connection is closed")
+	ErrOutputOverflow = errors.New("Output of connection exceeds limit")
+)
+
+// outputQueue : Bytes waiting to be written to connection by its writer
+// goroutine, so goroutines producing replies and pushed responses never
+// write to socket themselves
+type outputQueue struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	limit  int
+	closed bool
+}
+
+// newOutputQueue : Create a new output queue holding about limit bytes
+func newOutputQueue(limit int) *outputQueue {
+	queue := &outputQueue{limit: limit}
+	queue.cond = sync.NewCond(&queue.mutex)
+	return queue
+}
+
+// Write : Append data whatever the limit is, used by buffered writer of
+// replies whose producer waits by Wait instead
+func (queue *outputQueue) Write(data []byte) (int, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.closed {
+		return 0, ErrOutputClosed
+	}
+	queue.buf = append(queue.buf, data...)
+	queue.cond.Broadcast()
+	return len(data), nil
+}
+
+// Push : Append data without waiting, ErrOutputOverflow if it exceeds limit
+func (queue *outputQueue) Push(data []byte) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.closed {
+		return ErrOutputClosed
+	}
+	if len(queue.buf)+len(data) > queue.limit {
+		return ErrOutputOverflow
+	}
+	queue.buf = append(queue.buf, data...)
+	queue.cond.Broadcast()
+	return nil
+}
+
+// Wait : Wait until bytes queued are below limit or queue is closed
+func (queue *outputQueue) Wait() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for !queue.closed && (len(queue.buf) >= queue.limit) {
+		queue.cond.Wait()
+	}
+}
+
+// Take : Wait for bytes queued and take all of them, nil once queue is
+// closed and all bytes are taken
+func (queue *outputQueue) Take() []byte {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for !queue.closed && (0 == len(queue.buf)) {
+		queue.cond.Wait()
+	}
+	data := queue.buf
+	queue.buf = nil
+	queue.cond.Broadcast()
+	return data
+}
+
+// Close : Close queue, bytes queued already are still taken by Take
+func (queue *outputQueue) Close() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.closed = true
+	queue.cond.Broadcast()
+}
+
+// Abort : Close queue and drop bytes queued, when connection is broken
+func (queue *outputQueue) Abort() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.closed = true
+	queue.buf = nil
+	queue.cond.Broadcast()
+}
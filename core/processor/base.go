@@ -2,149 +2,242 @@ package processor
 
 import (
 	"errors"
+	"fmt"
+	"gredissimulate/core/clock"
 	"gredissimulate/core/proto"
-	"reflect"
+	"gredissimulate/core/store"
+	"gredissimulate/logger"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
+// SERVER_VERSION : Redis version reported to clients
+const SERVER_VERSION = "7.0.0"
+
+// Error replies shared by commands
+const (
+	ERR_SYNTAX          = "ERR syntax error"
+	ERR_NOT_INTEGER     = "ERR value is not an integer or out of range"
+	ERR_NOT_FLOAT       = "ERR value is not a valid float"
+	ERR_STRING_TOO_LONG = "ERR string exceeds maximum allowed size (proto-max-bulk-len)"
+)
+
+// ProcConf : Dependencies injected to processor
+type ProcConf struct {
+	Passwd      string           // Password of server, empty if auth is not required
+	Databases   *store.Databases // Logical databases shared by all connections of server
+	Clock       clock.Clock      // Clock of server, real time is used if nil
+	EnableDebug bool             // Whether DEBUG command is allowed
+}
+
 // Create : construct function define
-type Create func(string) Processor
+type Create func(ProcConf) Processor
 
 // Processor : Processor interface
 type Processor interface {
-	PING(*proto.Request) (*proto.Response, error)
-	AUTH(*proto.Request) (*proto.Response, error)
-	MULTI(*proto.Request) (*proto.Response, error)
-	EXEC(*proto.Request) (*proto.Response, error)
-	IsMulti() bool
-	SetMulti(bool)
-	GetReqQue() []*proto.Request
-	AppendReq(*proto.Request)
-}
-
-// ProcessReq : Process request
-func ProcessReq(proc Processor, req *proto.Request) (res *proto.Response, err error) {
-	cmd := req.Cmd
-
-	v := reflect.ValueOf(proc)
-	method := v.MethodByName(cmd)
-	if method.IsValid() {
-		if !proc.IsMulti() {
-			if "EXEC" != cmd {
-				result := method.Call([]reflect.Value{reflect.ValueOf(req)})
-				if !result[0].IsNil() {
-					res = result[0].Interface().(*proto.Response)
-				} else {
-					res = proto.NewErrorRes("Process `" + cmd + "` error")
-				}
-
-				if !result[1].IsNil() {
-					err = result[1].Interface().(error)
-				}
-			} else {
-				res, _ = proc.EXEC(req)
-			}
-		} else {
-			if "EXEC" != cmd {
-				if "MULTI" != cmd {
-					proc.AppendReq(req)
-					res = proto.NewResponse(proto.RES_TYPE_STATE)
-					res.SetString("QUEUE")
-				} else {
-					res, _ = proc.MULTI(req)
-				}
-			} else {
-				res, _ = execMulti(proc)
-			}
+	PING(*Session, *proto.Request) (*proto.Response, error)
+	AUTH(*Session, *proto.Request) (*proto.Response, error)
+	MULTI(*Session, *proto.Request) (*proto.Response, error)
+	EXEC(*Session, *proto.Request) (*proto.Response, error)
+	HELLO(*Session, *proto.Request) (*proto.Response, error)
+}
+
+// ProcessReq : Process request, handler is found in command table of
+// processor and is called only if arguments match metadata of command.
+// Commands other than those of transaction are queued by MULTI
+func ProcessReq(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	command := GetCommandTable(proc).Lookup(req)
+	if res = checkCommand(command, sess, req); nil != res {
+		if sess.IsMulti() {
+			// Transaction with a rejected command is discarded by EXEC
+			sess.execAbort = true
 		}
-	} else {
-		res = proto.NewErrorRes("Unknow command")
+		return
 	}
 
+	if "EXEC" == req.Cmd {
+		return execMulti(proc, sess)
+	}
+	if sess.IsMulti() && !isTransactionCmd(command) {
+		sess.AppendReq(req)
+		res = newStatusRes("QUEUED")
+		return
+	}
+
+	if databases := getDatabases(proc); nil != databases {
+		// Commands of other connections wait while a transaction is running
+		databases.RLock()
+		defer databases.RUnlock()
+	}
+	res, err = callCommand(command, proc, sess, req)
+	if nil == res {
+		res = proto.NewErrorRes("Process `" + req.Cmd + "` error")
+	}
 	return
 }
 
-func execMulti(proc Processor) (res *proto.Response, err error) {
-	if proc.IsMulti() {
-		proc.SetMulti(false)
-		res = proto.NewResponse(proto.RES_TYPE_MULTI)
-		for _, request := range proc.GetReqQue() {
-			cmd := request.Cmd
-
-			v := reflect.ValueOf(proc)
-			method := v.MethodByName(cmd)
-			result := method.Call([]reflect.Value{reflect.ValueOf(request)})
-			if !result[0].IsNil() {
-				res.SetResponse(result[0].Interface().(*proto.Response))
-			} else {
-				res.SetResponse(proto.NewErrorRes("Process `" + cmd + "` error"))
-			}
-		}
-	} else {
-		res = proto.NewErrorRes("EXEC without MULTI")
+// checkCommand : Check whether request can be processed, return error
+// response if client has not authenticated, command is unknown, arguments
+// do not match metadata of command or command is not allowed for RESP2
+// connection having subscriptions
+func checkCommand(command *Command, sess *Session, req *proto.Request) *proto.Response {
+	if !sess.IsAuthenticated() && ((nil == command) || !command.HasFlag(CMD_NOAUTH)) {
+		return proto.NewErrorRes("NOAUTH Authentication required.")
 	}
-	return
+	if nil == command {
+		return newUnknownCmdRes(nil, req)
+	}
+	if (nil != command.SubCommands) && (len(req.Params) > 0) {
+		// Lookup returns the container itself if subcommand does not match
+		return newUnknownCmdRes(command, req)
+	}
+	if res := command.CheckArity(req); nil != res {
+		return res
+	}
+	if nil == command.Func {
+		return newUnknownCmdRes(nil, req)
+	}
+	if sess.IsSubscribed() && (proto.PROTO_RESP2 == sess.ProtoVer) && !isSubscribedModeCmd(command) {
+		return newSubscribedModeErrRes(command)
+	}
+	return nil
+}
+
+// recoveredPanics : Count of panics recovered from command handlers
+var recoveredPanics uint64
+
+// GetRecoveredPanics : Get count of panics recovered from command handlers
+func GetRecoveredPanics() uint64 {
+	return atomic.LoadUint64(&recoveredPanics)
+}
+
+// callCommand : Call handler of command, panic of handler is recovered and
+// replied as error so the connection stays alive
+func callCommand(command *Command, proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	defer func() {
+		if r := recover(); nil != r {
+			atomic.AddUint64(&recoveredPanics, 1)
+			logger.LogError("Panic in command", command.Name, ":", r, "\n"+string(debug.Stack()))
+			res = proto.NewErrorRes("ERR internal error")
+			err = fmt.Errorf("panic in command %s: %v", command.Name, r)
+		}
+	}()
+	return command.Func(proc, sess, req)
 }
 
 // BaseProc : Do nothing
 type BaseProc struct {
-	isMulti bool
-	passwd  string
-	reqQue  []*proto.Request
+	passwd      string
+	databases   *store.Databases
+	clock       clock.Clock
+	enableDebug bool
 }
 
-// IsCmdSupport : Whether cmd support by processor
-func (proc *BaseProc) IsCmdSupport(cmd string) bool {
-	v := reflect.ValueOf(proc)
-	method := v.MethodByName(cmd)
-	return method.IsValid()
+// NewBaseProc : Create base processor to be embedded by other processors
+func NewBaseProc(conf ProcConf) BaseProc {
+	if nil == conf.Clock {
+		conf.Clock = clock.NewSystemClock()
+	}
+	return BaseProc{
+		passwd:      conf.Passwd,
+		databases:   conf.Databases,
+		clock:       conf.Clock,
+		enableDebug: conf.EnableDebug,
+	}
+}
+
+// GetClock : Get clock injected to processor
+func (proc *BaseProc) GetClock() clock.Clock {
+	return proc.clock
 }
 
-// IsMulti : Is processor in multi processing
-func (proc *BaseProc) IsMulti() bool {
-	return proc.isMulti
+// nowMs : Current unix time of clock in milliseconds
+func (proc *BaseProc) nowMs() int64 {
+	return clock.UnixMs(proc.clock)
 }
 
-// GetReqQue : Get request que from processor
-func (proc *BaseProc) GetReqQue() []*proto.Request {
-	return proc.reqQue
+// GetDatabases : Get logical databases injected to processor
+func (proc *BaseProc) GetDatabases() *store.Databases {
+	return proc.databases
 }
 
-// SetMulti : Update isMulti flag
-func (proc *BaseProc) SetMulti(flag bool) {
-	proc.isMulti = flag
+// GetKeyspace : Get keyspace of database selected by session
+func (proc *BaseProc) GetKeyspace(sess *Session) store.Keyspace {
+	return proc.databases.Get(sess.DB)
 }
 
-// AppendReq : Push request to proc queue
-func (proc *BaseProc) AppendReq(req *proto.Request) {
-	proc.reqQue = append(proc.reqQue, req)
+// notifyKeyspaceEvent : Publish event of class on key of database selected
+// by session, class is one of store.NOTIFY_*
+func (proc *BaseProc) notifyKeyspaceEvent(sess *Session, class int, event string, key string) {
+	proc.databases.Notify(class, event, sess.DB, key)
+}
+
+// notifyChanged : Publish event of class on key, followed by del event if
+// the value is emptied and the key is deleted
+func (proc *BaseProc) notifyChanged(sess *Session, class int, event string, key string, deleted bool) {
+	proc.notifyKeyspaceEvent(sess, class, event, key)
+	if deleted {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "del", key)
+	}
+}
+
+// notifyStored : Publish event of class on destination key of a command
+// storing its result, or del event if empty result deleted existing key
+func (proc *BaseProc) notifyStored(sess *Session, class int, event string, key string, stored bool, existed bool) {
+	if stored {
+		proc.notifyKeyspaceEvent(sess, class, event, key)
+	} else if existed {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "del", key)
+	}
+}
+
+// IsCmdSupport : Whether cmd support by processor
+func (proc *BaseProc) IsCmdSupport(cmd string) bool {
+	return nil != GetCommandTable(proc).Get(cmd)
 }
 
 // GET : Empty processor get
-func (proc *BaseProc) GET(req *proto.Request) (res *proto.Response, err error) {
-	res = proto.NewResponse(proto.RES_TYPE_BULK)
+func (proc *BaseProc) GET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = proto.NewNullRes(proto.RES_TYPE_BULK)
 	return
 }
 
 // SET : Empty processor set
-func (proc *BaseProc) SET(req *proto.Request) (res *proto.Response, err error) {
+func (proc *BaseProc) SET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
 	res = proto.NewResponse(proto.RES_TYPE_STATE)
 	res.SetString("OK")
 	return
 }
 
-// PING : Empty processor ping
-func (proc *BaseProc) PING(req *proto.Request) (res *proto.Response, err error) {
+// PING : Empty processor ping, RESP2 connection having subscriptions is
+// replied by an array like messages
+func (proc *BaseProc) PING(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if sess.IsSubscribed() && (proto.PROTO_RESP2 == sess.ProtoVer) {
+		message := ""
+		if len(req.Params) > 0 {
+			message = req.Params[0]
+		}
+		res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, []string{"pong", message})
+		return
+	}
 	res = proto.NewResponse(proto.RES_TYPE_STATE)
 	res.SetString("PONG")
 	return
 }
 
 // AUTH : Empty processor auth
-func (proc *BaseProc) AUTH(req *proto.Request) (res *proto.Response, err error) {
+func (proc *BaseProc) AUTH(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	passwd := req.Params[len(req.Params)-1]
 	if "" == proc.passwd {
 		res = proto.NewErrorRes("ERR Client sent AUTH, but no password is set")
+	} else if (2 == len(req.Params)) && (DEFAULT_USER != req.Params[0]) {
+		res = proto.NewErrorRes("WRONGPASS invalid username-password pair or user is disabled.")
+		err = errors.New("WRONGPASS invalid username-password pair")
 	} else {
-		if proc.passwd == req.Params[0] {
+		if proc.passwd == passwd {
+			sess.User = DEFAULT_USER
 			res = proto.NewResponse(proto.RES_TYPE_STATE)
 			res.SetString("OK")
 		} else {
@@ -155,36 +248,98 @@ func (proc *BaseProc) AUTH(req *proto.Request) (res *proto.Response, err error)
 	return
 }
 
+// HELLO : Switch protocol version, optionally auth with `AUTH username password`
+func (proc *BaseProc) HELLO(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	protoVer := sess.ProtoVer
+	if len(req.Params) > 0 {
+		protoVer, err = strconv.Atoi(req.Params[0])
+		if nil != err {
+			res = proto.NewErrorRes("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if (proto.PROTO_RESP2 != protoVer) && (proto.PROTO_RESP3 != protoVer) {
+			res = proto.NewErrorRes("NOPROTO sorry, this protocol version is not supported.")
+			err = errors.New("NOPROTO unsupported protocol version")
+			return
+		}
+	}
+
+	user := sess.User
+	name := sess.Name
+	for i := 1; i < len(req.Params); i++ {
+		option := strings.ToUpper(req.Params[i])
+		if ("AUTH" == option) && (i+2 < len(req.Params)) {
+			username := req.Params[i+1]
+			passwd := req.Params[i+2]
+			if (DEFAULT_USER != username) || (("" != proc.passwd) && (proc.passwd != passwd)) {
+				res = proto.NewErrorRes("WRONGPASS invalid username-password pair or user is disabled.")
+				err = errors.New("WRONGPASS invalid username-password pair")
+				return
+			}
+			user = DEFAULT_USER
+			i = i + 2
+		} else if ("SETNAME" == option) && (i+1 < len(req.Params)) {
+			name = req.Params[i+1]
+			i = i + 1
+		} else {
+			res = proto.NewErrorRes("ERR Syntax error in HELLO option '" + req.Params[i] + "'")
+			err = errors.New("ERR Syntax error in HELLO")
+			return
+		}
+	}
+
+	if "" == user {
+		res = proto.NewErrorRes("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		err = errors.New("NOAUTH HELLO without authentication")
+		return
+	}
+
+	sess.User = user
+	sess.Name = name
+	sess.ProtoVer = protoVer
+	res = proto.NewResponse(proto.RES_TYPE_MAP)
+	res.SetPair(newBulkRes("server"), newBulkRes("redis"))
+	res.SetPair(newBulkRes("version"), newBulkRes(SERVER_VERSION))
+	res.SetPair(newBulkRes("proto"), newIntRes(protoVer))
+	res.SetPair(newBulkRes("id"), newIntRes(int(sess.ID)))
+	res.SetPair(newBulkRes("mode"), newBulkRes("standalone"))
+	res.SetPair(newBulkRes("role"), newBulkRes("master"))
+	res.SetPair(newBulkRes("modules"), proto.NewResponse(proto.RES_TYPE_MULTI))
+	return
+}
+
 // MULTI : Empty processor multi
-func (proc *BaseProc) MULTI(req *proto.Request) (res *proto.Response, err error) {
-	if !proc.isMulti {
-		proc.isMulti = true
+func (proc *BaseProc) MULTI(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if !sess.IsMulti() {
+		sess.SetMulti(true)
 		res = proto.NewResponse(proto.RES_TYPE_STATE)
 		res.SetString("OK")
 	} else {
-		res = proto.NewErrorRes("MULTI calls can not be nested")
+		res = proto.NewErrorRes("ERR MULTI calls can not be nested")
 	}
 	return
 }
 
-// EXEC : Empty processor auth
-func (proc *BaseProc) EXEC(req *proto.Request) (res *proto.Response, err error) {
-	if proc.isMulti {
-		proc.isMulti = false
-		res = proto.NewResponse(proto.RES_TYPE_MULTI)
-		for _, request := range proc.reqQue {
-			cmd := request.Cmd
-			v := reflect.ValueOf(proc)
-			method := v.MethodByName(cmd)
-			result := method.Call([]reflect.Value{reflect.ValueOf(request)})
-			if !result[0].IsNil() {
-				res.SetResponse(result[0].Interface().(*proto.Response))
-			} else {
-				res.SetResponse(proto.NewErrorRes("Process `" + cmd + "` error"))
-			}
-		}
-	} else {
-		res = proto.NewErrorRes("EXEC without MULTI")
-	}
-	return
+// EXEC : Empty processor exec, queued requests are executed by ProcessReq
+// through command table of the outer processor
+func (proc *BaseProc) EXEC(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	return execMulti(proc, sess)
+}
+
+func newBulkRes(content string) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_BULK)
+	res.SetString(content)
+	return res
+}
+
+func newStatusRes(content string) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_STATE)
+	res.SetString(content)
+	return res
+}
+
+func newIntRes(value int) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_INT)
+	res.SetInt(value)
+	return res
 }
@@ -0,0 +1,318 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Command flags
+const (
+	CMD_WRITE    = 1 << iota // Command may modify the keyspace
+	CMD_READONLY             // Command only reads from keyspace
+	CMD_ADMIN                // Administrative command
+	CMD_NOSCRIPT             // Command is not allowed in scripts
+	CMD_LOADING              // Command is allowed while loading the dataset
+	CMD_STALE                // Command is allowed when replica has stale data
+	CMD_FAST                 // Command runs in O(1) or O(log(N))
+	CMD_DENYOOM              // Command may increase memory usage
+	CMD_NOAUTH               // Command is allowed before authentication
+	CMD_BLOCKING             // Command may block the client
+)
+
+// Command groups, used by COMMAND DOCS and to derive ACL categories
+const (
+	GROUP_GENERIC      = "generic"
+	GROUP_STRING       = "string"
+	GROUP_LIST         = "list"
+	GROUP_SET          = "set"
+	GROUP_SORTED_SET   = "sorted-set"
+	GROUP_HASH         = "hash"
+	GROUP_PUBSUB       = "pubsub"
+	GROUP_TRANSACTIONS = "transactions"
+	GROUP_CONNECTION   = "connection"
+	GROUP_SERVER       = "server"
+	GROUP_STREAM       = "stream"
+)
+
+// CommandFunc : Handler of command
+type CommandFunc func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error)
+
+// Command : Metadata and handler of command
+type Command struct {
+	Name        string      // Lower case name, subcommand is named as `container|sub`
+	Func        CommandFunc // Handler, nil for container command
+	Arity       int         // Exact argument count including command name if positive, minimum count if negative
+	Flags       int         // Combination of CMD_* flags
+	FirstKey    int         // Position of first key in arguments, 0 if no key
+	LastKey     int         // Position of last key, negative is counted from the end
+	KeyStep     int         // Step between keys
+	KeysFunc    KeysFunc    // Extract keys of command whose key positions depend on arguments, optional
+	CheckArgs   ArgsChecker // Extra check of argument count besides arity, optional
+	Group       string      // One of GROUP_*
+	Summary     string      // Short description shown by COMMAND DOCS
+	Since       string      // Redis version the command is introduced
+	SubCommands map[string]*Command
+}
+
+// KeysFunc : Extract keys from params of request
+type KeysFunc func(params []string) []string
+
+// numKeysAt : Params has numkeys at position index followed by numkeys keys
+func numKeysAt(index int) KeysFunc {
+	return func(params []string) []string {
+		if index >= len(params) {
+			return []string{}
+		}
+		count, err := strconv.Atoi(params[index])
+		if (nil != err) || (count < 0) || (index+1+count > len(params)) {
+			return []string{}
+		}
+		return params[index+1 : index+1+count]
+	}
+}
+
+// destAndNumKeysAt : Params start with a destination key and have numkeys
+// at position index followed by numkeys keys
+func destAndNumKeysAt(index int) KeysFunc {
+	return func(params []string) []string {
+		if 0 == len(params) {
+			return []string{}
+		}
+		return append([]string{params[0]}, numKeysAt(index)(params)...)
+	}
+}
+
+// streamsKeys : Params have keys after STREAMS option followed by the same
+// count of IDs, etc: XREAD COUNT 2 STREAMS key1 key2 id1 id2
+func streamsKeys(params []string) []string {
+	for i, param := range params {
+		if "STREAMS" == strings.ToUpper(param) {
+			rest := params[i+1:]
+			return rest[:len(rest)/2]
+		}
+	}
+	return []string{}
+}
+
+// ArgsChecker : Check params of request, return false if the number of
+// arguments is wrong
+type ArgsChecker func(params []string) bool
+
+// pairsFrom : Params from position start on must be pairs, etc: field value
+func pairsFrom(start int) ArgsChecker {
+	return func(params []string) bool {
+		return (len(params) >= start) && (0 == (len(params)-start)%2)
+	}
+}
+
+// maxArgs : At most count params
+func maxArgs(count int) ArgsChecker {
+	return func(params []string) bool {
+		return len(params) <= count
+	}
+}
+
+// CheckArity : Check argument count of request, return error response if
+// the count does not match metadata of command
+func (cmd *Command) CheckArity(req *proto.Request) *proto.Response {
+	argc := len(req.Params) + 1
+	if ((cmd.Arity > 0) && (argc != cmd.Arity)) || (argc < -cmd.Arity) ||
+		((nil != cmd.CheckArgs) && !cmd.CheckArgs(req.Params)) {
+		return proto.NewErrorRes("ERR wrong number of arguments for '" + cmd.Name + "' command")
+	}
+	return nil
+}
+
+// newUnknownCmdRes : Error response of unknown command or subcommand
+func newUnknownCmdRes(cmd *Command, req *proto.Request) *proto.Response {
+	if nil != cmd {
+		return proto.NewErrorRes("ERR unknown subcommand '" + req.Params[0] + "'. Try " + req.Cmd + " HELP.")
+	}
+
+	content := "ERR unknown command '" + req.Cmd + "', with args beginning with: "
+	for _, param := range req.Params {
+		content = content + "'" + param + "' "
+	}
+	return proto.NewErrorRes(content)
+}
+
+// HasFlag : Whether command has flag
+func (cmd *Command) HasFlag(flag int) bool {
+	return 0 != (cmd.Flags & flag)
+}
+
+// CommandTable : Commands registered by a processor
+type CommandTable struct {
+	commands map[string]*Command
+}
+
+// CommandProvider : Processor that registers its commands to a table, other
+// processors are adapted by exported methods through reflection
+type CommandProvider interface {
+	Commands() *CommandTable
+}
+
+// NewCommandTable : Create a new empty command table
+func NewCommandTable() *CommandTable {
+	return &CommandTable{commands: make(map[string]*Command)}
+}
+
+// Register : Register commands to table, subcommand `container|sub` creates
+// the container command if it has not been registered
+func (table *CommandTable) Register(cmds ...*Command) {
+	for _, cmd := range cmds {
+		cmd.Name = strings.ToLower(cmd.Name)
+		names := strings.SplitN(cmd.Name, "|", 2)
+		if 1 == len(names) {
+			if old, ok := table.commands[strings.ToUpper(cmd.Name)]; ok && (nil == cmd.SubCommands) {
+				cmd.SubCommands = old.SubCommands
+			}
+			table.commands[strings.ToUpper(cmd.Name)] = cmd
+			continue
+		}
+
+		container, ok := table.commands[strings.ToUpper(names[0])]
+		if !ok {
+			container = &Command{Name: names[0], Arity: -2}
+			table.commands[strings.ToUpper(names[0])] = container
+		}
+		if nil == container.SubCommands {
+			container.SubCommands = make(map[string]*Command)
+		}
+		container.SubCommands[strings.ToUpper(names[1])] = cmd
+	}
+}
+
+// Get : Get top level command by name
+func (table *CommandTable) Get(name string) *Command {
+	return table.commands[strings.ToUpper(name)]
+}
+
+// Lookup : Find command of request, subcommand is resolved by first param
+func (table *CommandTable) Lookup(req *proto.Request) *Command {
+	cmd, ok := table.commands[req.Cmd]
+	if !ok {
+		return nil
+	}
+	if (nil != cmd.SubCommands) && (len(req.Params) > 0) {
+		if sub, ok := cmd.SubCommands[strings.ToUpper(req.Params[0])]; ok {
+			return sub
+		}
+	}
+	return cmd
+}
+
+// List : Get all top level commands sorted by name
+func (table *CommandTable) List() []*Command {
+	cmds := make([]*Command, 0, len(table.commands))
+	for _, cmd := range table.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// RegisterBaseCommands : Register commands of Processor interface
+func RegisterBaseCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "ping", Func: func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+			return proc.PING(sess, req)
+		}, Arity: -1, Flags: CMD_FAST, Group: GROUP_CONNECTION, Since: "1.0.0",
+			Summary: "Returns the server's liveliness response."},
+		&Command{Name: "auth", Func: func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+			return proc.AUTH(sess, req)
+		}, Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE | CMD_FAST | CMD_NOAUTH,
+			Group: GROUP_CONNECTION, Since: "1.0.0", Summary: "Authenticates the connection."},
+		&Command{Name: "hello", Func: func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+			return proc.HELLO(sess, req)
+		}, Arity: -1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE | CMD_FAST | CMD_NOAUTH,
+			Group: GROUP_CONNECTION, Since: "6.0.0", Summary: "Handshakes with the Redis server."},
+		&Command{Name: "multi", Func: func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+			return proc.MULTI(sess, req)
+		}, Arity: 1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE | CMD_FAST,
+			Group: GROUP_TRANSACTIONS, Since: "1.2.0", Summary: "Starts a transaction."},
+		&Command{Name: "exec", Func: func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+			return proc.EXEC(sess, req)
+		}, Arity: 1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_TRANSACTIONS, Since: "1.2.0", Summary: "Executes all commands in a transaction."},
+	)
+	registerMultiCommands(table)
+	registerPubSubCommands(table)
+	registerCommandCommands(table)
+	registerClientCommands(table)
+	registerConfigCommands(table)
+	registerInfoCommands(table)
+}
+
+// reflectTables : Command tables of processors without CommandProvider, cached by type
+var reflectTables sync.Map
+
+// GetCommandTable : Get command table of processor
+func GetCommandTable(proc Processor) *CommandTable {
+	if provider, ok := proc.(CommandProvider); ok {
+		return provider.Commands()
+	}
+
+	t := reflect.TypeOf(proc)
+	if table, ok := reflectTables.Load(t); ok {
+		return table.(*CommandTable)
+	}
+	table, _ := reflectTables.LoadOrStore(t, NewReflectTable(proc))
+	return table.(*CommandTable)
+}
+
+// NewReflectTable : Adapt processor whose commands are methods named as upper
+// case command, only methods with handler signature are registered, etc:
+// func(*Session, *proto.Request) (*proto.Response, error) or the legacy
+// func(*proto.Request) (*proto.Response, error). Method named as a base
+// command overrides it and all of its subcommands, metadata of the base
+// command is kept
+func NewReflectTable(proc Processor) *CommandTable {
+	table := NewCommandTable()
+	RegisterBaseCommands(table)
+	t := reflect.TypeOf(proc)
+	handlerType := reflect.TypeOf(func(*Session, *proto.Request) (*proto.Response, error) { return nil, nil })
+	legacyType := reflect.TypeOf(func(*proto.Request) (*proto.Response, error) { return nil, nil })
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		methodType := reflect.ValueOf(proc).Method(i).Type()
+		isLegacy := methodType.AssignableTo(legacyType)
+		if (method.Name != strings.ToUpper(method.Name)) || !(isLegacy || methodType.AssignableTo(handlerType)) {
+			continue
+		}
+
+		index := i
+		handler := func(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+			args := []reflect.Value{reflect.ValueOf(sess), reflect.ValueOf(req)}
+			if isLegacy {
+				args = args[1:]
+			}
+			result := reflect.ValueOf(proc).Method(index).Call(args)
+			var res *proto.Response
+			var err error
+			if !result[0].IsNil() {
+				res = result[0].Interface().(*proto.Response)
+			}
+			if !result[1].IsNil() {
+				err = result[1].Interface().(error)
+			}
+			return res, err
+		}
+
+		if base := table.Get(method.Name); nil != base {
+			override := *base
+			override.Func = handler
+			override.SubCommands = nil
+			table.commands[method.Name] = &override
+			continue
+		}
+		table.Register(&Command{Name: method.Name, Func: handler, Arity: -1})
+	}
+	return table
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"testing"
+)
+
+// legacyProc : Processor without command table, having only legacy methods,
+// one of which overrides a base command
+type legacyProc struct {
+	BaseProc
+}
+
+func (proc *legacyProc) ECHO(req *proto.Request) (*proto.Response, error) {
+	res := proto.NewResponse(proto.RES_TYPE_BULK)
+	res.SetString(req.Params[0])
+	return res, nil
+}
+
+func (proc *legacyProc) CLIENT(req *proto.Request) (*proto.Response, error) {
+	res := proto.NewResponse(proto.RES_TYPE_STATE)
+	res.SetString("legacy client")
+	return res, nil
+}
+
+func TestReflectTableAuth(t *testing.T) {
+	proc := &legacyProc{NewBaseProc(ProcConf{Passwd: "secret"})}
+	sess := NewSession("127.0.0.1:6379", "secret")
+
+	steps := []struct {
+		req  *proto.Request
+		typ  string
+		data string
+	}{
+		{&proto.Request{Cmd: "ECHO", Params: []string{"hi"}}, proto.RES_TYPE_ERROR, "NOAUTH Authentication required."},
+		{&proto.Request{Cmd: "AUTH"}, proto.RES_TYPE_ERROR, "ERR wrong number of arguments for 'auth' command"},
+		{&proto.Request{Cmd: "AUTH", Params: []string{"wrong"}}, proto.RES_TYPE_ERROR, "ERR invalid password"},
+		{&proto.Request{Cmd: "AUTH", Params: []string{"secret"}}, proto.RES_TYPE_STATE, "OK"},
+		{&proto.Request{Cmd: "ECHO", Params: []string{"hi"}}, proto.RES_TYPE_BULK, "hi"},
+	}
+	for i, step := range steps {
+		res, _ := ProcessReq(proc, sess, step.req)
+		if (step.typ != res.Type) || (step.data != res.Data) {
+			t.Fatalf("step %d %s: got %s%s, want %s%s", i, step.req.Cmd, res.Type, res.Data, step.typ, step.data)
+		}
+	}
+}
+
+func TestReflectTableHello(t *testing.T) {
+	proc := &legacyProc{NewBaseProc(ProcConf{Passwd: "secret"})}
+	sess := NewSession("127.0.0.1:6379", "secret")
+
+	res, _ := ProcessReq(proc, sess, &proto.Request{Cmd: "HELLO", Params: []string{"3", "AUTH", "default", "secret"}})
+	if proto.RES_TYPE_ERROR == res.Type {
+		t.Fatalf("HELLO AUTH: got error %s", res.Data)
+	}
+	if !sess.IsAuthenticated() || (proto.PROTO_RESP3 != sess.ProtoVer) {
+		t.Fatalf("HELLO AUTH: authenticated %v, protocol %d", sess.IsAuthenticated(), sess.ProtoVer)
+	}
+}
+
+func TestReflectTableOverride(t *testing.T) {
+	proc := &legacyProc{NewBaseProc(ProcConf{Passwd: "secret"})}
+	sess := NewSession("127.0.0.1:6379", "secret")
+
+	// Metadata of base command is kept, so it still requires auth
+	res, _ := ProcessReq(proc, sess, &proto.Request{Cmd: "CLIENT", Params: []string{"ID"}})
+	if "NOAUTH Authentication required." != res.Data {
+		t.Fatalf("CLIENT ID before AUTH: got %s%s", res.Type, res.Data)
+	}
+	ProcessReq(proc, sess, &proto.Request{Cmd: "AUTH", Params: []string{"secret"}})
+	for _, params := range [][]string{{"ID"}, {"SETNAME", "name"}} {
+		res, _ = ProcessReq(proc, sess, &proto.Request{Cmd: "CLIENT", Params: params})
+		if "legacy client" != res.Data {
+			t.Fatalf("CLIENT %v: got %s%s", params, res.Type, res.Data)
+		}
+	}
+	res, _ = ProcessReq(proc, sess, &proto.Request{Cmd: "CLIENT"})
+	if "ERR wrong number of arguments for 'client' command" != res.Data {
+		t.Fatalf("CLIENT without subcommand: got %s%s", res.Type, res.Data)
+	}
+}
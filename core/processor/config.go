@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"gredissimulate/helper"
+	"sort"
+	"strings"
+)
+
+// configParam : Parameter which can be changed at runtime by CONFIG SET
+type configParam struct {
+	get func(databases *store.Databases) string
+	set func(databases *store.Databases, value string) error
+}
+
+// configParams : Parameters of CONFIG GET and CONFIG SET by name
+var configParams = map[string]configParam{
+	"notify-keyspace-events": {
+		get: func(databases *store.Databases) string {
+			return store.FormatNotifyFlags(databases.GetNotifyFlags())
+		},
+		set: func(databases *store.Databases, value string) error {
+			flags, err := store.ParseNotifyFlags(value)
+			if nil == err {
+				databases.SetNotifyFlags(flags)
+			}
+			return err
+		},
+	},
+}
+
+// configGet : CONFIG GET parameter [parameter ...], reply parameters
+// matching any of the glob-style patterns with their values
+func configGet(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	databases := getDatabases(proc)
+	if nil == databases {
+		res = proto.NewErrorRes("ERR CONFIG is not supported by processor")
+		return
+	}
+	names := []string{}
+	for name := range configParams {
+		for _, pattern := range req.Params[1:] {
+			if helper.GlobMatch(strings.ToLower(pattern), name, false) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	res = proto.NewResponse(proto.RES_TYPE_MAP)
+	for _, name := range names {
+		res.SetPair(newBulkRes(name), newBulkRes(configParams[name].get(databases)))
+	}
+	return
+}
+
+// configSet : CONFIG SET parameter value [parameter value ...], parameters
+// are all looked up before any of them is set
+func configSet(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	databases := getDatabases(proc)
+	if nil == databases {
+		res = proto.NewErrorRes("ERR CONFIG is not supported by processor")
+		return
+	}
+	for i := 1; i < len(req.Params); i = i + 2 {
+		if _, ok := configParams[strings.ToLower(req.Params[i])]; !ok {
+			res = proto.NewErrorRes("ERR Unknown option or number of arguments for CONFIG SET - '" + req.Params[i] + "'")
+			return
+		}
+	}
+	for i := 1; i < len(req.Params); i = i + 2 {
+		name := strings.ToLower(req.Params[i])
+		if e := configParams[name].set(databases, req.Params[i+1]); nil != e {
+			res = proto.NewErrorRes("ERR CONFIG SET failed (possibly related to argument '" + name + "') - " + e.Error())
+			return
+		}
+	}
+	res = newStatusRes("OK")
+	return
+}
+
+func registerConfigCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "config|get", Func: configGet, Arity: -3, Flags: CMD_ADMIN | CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "2.0.0", Summary: "Returns the effective values of configuration parameters."},
+		&Command{Name: "config|set", Func: configSet, Arity: -4, CheckArgs: pairsFrom(1), Flags: CMD_ADMIN | CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "2.0.0", Summary: "Sets configuration parameters in-flight."},
+	)
+}
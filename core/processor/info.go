@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// infoSection : Section of INFO reply, fields are lines of `name:value`
+type infoSection struct {
+	name   string
+	fields func(proc Processor) []string
+}
+
+// infoSections : Sections of INFO in order of reply
+var infoSections = []infoSection{
+	{"server", func(proc Processor) []string {
+		return []string{
+			"redis_version:" + SERVER_VERSION,
+			"redis_mode:standalone",
+			"process_id:" + strconv.Itoa(os.Getpid()),
+		}
+	}},
+	{"stats", func(proc Processor) []string {
+		return []string{
+			"recovered_panics:" + strconv.FormatUint(GetRecoveredPanics(), 10),
+		}
+	}},
+}
+
+// info : INFO [section [section ...]], reply all sections if none is given,
+// unknown sections are ignored
+func info(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	wanted := map[string]bool{}
+	for _, param := range req.Params {
+		wanted[strings.ToLower(param)] = true
+	}
+	all := (0 == len(wanted)) || wanted["all"] || wanted["everything"] || wanted["default"]
+
+	var builder strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[section.name] {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\r\n")
+		}
+		builder.WriteString("# " + strings.Title(section.name) + "\r\n")
+		for _, field := range section.fields(proc) {
+			builder.WriteString(field + "\r\n")
+		}
+	}
+	res = proto.NewResponse(proto.RES_TYPE_VERBATIM)
+	res.SetVerbatim("txt", builder.String())
+	return
+}
+
+func registerInfoCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "info", Func: info, Arity: -1, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "1.0.0", Summary: "Returns information and statistics about the server."},
+	)
+}
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// panicProc : Processor whose command always panics
+type panicProc struct {
+	BaseProc
+}
+
+func (proc *panicProc) BOOM(sess *Session, req *proto.Request) (*proto.Response, error) {
+	var m map[string]int
+	m["key"] = 1
+	return nil, nil
+}
+
+func TestInfoRecoveredPanics(t *testing.T) {
+	proc := &panicProc{NewBaseProc(ProcConf{})}
+	sess := NewSession("127.0.0.1:6379", "")
+
+	before := GetRecoveredPanics()
+	res, _ := ProcessReq(proc, sess, &proto.Request{Cmd: "BOOM"})
+	if "ERR internal error" != res.Data {
+		t.Fatalf("BOOM: got %s%s", res.Type, res.Data)
+	}
+
+	res, _ = ProcessReq(proc, sess, &proto.Request{Cmd: "INFO", Params: []string{"stats"}})
+	want := "recovered_panics:" + strconv.FormatUint(before+1, 10) + "\r\n"
+	if !strings.Contains(res.Data, want) {
+		t.Fatalf("INFO stats: got %q, want it to contain %q", res.Data, want)
+	}
+}
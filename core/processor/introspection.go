@@ -0,0 +1,356 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"strings"
+)
+
+// cmdFlagNames : Flag names replied by COMMAND, in the order redis lists them
+var cmdFlagNames = []struct {
+	flag int
+	name string
+}{
+	{CMD_WRITE, "write"},
+	{CMD_READONLY, "readonly"},
+	{CMD_DENYOOM, "denyoom"},
+	{CMD_ADMIN, "admin"},
+	{CMD_NOSCRIPT, "noscript"},
+	{CMD_BLOCKING, "blocking"},
+	{CMD_LOADING, "loading"},
+	{CMD_STALE, "stale"},
+	{CMD_FAST, "fast"},
+	{CMD_NOAUTH, "no_auth"},
+}
+
+// groupCategories : ACL category of command group
+var groupCategories = map[string]string{
+	GROUP_GENERIC:      "@keyspace",
+	GROUP_STRING:       "@string",
+	GROUP_LIST:         "@list",
+	GROUP_SET:          "@set",
+	GROUP_SORTED_SET:   "@sortedset",
+	GROUP_HASH:         "@hash",
+	GROUP_PUBSUB:       "@pubsub",
+	GROUP_TRANSACTIONS: "@transaction",
+	GROUP_CONNECTION:   "@connection",
+	GROUP_STREAM:       "@stream",
+}
+
+// FlagNames : Get names of command flags
+func (cmd *Command) FlagNames() []string {
+	names := []string{}
+	for _, item := range cmdFlagNames {
+		if cmd.HasFlag(item.flag) {
+			names = append(names, item.name)
+		}
+	}
+	if nil != cmd.KeysFunc {
+		names = append(names, "movablekeys")
+	}
+	return names
+}
+
+// Categories : Get ACL categories derived from flags and group
+func (cmd *Command) Categories() []string {
+	categories := []string{}
+	if category, ok := groupCategories[cmd.Group]; ok {
+		categories = append(categories, category)
+	}
+	if cmd.HasFlag(CMD_WRITE) {
+		categories = append(categories, "@write")
+	}
+	if cmd.HasFlag(CMD_READONLY) {
+		categories = append(categories, "@read")
+	}
+	if cmd.HasFlag(CMD_ADMIN) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	if cmd.HasFlag(CMD_BLOCKING) {
+		categories = append(categories, "@blocking")
+	}
+	if cmd.HasFlag(CMD_FAST) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	return categories
+}
+
+// GetKeys : Get keys of request by key positions of command
+func (cmd *Command) GetKeys(req *proto.Request) []string {
+	if nil != cmd.KeysFunc {
+		return cmd.KeysFunc(req.Params)
+	}
+	keys := []string{}
+	if cmd.FirstKey <= 0 {
+		return keys
+	}
+
+	// Positions count command name, params start from position 1
+	argc := len(req.Params) + 1
+	last := cmd.LastKey
+	if last < 0 {
+		last = argc + last
+	}
+	step := cmd.KeyStep
+	if step <= 0 {
+		step = 1
+	}
+	for i := cmd.FirstKey; (i <= last) && (i < argc); i = i + step {
+		keys = append(keys, req.Params[i-1])
+	}
+	return keys
+}
+
+func registerCommandCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "command", Func: commandAll, Arity: -1, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "2.8.13", Summary: "Returns detailed information about all commands."},
+		&Command{Name: "command|count", Func: commandCount, Arity: 2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "2.8.13", Summary: "Returns a count of commands."},
+		&Command{Name: "command|info", Func: commandInfo, Arity: -2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "2.8.13", Summary: "Returns information about one, multiple or all commands."},
+		&Command{Name: "command|docs", Func: commandDocs, Arity: -2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "7.0.0", Summary: "Returns documentary information about one, multiple or all commands."},
+		&Command{Name: "command|list", Func: commandList, Arity: 2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "7.0.0", Summary: "Returns a list of command names."},
+		&Command{Name: "command|getkeys", Func: commandGetKeys, Arity: -3, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "2.8.13", Summary: "Extracts the key names from an arbitrary command."},
+		&Command{Name: "command|help", Func: commandHelp, Arity: 2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "5.0.0", Summary: "Returns helpful text about the different subcommands."},
+	)
+}
+
+// commandAll : COMMAND, info of all commands
+func commandAll(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, cmd := range GetCommandTable(proc).List() {
+		res.SetResponse(newCommandInfoRes(cmd))
+	}
+	return
+}
+
+// commandCount : COMMAND COUNT
+func commandCount(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = newIntRes(len(GetCommandTable(proc).List()))
+	return
+}
+
+// commandInfo : COMMAND INFO [command-name ...], null for unknown command
+func commandInfo(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if 1 == len(req.Params) {
+		return commandAll(proc, sess, req)
+	}
+
+	table := GetCommandTable(proc)
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, name := range req.Params[1:] {
+		if cmd := lookupByFullName(table, name); nil != cmd {
+			res.SetResponse(newCommandInfoRes(cmd))
+		} else {
+			res.SetResponse(proto.NewNullRes(proto.RES_TYPE_MULTI))
+		}
+	}
+	return
+}
+
+// commandDocs : COMMAND DOCS [command-name ...], unknown commands are skipped
+func commandDocs(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	table := GetCommandTable(proc)
+	cmds := []*Command{}
+	if 1 == len(req.Params) {
+		cmds = table.List()
+	} else {
+		for _, name := range req.Params[1:] {
+			if cmd := lookupByFullName(table, name); nil != cmd {
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
+
+	res = proto.NewResponse(proto.RES_TYPE_MAP)
+	for _, cmd := range cmds {
+		res.SetPair(newBulkRes(cmd.Name), newCommandDocsRes(cmd))
+	}
+	return
+}
+
+// commandList : COMMAND LIST, names of all commands
+func commandList(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	names := []string{}
+	for _, cmd := range GetCommandTable(proc).List() {
+		names = append(names, cmd.Name)
+		for _, sub := range sortedSubCommands(cmd) {
+			names = append(names, sub.Name)
+		}
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, names)
+	return
+}
+
+// commandGetKeys : COMMAND GETKEYS command [arg ...]
+func commandGetKeys(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	target := &proto.Request{Cmd: strings.ToUpper(req.Params[1]), Params: req.Params[2:]}
+	cmd := GetCommandTable(proc).Lookup(target)
+	if nil == cmd {
+		res = proto.NewErrorRes("ERR Invalid command specified")
+		return
+	}
+	if nil != cmd.CheckArity(target) {
+		res = proto.NewErrorRes("ERR Invalid number of arguments specified for command")
+		return
+	}
+
+	keys := cmd.GetKeys(target)
+	if 0 == len(keys) {
+		res = proto.NewErrorRes("ERR The command has no key arguments")
+		return
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, keys)
+	return
+}
+
+// commandHelp : COMMAND HELP
+func commandHelp(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	lines := []string{
+		"COMMAND <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"(no subcommand)",
+		"    Return details about all Redis commands.",
+		"COUNT",
+		"    Return the total number of commands in this Redis server.",
+		"LIST",
+		"    Return a list of all commands in this Redis server.",
+		"INFO [<command-name> ...]",
+		"    Return details about multiple Redis commands.",
+		"    If no command names are given, documentation details for all",
+		"    commands are returned.",
+		"DOCS [<command-name> ...]",
+		"    Return documentation details about multiple Redis commands.",
+		"    If no command names are given, documentation details for all",
+		"    commands are returned.",
+		"GETKEYS <full-command>",
+		"    Return the keys from a full Redis command.",
+		"HELP",
+		"    Print this help.",
+	}
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, line := range lines {
+		r := proto.NewResponse(proto.RES_TYPE_STATE)
+		r.SetString(line)
+		res.SetResponse(r)
+	}
+	return
+}
+
+// lookupByFullName : Find command by name, subcommand is named as `container|sub`
+func lookupByFullName(table *CommandTable, name string) *Command {
+	names := strings.SplitN(name, "|", 2)
+	cmd := table.Get(names[0])
+	if (nil == cmd) || (1 == len(names)) {
+		return cmd
+	}
+	return cmd.SubCommands[strings.ToUpper(names[1])]
+}
+
+func sortedSubCommands(cmd *Command) []*Command {
+	if nil == cmd.SubCommands {
+		return nil
+	}
+	subTable := &CommandTable{commands: cmd.SubCommands}
+	return subTable.List()
+}
+
+// newCommandInfoRes : Command info in the reply format of redis 7, etc:
+// name, arity, flags, first key, last key, step, ACL categories, tips, key
+// specs and subcommands
+func newCommandInfoRes(cmd *Command) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newBulkRes(cmd.Name))
+	res.SetResponse(newIntRes(cmd.Arity))
+	res.SetResponse(newStatusSetRes(cmd.FlagNames()))
+	res.SetResponse(newIntRes(cmd.FirstKey))
+	res.SetResponse(newIntRes(cmd.LastKey))
+	res.SetResponse(newIntRes(cmd.KeyStep))
+	res.SetResponse(newStatusSetRes(cmd.Categories()))
+	res.SetResponse(proto.NewResponse(proto.RES_TYPE_MULTI))
+	res.SetResponse(newKeySpecsRes(cmd))
+
+	subs := proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, sub := range sortedSubCommands(cmd) {
+		subs.SetResponse(newCommandInfoRes(sub))
+	}
+	res.SetResponse(subs)
+	return res
+}
+
+// newKeySpecsRes : Key specs of command derived from key positions
+func newKeySpecsRes(cmd *Command) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	if cmd.FirstKey <= 0 {
+		return res
+	}
+
+	lastKey := cmd.LastKey
+	if lastKey >= 0 {
+		lastKey = lastKey - cmd.FirstKey
+	}
+	flags := "RW"
+	if cmd.HasFlag(CMD_READONLY) {
+		flags = "RO"
+	}
+
+	beginSpec := proto.NewResponse(proto.RES_TYPE_MAP)
+	beginSpec.SetPair(newBulkRes("index"), newIntRes(cmd.FirstKey))
+	beginSearch := proto.NewResponse(proto.RES_TYPE_MAP)
+	beginSearch.SetPair(newBulkRes("type"), newBulkRes("index"))
+	beginSearch.SetPair(newBulkRes("spec"), beginSpec)
+
+	findSpec := proto.NewResponse(proto.RES_TYPE_MAP)
+	findSpec.SetPair(newBulkRes("lastkey"), newIntRes(lastKey))
+	findSpec.SetPair(newBulkRes("keystep"), newIntRes(cmd.KeyStep))
+	findSpec.SetPair(newBulkRes("limit"), newIntRes(0))
+	findKeys := proto.NewResponse(proto.RES_TYPE_MAP)
+	findKeys.SetPair(newBulkRes("type"), newBulkRes("range"))
+	findKeys.SetPair(newBulkRes("spec"), findSpec)
+
+	spec := proto.NewResponse(proto.RES_TYPE_MAP)
+	spec.SetPair(newBulkRes("flags"), newStatusSetRes([]string{flags}))
+	spec.SetPair(newBulkRes("begin_search"), beginSearch)
+	spec.SetPair(newBulkRes("find_keys"), findKeys)
+	res.SetResponse(spec)
+	return res
+}
+
+// newCommandDocsRes : Docs of command, etc: summary, since, group
+func newCommandDocsRes(cmd *Command) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MAP)
+	if "" != cmd.Summary {
+		res.SetPair(newBulkRes("summary"), newBulkRes(cmd.Summary))
+	}
+	if "" != cmd.Since {
+		res.SetPair(newBulkRes("since"), newBulkRes(cmd.Since))
+	}
+	if "" != cmd.Group {
+		res.SetPair(newBulkRes("group"), newBulkRes(cmd.Group))
+	}
+
+	subs := sortedSubCommands(cmd)
+	if len(subs) > 0 {
+		subDocs := proto.NewResponse(proto.RES_TYPE_MAP)
+		for _, sub := range subs {
+			subDocs.SetPair(newBulkRes(sub.Name), newCommandDocsRes(sub))
+		}
+		res.SetPair(newBulkRes("subcommands"), subDocs)
+	}
+	return res
+}
+
+func newStatusSetRes(items []string) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_SET)
+	for _, item := range items {
+		r := proto.NewResponse(proto.RES_TYPE_STATE)
+		r.SetString(item)
+		res.SetResponse(r)
+	}
+	return res
+}
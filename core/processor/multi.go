@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+)
+
+// databasesOwner : Processor holding databases shared by connections, such
+// as BaseProc and processors embedding it
+type databasesOwner interface {
+	GetDatabases() *store.Databases
+}
+
+// getDatabases : Databases of processor, nil if it has none
+func getDatabases(proc Processor) *store.Databases {
+	if owner, ok := proc.(databasesOwner); ok {
+		return owner.GetDatabases()
+	}
+	return nil
+}
+
+// isTransactionCmd : Commands run at once instead of being queued by MULTI
+func isTransactionCmd(command *Command) bool {
+	switch command.Name {
+	case "multi", "exec", "discard", "watch":
+		return true
+	}
+	return false
+}
+
+// execMulti : Run queued requests of MULTI while databases are locked, so no
+// command of other connections runs in the middle. Transaction is discarded
+// if a command is rejected while queuing, and replied null if a watched key
+// is modified
+func execMulti(proc Processor, sess *Session) (res *proto.Response, err error) {
+	if !sess.IsMulti() {
+		res = proto.NewErrorRes("ERR EXEC without MULTI")
+		return
+	}
+	reqQue := sess.GetReqQue()
+	aborted := sess.execAbort
+	sess.SetMulti(false)
+	databases := getDatabases(proc)
+	if nil != databases {
+		databases.Lock()
+		defer databases.Unlock()
+	}
+	defer sess.Unwatch()
+
+	if aborted {
+		res = proto.NewErrorRes("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+	if (nil != databases) && sess.IsWatchedChanged(databases) {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+		return
+	}
+
+	sess.isExec = true
+	defer func() { sess.isExec = false }()
+	table := GetCommandTable(proc)
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, request := range reqQue {
+		var result *proto.Response
+		if command := table.Lookup(request); (nil != command) && (nil != command.Func) {
+			result, _ = callCommand(command, proc, sess, request)
+		}
+		if nil != result {
+			res.SetResponse(result)
+		} else {
+			res.SetResponse(proto.NewErrorRes("Process `" + request.Cmd + "` error"))
+		}
+	}
+	return
+}
+
+// watchCmd : WATCH, keys are watched until EXEC, DISCARD or UNWATCH
+func watchCmd(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if sess.IsMulti() {
+		res = proto.NewErrorRes("ERR WATCH inside MULTI is not allowed")
+		return
+	}
+	if databases := getDatabases(proc); nil != databases {
+		ks := databases.Get(sess.DB)
+		for _, key := range req.Params {
+			sess.Watch(sess.DB, ks, key)
+		}
+	}
+	res = newStatusRes("OK")
+	return
+}
+
+// unwatchCmd : UNWATCH, forget all watched keys
+func unwatchCmd(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	sess.Unwatch()
+	res = newStatusRes("OK")
+	return
+}
+
+// discardCmd : DISCARD, drop queued requests and unwatch all keys
+func discardCmd(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if !sess.IsMulti() {
+		res = proto.NewErrorRes("ERR DISCARD without MULTI")
+		return
+	}
+	sess.SetMulti(false)
+	sess.Unwatch()
+	res = newStatusRes("OK")
+	return
+}
+
+func registerMultiCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "watch", Func: watchCmd, Arity: -2, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE | CMD_FAST, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_TRANSACTIONS, Since: "2.2.0", Summary: "Monitors changes to keys to determine the execution of a transaction."},
+		&Command{Name: "unwatch", Func: unwatchCmd, Arity: 1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE | CMD_FAST,
+			Group: GROUP_TRANSACTIONS, Since: "2.2.0", Summary: "Forgets about watched keys of a transaction."},
+		&Command{Name: "discard", Func: discardCmd, Arity: 1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE | CMD_FAST,
+			Group: GROUP_TRANSACTIONS, Since: "2.0.0", Summary: "Discards a transaction."},
+	)
+}
@@ -0,0 +1,276 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"strings"
+)
+
+// Names of subscription replies of each kind
+var (
+	subscribeNames   = [store.SUB_KINDS]string{"subscribe", "psubscribe", "ssubscribe"}
+	unsubscribeNames = [store.SUB_KINDS]string{"unsubscribe", "punsubscribe", "sunsubscribe"}
+)
+
+// isSubscribedModeCmd : Commands allowed for RESP2 connection having
+// subscriptions
+func isSubscribedModeCmd(command *Command) bool {
+	switch command.Name {
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ssubscribe", "sunsubscribe", "ping", "quit", "reset":
+		return true
+	}
+	return false
+}
+
+// getBroker : Pub/sub broker of processor, error response if processor
+// has no databases
+func getBroker(proc Processor) (*store.Broker, *proto.Response) {
+	databases := getDatabases(proc)
+	if nil == databases {
+		return nil, proto.NewErrorRes("ERR pub/sub is not supported by processor")
+	}
+	return databases.GetBroker(), nil
+}
+
+// subscribeCmd : SUBSCRIBE channel [channel ...]
+func subscribeCmd(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return subscribeGeneric(proc, sess, req, store.SUB_CHANNEL)
+}
+
+// psubscribeCmd : PSUBSCRIBE pattern [pattern ...]
+func psubscribeCmd(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return subscribeGeneric(proc, sess, req, store.SUB_PATTERN)
+}
+
+// ssubscribeCmd : SSUBSCRIBE shardchannel [shardchannel ...]
+func ssubscribeCmd(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return subscribeGeneric(proc, sess, req, store.SUB_SHARD)
+}
+
+// unsubscribeCmd : UNSUBSCRIBE [channel [channel ...]]
+func unsubscribeCmd(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return unsubscribeGeneric(proc, sess, req, store.SUB_CHANNEL)
+}
+
+// punsubscribeCmd : PUNSUBSCRIBE [pattern [pattern ...]]
+func punsubscribeCmd(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return unsubscribeGeneric(proc, sess, req, store.SUB_PATTERN)
+}
+
+// sunsubscribeCmd : SUNSUBSCRIBE [shardchannel [shardchannel ...]]
+func sunsubscribeCmd(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return unsubscribeGeneric(proc, sess, req, store.SUB_SHARD)
+}
+
+// subscribeGeneric : Subscribe names of kind, each one is confirmed by a
+// reply carrying the count of subscriptions
+func subscribeGeneric(proc Processor, sess *Session, req *proto.Request, kind int) (res *proto.Response, err error) {
+	broker, res := getBroker(proc)
+	if nil != res {
+		return
+	}
+	replies := make([]*proto.Response, 0, len(req.Params))
+	for _, name := range req.Params {
+		sess.Subscribe(broker, kind, name)
+		replies = append(replies, newSubscriptionRes(subscribeNames[kind], name, true, countSubscriptions(sess, kind)))
+	}
+	res = pushReplies(sess, replies)
+	return
+}
+
+// unsubscribeGeneric : Unsubscribe names of kind, all subscribed ones if no
+// name is given
+func unsubscribeGeneric(proc Processor, sess *Session, req *proto.Request, kind int) (res *proto.Response, err error) {
+	names := req.Params
+	if 0 == len(names) {
+		names = sess.GetSubscriptions(kind)
+	}
+	if 0 == len(names) {
+		res = newSubscriptionRes(unsubscribeNames[kind], "", false, countSubscriptions(sess, kind))
+		return
+	}
+	replies := make([]*proto.Response, 0, len(names))
+	for _, name := range names {
+		sess.Unsubscribe(kind, name)
+		replies = append(replies, newSubscriptionRes(unsubscribeNames[kind], name, true, countSubscriptions(sess, kind)))
+	}
+	res = pushReplies(sess, replies)
+	return
+}
+
+// countSubscriptions : Count replied by subscription of kind, shard channels
+// are counted apart from channels and patterns
+func countSubscriptions(sess *Session, kind int) int {
+	if store.SUB_SHARD == kind {
+		return sess.CountSubscriptions(store.SUB_SHARD)
+	}
+	return sess.CountSubscriptions(store.SUB_CHANNEL, store.SUB_PATTERN)
+}
+
+// pushReplies : Reply the first one of multiple replies of a command, others
+// are pushed and written after it
+func pushReplies(sess *Session, replies []*proto.Response) *proto.Response {
+	for _, reply := range replies[1:] {
+		sess.Push(reply)
+	}
+	return replies[0]
+}
+
+// publishCmd : PUBLISH channel message, reply count of clients received it
+func publishCmd(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	broker, res := getBroker(proc)
+	if nil != res {
+		return
+	}
+	res = newIntRes(broker.Publish(req.Params[0], req.Params[1]))
+	return
+}
+
+// spublishCmd : SPUBLISH shardchannel message
+func spublishCmd(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	broker, res := getBroker(proc)
+	if nil != res {
+		return
+	}
+	res = newIntRes(broker.SPublish(req.Params[0], req.Params[1]))
+	return
+}
+
+// pubsubChannels : PUBSUB CHANNELS [pattern]
+func pubsubChannels(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return pubsubNamesGeneric(proc, req, store.SUB_CHANNEL)
+}
+
+// pubsubShardChannels : PUBSUB SHARDCHANNELS [pattern]
+func pubsubShardChannels(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return pubsubNamesGeneric(proc, req, store.SUB_SHARD)
+}
+
+// pubsubNamesGeneric : Active channels of kind matching optional pattern
+func pubsubNamesGeneric(proc Processor, req *proto.Request, kind int) (res *proto.Response, err error) {
+	broker, res := getBroker(proc)
+	if nil != res {
+		return
+	}
+	pattern := ""
+	if len(req.Params) > 1 {
+		pattern = req.Params[1]
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, broker.Names(kind, pattern))
+	return
+}
+
+// pubsubNumSub : PUBSUB NUMSUB [channel [channel ...]]
+func pubsubNumSub(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return pubsubNumSubGeneric(proc, req, store.SUB_CHANNEL)
+}
+
+// pubsubShardNumSub : PUBSUB SHARDNUMSUB [shardchannel [shardchannel ...]]
+func pubsubShardNumSub(proc Processor, sess *Session, req *proto.Request) (*proto.Response, error) {
+	return pubsubNumSubGeneric(proc, req, store.SUB_SHARD)
+}
+
+// pubsubNumSubGeneric : Count of subscribers of each channel of kind
+func pubsubNumSubGeneric(proc Processor, req *proto.Request, kind int) (res *proto.Response, err error) {
+	broker, res := getBroker(proc)
+	if nil != res {
+		return
+	}
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, channel := range req.Params[1:] {
+		res.SetResponse(newBulkRes(channel))
+		res.SetResponse(newIntRes(broker.NumSub(kind, channel)))
+	}
+	return
+}
+
+// pubsubNumPat : PUBSUB NUMPAT, count of patterns having subscribers
+func pubsubNumPat(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	broker, res := getBroker(proc)
+	if nil != res {
+		return
+	}
+	res = newIntRes(broker.NumPat())
+	return
+}
+
+// pubsubHelp : PUBSUB HELP
+func pubsubHelp(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	lines := []string{
+		"PUBSUB <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"CHANNELS [<pattern>]",
+		"    Return the currently active channels matching a <pattern> (default: '*').",
+		"NUMPAT",
+		"    Return number of subscriptions to patterns.",
+		"NUMSUB [<channel> ...]",
+		"    Return the number of subscribers for the specified channels, excluding",
+		"    pattern subscriptions(default: no channels).",
+		"SHARDCHANNELS [<pattern>]",
+		"    Return the currently active shard level channels matching a <pattern> (default: '*').",
+		"SHARDNUMSUB [<shardchannel> ...]",
+		"    Return the number of subscribers for the specified shard level channel(s)",
+		"HELP",
+		"    Print this help.",
+	}
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, line := range lines {
+		r := proto.NewResponse(proto.RES_TYPE_STATE)
+		r.SetString(line)
+		res.SetResponse(r)
+	}
+	return
+}
+
+// newSubscriptionRes : Reply of subscription change, push frame in RESP3,
+// name is null if there is no subscription to remove
+func newSubscriptionRes(kind string, name string, hasName bool, count int) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_PUSH)
+	res.SetResponse(newBulkRes(kind))
+	if hasName {
+		res.SetResponse(newBulkRes(name))
+	} else {
+		res.SetResponse(proto.NewNullRes(proto.RES_TYPE_BULK))
+	}
+	res.SetResponse(newIntRes(count))
+	return res
+}
+
+// newSubscribedModeErrRes : Error of command not allowed for RESP2
+// connection having subscriptions
+func newSubscribedModeErrRes(command *Command) *proto.Response {
+	return proto.NewErrorRes("ERR Can't execute '" + strings.ToLower(command.Name) +
+		"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+}
+
+func registerPubSubCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "subscribe", Func: subscribeCmd, Arity: -2, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.0.0", Summary: "Listens for messages published to channels."},
+		&Command{Name: "unsubscribe", Func: unsubscribeCmd, Arity: -1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.0.0", Summary: "Stops listening to messages posted to channels."},
+		&Command{Name: "psubscribe", Func: psubscribeCmd, Arity: -2, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.0.0", Summary: "Listens for messages published to channels that match one or more patterns."},
+		&Command{Name: "punsubscribe", Func: punsubscribeCmd, Arity: -1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.0.0", Summary: "Stops listening to messages published to channels that match one or more patterns."},
+		&Command{Name: "ssubscribe", Func: ssubscribeCmd, Arity: -2, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_PUBSUB, Since: "7.0.0", Summary: "Listens for messages published to shard channels."},
+		&Command{Name: "sunsubscribe", Func: sunsubscribeCmd, Arity: -1, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_PUBSUB, Since: "7.0.0", Summary: "Stops listening to messages posted to shard channels."},
+		&Command{Name: "publish", Func: publishCmd, Arity: 3, Flags: CMD_LOADING | CMD_STALE | CMD_FAST,
+			Group: GROUP_PUBSUB, Since: "2.0.0", Summary: "Posts a message to a channel."},
+		&Command{Name: "spublish", Func: spublishCmd, Arity: 3, Flags: CMD_LOADING | CMD_STALE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_PUBSUB, Since: "7.0.0", Summary: "Post a message to a shard channel."},
+		&Command{Name: "pubsub|channels", Func: pubsubChannels, Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.8.0", Summary: "Returns the active channels."},
+		&Command{Name: "pubsub|numsub", Func: pubsubNumSub, Arity: -2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.8.0", Summary: "Returns a count of subscribers to channels."},
+		&Command{Name: "pubsub|numpat", Func: pubsubNumPat, Arity: 2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "2.8.0", Summary: "Returns a count of unique pattern subscriptions."},
+		&Command{Name: "pubsub|shardchannels", Func: pubsubShardChannels, Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "7.0.0", Summary: "Returns the active shard channels."},
+		&Command{Name: "pubsub|shardnumsub", Func: pubsubShardNumSub, Arity: -2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "7.0.0", Summary: "Returns the count of subscribers of shard channels."},
+		&Command{Name: "pubsub|help", Func: pubsubHelp, Arity: 2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_PUBSUB, Since: "6.2.0", Summary: "Returns helpful text about the different subcommands."},
+	)
+}
@@ -0,0 +1,297 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"sort"
+	"sync/atomic"
+)
+
+// DEFAULT_USER : The only user supported, authenticated by requirepass
+const DEFAULT_USER = "default"
+
+// lastSessionID : Last id assigned to session
+var lastSessionID int64
+
+// Session : Context of a client connection, created once per connection and
+// passed to every command handler
+type Session struct {
+	ID         int64  // Unique client id
+	RemoteAddr string // Remote address of client
+	User       string // Authenticated user, empty if client has not authenticated
+	DB         int    // Selected database index
+	Name       string // Client name set by CLIENT SETNAME or HELLO SETNAME
+	ProtoVer   int    // Protocol version, PROTO_RESP2 or PROTO_RESP3
+	isMulti    bool
+	isExec     bool
+	execAbort  bool // A command is rejected while queuing, EXEC discards the transaction
+	reqQue     []*proto.Request
+	watched    []watchedKey
+	data       map[string]interface{}
+	watcher    ConnWatcher
+	pusher     Pusher
+	broker     *store.Broker                    // Broker of subscriptions, set at the first subscription
+	subs       [store.SUB_KINDS]map[string]bool // Subscribed names of each kind
+}
+
+// ConnWatcher : Watch connection of session while command is blocked, the
+// returned channel is closed if connection is closed by client, watching
+// ends when stop returns
+type ConnWatcher func() (closed <-chan struct{}, stop func())
+
+// Pusher : Write response to connection of session out of the order of
+// requests, such as messages of subscribed channels
+type Pusher func(res *proto.Response)
+
+// NewSession : Create a new session, client is authenticated already if
+// there is no password
+//
+// @param remoteAddr string : remote address of client
+// @param passwd string : password of server
+func NewSession(remoteAddr string, passwd string) *Session {
+	sess := &Session{
+		ID:         atomic.AddInt64(&lastSessionID, 1),
+		RemoteAddr: remoteAddr,
+		ProtoVer:   proto.PROTO_RESP2,
+		data:       make(map[string]interface{}),
+	}
+	if "" == passwd {
+		sess.User = DEFAULT_USER
+	}
+	return sess
+}
+
+// IsAuthenticated : Whether client has been authenticated
+func (sess *Session) IsAuthenticated() bool {
+	return "" != sess.User
+}
+
+// IsMulti : Is session in multi processing
+func (sess *Session) IsMulti() bool {
+	return sess.isMulti
+}
+
+// SetMulti : Update multi flag, request queue is cleared
+func (sess *Session) SetMulti(flag bool) {
+	sess.isMulti = flag
+	sess.execAbort = false
+	sess.reqQue = nil
+}
+
+// IsExec : Whether queued commands are being executed by EXEC, blocking
+// commands do not block in this case
+func (sess *Session) IsExec() bool {
+	return sess.isExec
+}
+
+// SetConnWatcher : Set watcher of connection, sessions without watcher never
+// see connection closed while blocked
+func (sess *Session) SetConnWatcher(watcher ConnWatcher) {
+	sess.watcher = watcher
+}
+
+// WatchConn : Start watching connection while command is blocked
+func (sess *Session) WatchConn() (closed <-chan struct{}, stop func()) {
+	if nil == sess.watcher {
+		return nil, func() {}
+	}
+	return sess.watcher()
+}
+
+// SetPusher : Set pusher of connection, responses pushed to sessions
+// without pusher are dropped
+func (sess *Session) SetPusher(pusher Pusher) {
+	sess.pusher = pusher
+}
+
+// Push : Push response to connection, it is written after the reply of the
+// command being processed
+func (sess *Session) Push(res *proto.Response) {
+	if nil != sess.pusher {
+		sess.pusher(res)
+	}
+}
+
+// Deliver : Push message of subscription to connection, called by goroutine
+// of publisher
+func (sess *Session) Deliver(kind int, pattern string, channel string, message string) {
+	res := proto.NewResponse(proto.RES_TYPE_PUSH)
+	switch kind {
+	case store.SUB_PATTERN:
+		res.SetResponse(newBulkRes("pmessage"))
+		res.SetResponse(newBulkRes(pattern))
+	case store.SUB_SHARD:
+		res.SetResponse(newBulkRes("smessage"))
+	default:
+		res.SetResponse(newBulkRes("message"))
+	}
+	res.SetResponse(newBulkRes(channel))
+	res.SetResponse(newBulkRes(message))
+	sess.Push(res)
+}
+
+// Subscribe : Subscribe name of kind, false if it is subscribed already
+func (sess *Session) Subscribe(broker *store.Broker, kind int, name string) bool {
+	if sess.subs[kind][name] {
+		return false
+	}
+	if nil == sess.subs[kind] {
+		sess.subs[kind] = make(map[string]bool)
+	}
+	sess.broker = broker
+	sess.subs[kind][name] = true
+	broker.Subscribe(kind, sess, name)
+	return true
+}
+
+// Unsubscribe : Unsubscribe name of kind, false if it is not subscribed
+func (sess *Session) Unsubscribe(kind int, name string) bool {
+	if !sess.subs[kind][name] {
+		return false
+	}
+	delete(sess.subs[kind], name)
+	sess.broker.Unsubscribe(kind, sess, name)
+	return true
+}
+
+// GetSubscriptions : Sorted names subscribed of kind
+func (sess *Session) GetSubscriptions(kind int) []string {
+	names := make([]string, 0, len(sess.subs[kind]))
+	for name := range sess.subs[kind] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CountSubscriptions : Count of subscribed names of kinds
+func (sess *Session) CountSubscriptions(kinds ...int) int {
+	count := 0
+	for _, kind := range kinds {
+		count = count + len(sess.subs[kind])
+	}
+	return count
+}
+
+// IsSubscribed : Whether session has any subscription, RESP2 connection
+// can only run commands of subscription in this case
+func (sess *Session) IsSubscribed() bool {
+	return sess.CountSubscriptions(store.SUB_CHANNEL, store.SUB_PATTERN, store.SUB_SHARD) > 0
+}
+
+// GetReqQue : Get request queue of MULTI
+func (sess *Session) GetReqQue() []*proto.Request {
+	return sess.reqQue
+}
+
+// AppendReq : Push request to MULTI queue
+func (sess *Session) AppendReq(req *proto.Request) {
+	sess.reqQue = append(sess.reqQue, req)
+}
+
+// watchedKey : Key watched by session and its version when it is watched
+type watchedKey struct {
+	db      int
+	ks      store.Keyspace // Keyspace of db when key is watched, SWAPDB may replace it
+	key     string
+	version uint64
+}
+
+// Watch : Watch key of keyspace of database db, watching a key again does
+// nothing
+func (sess *Session) Watch(db int, ks store.Keyspace, key string) {
+	for _, w := range sess.watched {
+		if (w.db == db) && (w.key == key) {
+			return
+		}
+	}
+	sess.watched = append(sess.watched, watchedKey{db: db, ks: ks, key: key, version: ks.Watch(key)})
+}
+
+// Unwatch : Stop watching all keys
+func (sess *Session) Unwatch() {
+	for _, w := range sess.watched {
+		w.ks.Unwatch(w.key)
+	}
+	sess.watched = nil
+}
+
+// IsWatchedChanged : Whether any watched key is modified since it is watched,
+// keys of a database swapped by SWAPDB are treated as modified
+func (sess *Session) IsWatchedChanged(databases *store.Databases) bool {
+	for _, w := range sess.watched {
+		if (databases.Get(w.db) != w.ks) || (w.ks.Version(w.key) != w.version) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close : Release state of session kept by storage shared with other
+// connections, called when connection is closed
+func (sess *Session) Close() {
+	sess.Unwatch()
+	for kind := range sess.subs {
+		for name := range sess.subs[kind] {
+			sess.Unsubscribe(kind, name)
+		}
+	}
+}
+
+// GetData : Get user data stored by processor
+func (sess *Session) GetData(key string) (interface{}, bool) {
+	value, ok := sess.data[key]
+	return value, ok
+}
+
+// SetData : Store user data that survives across commands
+func (sess *Session) SetData(key string, value interface{}) {
+	sess.data[key] = value
+}
+
+// DelData : Remove user data
+func (sess *Session) DelData(key string) {
+	delete(sess.data, key)
+}
+
+func registerClientCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "client|id", Func: clientID, Arity: 2, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_CONNECTION, Since: "5.0.0", Summary: "Returns the unique client ID of the connection."},
+		&Command{Name: "client|getname", Func: clientGetName, Arity: 2, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_CONNECTION, Since: "2.6.9", Summary: "Returns the name of the connection."},
+		&Command{Name: "client|setname", Func: clientSetName, Arity: 3, Flags: CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_CONNECTION, Since: "2.6.9", Summary: "Sets the connection name."},
+	)
+}
+
+// clientID : CLIENT ID
+func clientID(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = newIntRes(int(sess.ID))
+	return
+}
+
+// clientGetName : CLIENT GETNAME, null if name is not set
+func clientGetName(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if "" == sess.Name {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	} else {
+		res = newBulkRes(sess.Name)
+	}
+	return
+}
+
+// clientSetName : CLIENT SETNAME name, empty name clears it
+func clientSetName(proc Processor, sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	name := req.Params[1]
+	for _, c := range name {
+		if (c < '!') || (c > '~') {
+			res = proto.NewErrorRes("ERR Client names cannot contain spaces, newlines or special characters.")
+			return
+		}
+	}
+	sess.Name = name
+	res = proto.NewResponse(proto.RES_TYPE_STATE)
+	res.SetString("OK")
+	return
+}
@@ -0,0 +1,147 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"strconv"
+	"strings"
+)
+
+// SELECT : Change database of session
+func (proc *SimpleProc) SELECT(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	index, e := strconv.Atoi(req.Params[0])
+	if nil != e {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	if !proc.databases.IsValid(index) {
+		res = proto.NewErrorRes("ERR DB index is out of range")
+		return
+	}
+	sess.DB = index
+	res = newStatusRes("OK")
+	return
+}
+
+// SWAPDB : Swap two databases, clients selecting them see the swapped data
+func (proc *SimpleProc) SWAPDB(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	index1, e := strconv.Atoi(req.Params[0])
+	if nil != e {
+		res = proto.NewErrorRes("ERR invalid first DB index")
+		return
+	}
+	index2, e := strconv.Atoi(req.Params[1])
+	if nil != e {
+		res = proto.NewErrorRes("ERR invalid second DB index")
+		return
+	}
+	if !proc.databases.IsValid(index1) || !proc.databases.IsValid(index2) {
+		res = proto.NewErrorRes("ERR DB index is out of range")
+		return
+	}
+	if index1 != index2 {
+		proc.databases.Swap(index1, index2)
+	}
+	res = newStatusRes("OK")
+	return
+}
+
+// MOVE : Move key to other database, reply 0 if key does not exist in
+// current database or exists in target database already
+func (proc *SimpleProc) MOVE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key := req.Params[0]
+	index, e := strconv.Atoi(req.Params[1])
+	if nil != e {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	if !proc.databases.IsValid(index) {
+		res = proto.NewErrorRes("ERR DB index is out of range")
+		return
+	}
+	if index == sess.DB {
+		res = proto.NewErrorRes("ERR source and destination objects are the same")
+		return
+	}
+
+	src := proc.GetKeyspace(sess)
+	dst := proc.databases.Get(index)
+	res = newIntRes(0)
+	// Destination is looked up for writing, so Peek which notifies no miss
+	exists := false
+	dst.Peek(key, func(obj *store.Object) {
+		exists = nil != obj
+	})
+	if exists {
+		return
+	}
+	obj := src.Take(key)
+	if nil == obj {
+		return
+	}
+	if !dst.Put(key, obj, true) {
+		// Key is created in target database concurrently, give it back
+		src.Put(key, obj, false)
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "move_from", key)
+	proc.databases.Notify(store.NOTIFY_GENERIC, "move_to", index, key)
+	res = newIntRes(1)
+	return
+}
+
+// FLUSHDB : Delete all keys of current database
+func (proc *SimpleProc) FLUSHDB(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if res = checkFlushMode(req); nil != res {
+		return
+	}
+	proc.GetKeyspace(sess).Flush()
+	res = newStatusRes("OK")
+	return
+}
+
+// FLUSHALL : Delete all keys of all databases
+func (proc *SimpleProc) FLUSHALL(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if res = checkFlushMode(req); nil != res {
+		return
+	}
+	proc.databases.FlushAll()
+	res = newStatusRes("OK")
+	return
+}
+
+// DBSIZE : Count of keys in current database
+func (proc *SimpleProc) DBSIZE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = newIntRes(proc.GetKeyspace(sess).Len())
+	return
+}
+
+// checkFlushMode : Check optional ASYNC|SYNC of flush commands, flush is
+// always done synchronously
+func checkFlushMode(req *proto.Request) *proto.Response {
+	if 0 == len(req.Params) {
+		return nil
+	}
+	mode := strings.ToUpper(req.Params[0])
+	if ("ASYNC" != mode) && ("SYNC" != mode) {
+		return proto.NewErrorRes(ERR_SYNTAX)
+	}
+	return nil
+}
+
+func registerDbCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "select", Func: simpleCmd((*SimpleProc).SELECT), Arity: 2, Flags: CMD_LOADING | CMD_STALE | CMD_FAST,
+			Group: GROUP_CONNECTION, Since: "1.0.0", Summary: "Changes the selected database."},
+		&Command{Name: "swapdb", Func: simpleCmd((*SimpleProc).SWAPDB), Arity: 3, Flags: CMD_WRITE | CMD_FAST,
+			Group: GROUP_SERVER, Since: "4.0.0", Summary: "Swaps two Redis databases."},
+		&Command{Name: "move", Func: simpleCmd((*SimpleProc).MOVE), Arity: 3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Moves a key to another database."},
+		&Command{Name: "flushdb", Func: simpleCmd((*SimpleProc).FLUSHDB), Arity: -1, CheckArgs: maxArgs(1), Flags: CMD_WRITE,
+			Group: GROUP_SERVER, Since: "1.0.0", Summary: "Removes all keys from the current database."},
+		&Command{Name: "flushall", Func: simpleCmd((*SimpleProc).FLUSHALL), Arity: -1, CheckArgs: maxArgs(1), Flags: CMD_WRITE,
+			Group: GROUP_SERVER, Since: "1.0.0", Summary: "Removes all keys from all databases."},
+		&Command{Name: "dbsize", Func: simpleCmd((*SimpleProc).DBSIZE), Arity: 1, Flags: CMD_READONLY | CMD_FAST,
+			Group: GROUP_SERVER, Since: "1.0.0", Summary: "Returns the number of keys in the database."},
+	)
+}
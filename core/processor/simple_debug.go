@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"gredissimulate/core/clock"
+	"gredissimulate/core/proto"
+	"math"
+	"strconv"
+	"time"
+)
+
+// debugAdvanceTime : DEBUG ADVANCE-TIME milliseconds, move clock of server
+// forward, keys whose expire time has passed are expired as in real time
+func (proc *SimpleProc) debugAdvanceTime(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if !proc.enableDebug {
+		res = proto.NewErrorRes("ERR DEBUG command not allowed. If the enable-debug-command option is set to \"local\", you can run it from a local connection, otherwise you need to fix the enable-debug-command option in your configuration file before restarting the server.")
+		return
+	}
+	advancer, ok := proc.clock.(clock.Advancer)
+	if !ok {
+		res = proto.NewErrorRes("ERR DEBUG ADVANCE-TIME requires a clock that can be advanced, enable fake-clock in configuration file")
+		return
+	}
+	ms, e := strconv.ParseInt(req.Params[1], 10, 64)
+	if (nil != e) || (ms < 0) {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	if ms > math.MaxInt64/int64(time.Millisecond) {
+		// Duration would overflow and move clock backwards
+		res = proto.NewErrorRes("ERR value is out of range")
+		return
+	}
+	// Lock of databases is held by this command or by the running EXEC, so
+	// cron is run here instead of by listeners of clock which take the lock
+	advancer.Forward(time.Duration(ms) * time.Millisecond)
+	if nil != proc.databases {
+		proc.databases.Cron()
+	}
+	res = newStatusRes("OK")
+	return
+}
+
+func registerDebugCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "debug|advance-time", Func: simpleCmd((*SimpleProc).debugAdvanceTime), Arity: 3, Flags: CMD_ADMIN | CMD_NOSCRIPT | CMD_LOADING | CMD_STALE,
+			Group: GROUP_SERVER, Since: "1.0.0", Summary: "Moves the clock of server forward, for testing expiration."},
+	)
+}
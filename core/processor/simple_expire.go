@@ -0,0 +1,221 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Conditions of EXPIRE family
+const (
+	expireNX = 1 << iota // Set only if key has no expire time
+	expireXX             // Set only if key has expire time
+	expireGT             // Set only if new expire time is greater than current one
+	expireLT             // Set only if new expire time is less than current one
+)
+
+// EXPIRE : Set expire time of key in seconds
+func (proc *SimpleProc) EXPIRE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.expireGeneric(sess, req, "expire", 1000, false)
+}
+
+// PEXPIRE : Set expire time of key in milliseconds
+func (proc *SimpleProc) PEXPIRE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.expireGeneric(sess, req, "pexpire", 1, false)
+}
+
+// EXPIREAT : Set expire time of key as unix time in seconds
+func (proc *SimpleProc) EXPIREAT(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.expireGeneric(sess, req, "expireat", 1000, true)
+}
+
+// PEXPIREAT : Set expire time of key as unix time in milliseconds
+func (proc *SimpleProc) PEXPIREAT(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.expireGeneric(sess, req, "pexpireat", 1, true)
+}
+
+// expireGeneric : Set expire time of key, reply 1 if expire time is set or
+// key is deleted because the time has passed, 0 if key does not exist or
+// condition is not met
+func (proc *SimpleProc) expireGeneric(sess *Session, req *proto.Request, name string, unit int64, absolute bool) (res *proto.Response, err error) {
+	key := req.Params[0]
+	now := proc.nowMs()
+	at, res := parseExpireTime(req.Params[1], unit, absolute, now, name)
+	if nil != res {
+		return
+	}
+
+	flags := 0
+	for _, option := range req.Params[2:] {
+		switch strings.ToUpper(option) {
+		case "NX":
+			flags = flags | expireNX
+		case "XX":
+			flags = flags | expireXX
+		case "GT":
+			flags = flags | expireGT
+		case "LT":
+			flags = flags | expireLT
+		default:
+			res = proto.NewErrorRes("ERR Unsupported option " + option)
+			return
+		}
+	}
+	if (0 != flags&expireNX) && (0 != flags&(expireXX|expireGT|expireLT)) {
+		res = proto.NewErrorRes("ERR NX and XX, GT or LT options at the same time are not compatible")
+		return
+	}
+	if (0 != flags&expireGT) && (0 != flags&expireLT) {
+		res = proto.NewErrorRes("ERR GT and LT options at the same time are not compatible")
+		return
+	}
+
+	changed := false
+	proc.GetKeyspace(sess).Mutate(key, func(obj *store.Object) (*store.Object, error) {
+		if (nil == obj) || !checkExpireFlags(flags, obj.ExpireAt, at) {
+			return obj, nil
+		}
+		changed = true
+		if at <= now {
+			return nil, nil
+		}
+		obj.ExpireAt = at
+		return obj, nil
+	})
+	if !changed {
+		res = newIntRes(0)
+		return
+	}
+	if at <= now {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "del", key)
+	} else {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "expire", key)
+	}
+	res = newIntRes(1)
+	return
+}
+
+// checkExpireFlags : Whether new expire time can be set, key without expire
+// time is regarded as having an infinite one
+func checkExpireFlags(flags int, current int64, at int64) bool {
+	if (0 != flags&expireNX) && (0 != current) {
+		return false
+	}
+	if (0 != flags&expireXX) && (0 == current) {
+		return false
+	}
+	if (0 != flags&expireGT) && ((0 == current) || (at <= current)) {
+		return false
+	}
+	if (0 != flags&expireLT) && (0 != current) && (at >= current) {
+		return false
+	}
+	return true
+}
+
+// TTL : Remaining time to live of key in seconds
+func (proc *SimpleProc) TTL(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.ttlGeneric(sess, req, false, false)
+}
+
+// PTTL : Remaining time to live of key in milliseconds
+func (proc *SimpleProc) PTTL(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.ttlGeneric(sess, req, true, false)
+}
+
+// EXPIRETIME : Expire time of key as unix time in seconds
+func (proc *SimpleProc) EXPIRETIME(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.ttlGeneric(sess, req, false, true)
+}
+
+// PEXPIRETIME : Expire time of key as unix time in milliseconds
+func (proc *SimpleProc) PEXPIRETIME(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.ttlGeneric(sess, req, true, true)
+}
+
+// ttlGeneric : Reply -2 if key does not exist, -1 if key has no expire time
+func (proc *SimpleProc) ttlGeneric(sess *Session, req *proto.Request, inMs bool, absolute bool) (res *proto.Response, err error) {
+	at, ok := proc.GetKeyspace(sess).GetExpire(req.Params[0])
+	if !ok {
+		res = newIntRes(-2)
+		return
+	}
+	if 0 == at {
+		res = newIntRes(-1)
+		return
+	}
+
+	value := at
+	if !absolute {
+		value = at - proc.nowMs()
+		if value < 0 {
+			value = 0
+		}
+	}
+	if !inMs {
+		if absolute {
+			value = value / 1000
+		} else {
+			value = (value + 500) / 1000
+		}
+	}
+	res = newIntRes(int(value))
+	return
+}
+
+// PERSIST : Remove expire time of key
+func (proc *SimpleProc) PERSIST(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if proc.GetKeyspace(sess).Persist(req.Params[0]) {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "persist", req.Params[0])
+		res = newIntRes(1)
+	} else {
+		res = newIntRes(0)
+	}
+	return
+}
+
+// parseExpireTime : Parse expire time to unix time in milliseconds, error
+// response is returned if value is not an integer or overflows
+func parseExpireTime(str string, unit int64, absolute bool, now int64, name string) (int64, *proto.Response) {
+	value, err := strconv.ParseInt(str, 10, 64)
+	if nil != err {
+		return 0, proto.NewErrorRes(ERR_NOT_INTEGER)
+	}
+	invalid := proto.NewErrorRes("ERR invalid expire time in '" + name + "' command")
+	if (value > math.MaxInt64/unit) || (value < math.MinInt64/unit) {
+		return 0, invalid
+	}
+	value = value * unit
+	if !absolute {
+		if value > math.MaxInt64-now {
+			return 0, invalid
+		}
+		value = value + now
+	}
+	return value, nil
+}
+
+func registerExpireCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "expire", Func: simpleCmd((*SimpleProc).EXPIRE), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Sets the expiration time of a key in seconds."},
+		&Command{Name: "pexpire", Func: simpleCmd((*SimpleProc).PEXPIRE), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.6.0", Summary: "Sets the expiration time of a key in milliseconds."},
+		&Command{Name: "expireat", Func: simpleCmd((*SimpleProc).EXPIREAT), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.2.0", Summary: "Sets the expiration time of a key to a Unix timestamp."},
+		&Command{Name: "pexpireat", Func: simpleCmd((*SimpleProc).PEXPIREAT), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.6.0", Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp."},
+		&Command{Name: "ttl", Func: simpleCmd((*SimpleProc).TTL), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Returns the expiration time in seconds of a key."},
+		&Command{Name: "pttl", Func: simpleCmd((*SimpleProc).PTTL), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.6.0", Summary: "Returns the expiration time in milliseconds of a key."},
+		&Command{Name: "expiretime", Func: simpleCmd((*SimpleProc).EXPIRETIME), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "7.0.0", Summary: "Returns the expiration time of a key as a Unix timestamp."},
+		&Command{Name: "pexpiretime", Func: simpleCmd((*SimpleProc).PEXPIRETIME), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "7.0.0", Summary: "Returns the expiration time of a key as a Unix milliseconds timestamp."},
+		&Command{Name: "persist", Func: simpleCmd((*SimpleProc).PERSIST), Arity: 2, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.2.0", Summary: "Removes the expiration time of a key."},
+	)
+}
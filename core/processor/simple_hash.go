@@ -0,0 +1,570 @@
+package processor
+
+import (
+	"errors"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"gredissimulate/helper"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// viewHash : Call function with hash of key under read lock, hash is nil if
+// key does not exist
+func (proc *SimpleProc) viewHash(sess *Session, key string, function func(hash *store.Dict)) error {
+	return proc.GetKeyspace(sess).View(key, store.TYPE_HASH, func(value interface{}) error {
+		hash, _ := value.(*store.Dict)
+		function(hash)
+		return nil
+	})
+}
+
+// updateHash : Call function with hash of key under write lock, hash is
+// created if key does not exist and key is deleted if hash becomes empty
+func (proc *SimpleProc) updateHash(sess *Session, key string, function func(hash *store.Dict) error) error {
+	return proc.GetKeyspace(sess).Update(key, store.TYPE_HASH, func(value interface{}) (interface{}, error) {
+		hash, ok := value.(*store.Dict)
+		if !ok {
+			hash = store.NewDict()
+		}
+		err := function(hash)
+		if 0 == hash.Len() {
+			return nil, err
+		}
+		return hash, err
+	})
+}
+
+// HSET : Set fields of hash, reply count of fields created
+func (proc *SimpleProc) HSET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	createCount := 0
+	e := proc.updateHash(sess, req.Params[0], func(hash *store.Dict) error {
+		for i := 1; i < len(req.Params); i = i + 2 {
+			if hash.Set(req.Params[i], req.Params[i+1]) {
+				createCount++
+			}
+		}
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_HASH, "hset", req.Params[0])
+	res = newIntRes(createCount)
+	return
+}
+
+// HMSET : Set fields of hash, deprecated form of HSET
+func (proc *SimpleProc) HMSET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if res, err = proc.HSET(sess, req); proto.RES_TYPE_ERROR != res.Type {
+		res = newStatusRes("OK")
+	}
+	return
+}
+
+// HSETNX : Set field of hash only if it does not exist
+func (proc *SimpleProc) HSETNX(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	created := false
+	e := proc.updateHash(sess, req.Params[0], func(hash *store.Dict) error {
+		if _, ok := hash.Get(req.Params[1]); !ok {
+			created = hash.Set(req.Params[1], req.Params[2])
+		}
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if created {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_HASH, "hset", req.Params[0])
+	}
+	res = newBoolIntRes(created)
+	return
+}
+
+// HGET : Get value of hash field
+func (proc *SimpleProc) HGET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil != hash {
+			if v, ok := hash.Get(req.Params[1]); ok {
+				res.SetString(v.(string))
+			}
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// HMGET : Get values of hash fields, null for field not exist
+func (proc *SimpleProc) HMGET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		for _, field := range req.Params[1:] {
+			value := proto.NewNullRes(proto.RES_TYPE_BULK)
+			if nil != hash {
+				if v, ok := hash.Get(field); ok {
+					value.SetString(v.(string))
+				}
+			}
+			res.SetResponse(value)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// HGETALL : Get all fields and values of hash
+func (proc *SimpleProc) HGETALL(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.hashItems(sess, req, true, true)
+}
+
+// HKEYS : Get all fields of hash
+func (proc *SimpleProc) HKEYS(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.hashItems(sess, req, true, false)
+}
+
+// HVALS : Get all values of hash
+func (proc *SimpleProc) HVALS(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.hashItems(sess, req, false, true)
+}
+
+func (proc *SimpleProc) hashItems(sess *Session, req *proto.Request, withFields bool, withValues bool) (res *proto.Response, err error) {
+	// Flatten fields to a slice, response is encoded after the lock of
+	// keyspace is released so hash can not be streamed directly. Items share
+	// memory with the hash, only headers of strings are copied
+	items := []string{}
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil == hash {
+			return
+		}
+		items = make([]string, 0, hash.Len()*2)
+		hash.Range(func(field string, v interface{}) bool {
+			if withFields {
+				items = append(items, field)
+			}
+			if withValues {
+				items = append(items, v.(string))
+			}
+			return true
+		})
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if withFields && withValues {
+		res = proto.NewBulkListRes(proto.RES_TYPE_MAP, items)
+	} else {
+		res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, items)
+	}
+	return
+}
+
+// HDEL : Delete fields of hash, reply count of fields deleted
+func (proc *SimpleProc) HDEL(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count := 0
+	deleted := false
+	e := proc.GetKeyspace(sess).Update(req.Params[0], store.TYPE_HASH, func(value interface{}) (interface{}, error) {
+		hash, ok := value.(*store.Dict)
+		if !ok {
+			return nil, nil
+		}
+		for _, field := range req.Params[1:] {
+			if hash.Delete(field) {
+				count++
+			}
+		}
+		if 0 == hash.Len() {
+			deleted = true
+			return nil, nil
+		}
+		return hash, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_HASH, "hdel", req.Params[0], deleted)
+	}
+	res = newIntRes(count)
+	return
+}
+
+// HEXISTS : Whether field exists in hash
+func (proc *SimpleProc) HEXISTS(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	exists := false
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil != hash {
+			_, exists = hash.Get(req.Params[1])
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newBoolIntRes(exists)
+	return
+}
+
+// HLEN : Count of fields of hash
+func (proc *SimpleProc) HLEN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil != hash {
+			length = hash.Len()
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(length)
+	return
+}
+
+// HSTRLEN : Length of value of hash field
+func (proc *SimpleProc) HSTRLEN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil != hash {
+			if v, ok := hash.Get(req.Params[1]); ok {
+				length = len(v.(string))
+			}
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(length)
+	return
+}
+
+// HINCRBY : Increase integer value of hash field by increment
+func (proc *SimpleProc) HINCRBY(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	incr, ok := parseInt(req.Params[2])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+
+	var result int64
+	e := proc.updateHash(sess, req.Params[0], func(hash *store.Dict) error {
+		var old int64
+		if v, ok := hash.Get(req.Params[1]); ok {
+			if old, ok = parseInt(v.(string)); !ok {
+				return errors.New("ERR hash value is not an integer")
+			}
+		}
+		if ((incr < 0) && (old < 0) && (incr < math.MinInt64-old)) ||
+			((incr > 0) && (old > 0) && (incr > math.MaxInt64-old)) {
+			return errors.New("ERR increment or decrement would overflow")
+		}
+		result = old + incr
+		hash.Set(req.Params[1], strconv.FormatInt(result, 10))
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_HASH, "hincrby", req.Params[0])
+	res = proto.NewResponse(proto.RES_TYPE_INT)
+	res.SetString(strconv.FormatInt(result, 10))
+	return
+}
+
+// HINCRBYFLOAT : Increase float value of hash field by increment
+func (proc *SimpleProc) HINCRBYFLOAT(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	incr, ok := parseFloat(req.Params[2])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_FLOAT)
+		return
+	}
+
+	var result string
+	e := proc.updateHash(sess, req.Params[0], func(hash *store.Dict) error {
+		var old float64
+		if v, ok := hash.Get(req.Params[1]); ok {
+			if old, ok = parseFloat(v.(string)); !ok {
+				return errors.New("ERR hash value is not a float")
+			}
+		}
+		sum := old + incr
+		if math.IsNaN(sum) || math.IsInf(sum, 0) {
+			return errors.New("ERR increment would produce NaN or Infinity")
+		}
+		result = strconv.FormatFloat(sum, 'f', -1, 64)
+		hash.Set(req.Params[1], result)
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_HASH, "hincrbyfloat", req.Params[0])
+	res = newBulkRes(result)
+	return
+}
+
+// HRANDFIELD : Get random fields of hash, count > 0 replies distinct fields
+// and count < 0 allows the same field multiple times
+func (proc *SimpleProc) HRANDFIELD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if 1 == len(req.Params) {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+			if nil != hash {
+				field, _, _ := hash.RandomKey()
+				res.SetString(field)
+			}
+		})
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+		}
+		return
+	}
+
+	count, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	withValues := false
+	if 3 == len(req.Params) {
+		if "WITHVALUES" != strings.ToUpper(req.Params[2]) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		withValues = true
+	}
+	if (count < -math.MaxInt64/2) || (withValues && (count < -math.MaxInt64/4)) {
+		res = proto.NewErrorRes("ERR value is out of range")
+		return
+	}
+
+	fields := []string{}
+	values := []string{}
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil == hash {
+			return
+		}
+		fields = randomDictKeys(hash, count)
+		if withValues {
+			values = make([]string, len(fields))
+			for i, field := range fields {
+				v, _ := hash.Get(field)
+				values[i] = v.(string)
+			}
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for i, field := range fields {
+		if !withValues {
+			res.SetResponse(newBulkRes(field))
+		} else if proto.PROTO_RESP3 == sess.ProtoVer {
+			res.SetResponse(proto.NewBulkListRes(proto.RES_TYPE_MULTI, []string{field, values[i]}))
+		} else {
+			res.SetResponse(newBulkRes(field))
+			res.SetResponse(newBulkRes(values[i]))
+		}
+	}
+	return
+}
+
+// randomDictKeys : Pick random keys of dict, keys are distinct if count is
+// positive
+func randomDictKeys(dict *store.Dict, count int64) (keys []string) {
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			key, _, _ := dict.RandomKey()
+			keys = append(keys, key)
+		}
+		return
+	}
+
+	if count >= int64(dict.Len()) {
+		return dictKeys(dict)
+	}
+	if count*3 > int64(dict.Len()) {
+		// Count is close to size, shuffle all keys and take the head
+		keys = dictKeys(dict)
+		rand.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+		return keys[:count]
+	}
+	picked := make(map[string]bool)
+	for int64(len(keys)) < count {
+		key, _, _ := dict.RandomKey()
+		if !picked[key] {
+			picked[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+// dictKeys : All keys of dict
+func dictKeys(dict *store.Dict) []string {
+	keys := make([]string, 0, dict.Len())
+	dict.Range(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// HSCAN : Iterate fields and values of hash by cursor
+func (proc *SimpleProc) HSCAN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	cursor, opts, res := parseScanArgs(req.Params[1:], false)
+	if nil != res {
+		return
+	}
+
+	items := []string{}
+	e := proc.viewHash(sess, req.Params[0], func(hash *store.Dict) {
+		if nil == hash {
+			cursor = 0
+			return
+		}
+		cursor, items = scanDict(hash, cursor, opts, func(value interface{}) string {
+			return value.(string)
+		})
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newScanRes(cursor, items)
+	return
+}
+
+// scanOptions : Options of SCAN family
+type scanOptions struct {
+	pattern string // Glob-style pattern, empty if MATCH is not given
+	count   int64  // Hint of count of items replied by each call
+	typ     string // Type of keys of SCAN, empty if TYPE is not given
+}
+
+// parseScanArgs : Parse cursor and options of SCAN family, option TYPE is
+// allowed only for SCAN
+func parseScanArgs(params []string, allowType bool) (cursor uint64, opts scanOptions, res *proto.Response) {
+	cursor, e := strconv.ParseUint(params[0], 10, 64)
+	if nil != e {
+		res = proto.NewErrorRes("ERR invalid cursor")
+		return
+	}
+	opts.count = 10
+	for i := 1; i < len(params); i++ {
+		option := strings.ToUpper(params[i])
+		if (i+1 >= len(params)) || (("TYPE" == option) && !allowType) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		switch option {
+		case "MATCH":
+			opts.pattern = params[i+1]
+		case "COUNT":
+			var ok bool
+			if opts.count, ok = parseInt(params[i+1]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if opts.count < 1 {
+				res = proto.NewErrorRes(ERR_SYNTAX)
+				return
+			}
+		case "TYPE":
+			opts.typ = params[i+1]
+		default:
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		i++
+	}
+	return
+}
+
+// scanDict : Scan dict from cursor until count keys are collected, keys
+// not matching pattern are filtered after they are scanned, values formatted
+// by format follow their keys in items if format is not nil
+func scanDict(dict *store.Dict, cursor uint64, opts scanOptions, format func(value interface{}) string) (uint64, []string) {
+	items := []string{}
+	found := int64(0)
+	maxIterations := opts.count * 10
+	for {
+		cursor = dict.Scan(cursor, func(key string, value interface{}) {
+			if ("" != opts.pattern) && !helper.GlobMatch(opts.pattern, key, false) {
+				return
+			}
+			items = append(items, key)
+			if nil != format {
+				items = append(items, format(value))
+			}
+			found++
+		})
+		maxIterations--
+		if (0 == cursor) || (maxIterations <= 0) || (found >= opts.count) {
+			break
+		}
+	}
+	return cursor, items
+}
+
+func newScanRes(cursor uint64, items []string) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newBulkRes(strconv.FormatUint(cursor, 10)))
+	res.SetResponse(proto.NewBulkListRes(proto.RES_TYPE_MULTI, items))
+	return res
+}
+
+func registerHashCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "hset", Func: simpleCmd((*SimpleProc).HSET), Arity: -4, CheckArgs: pairsFrom(1), Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Creates or modifies the value of a field in a hash."},
+		&Command{Name: "hmset", Func: simpleCmd((*SimpleProc).HMSET), Arity: -4, CheckArgs: pairsFrom(1), Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Sets the values of multiple fields."},
+		&Command{Name: "hsetnx", Func: simpleCmd((*SimpleProc).HSETNX), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Sets the value of a field in a hash only when the field doesn't exist."},
+		&Command{Name: "hget", Func: simpleCmd((*SimpleProc).HGET), Arity: 3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Returns the value of a field in a hash."},
+		&Command{Name: "hmget", Func: simpleCmd((*SimpleProc).HMGET), Arity: -3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Returns the values of all fields in a hash."},
+		&Command{Name: "hgetall", Func: simpleCmd((*SimpleProc).HGETALL), Arity: 2, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Returns all fields and values in a hash."},
+		&Command{Name: "hkeys", Func: simpleCmd((*SimpleProc).HKEYS), Arity: 2, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Returns all fields in a hash."},
+		&Command{Name: "hvals", Func: simpleCmd((*SimpleProc).HVALS), Arity: 2, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Returns all values in a hash."},
+		&Command{Name: "hdel", Func: simpleCmd((*SimpleProc).HDEL), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain."},
+		&Command{Name: "hexists", Func: simpleCmd((*SimpleProc).HEXISTS), Arity: 3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Determines whether a field exists in a hash."},
+		&Command{Name: "hlen", Func: simpleCmd((*SimpleProc).HLEN), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Returns the number of fields in a hash."},
+		&Command{Name: "hstrlen", Func: simpleCmd((*SimpleProc).HSTRLEN), Arity: 3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "3.2.0", Summary: "Returns the length of the value of a field."},
+		&Command{Name: "hincrby", Func: simpleCmd((*SimpleProc).HINCRBY), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.0.0", Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist."},
+		&Command{Name: "hincrbyfloat", Func: simpleCmd((*SimpleProc).HINCRBYFLOAT), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.6.0", Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist."},
+		&Command{Name: "hrandfield", Func: simpleCmd((*SimpleProc).HRANDFIELD), Arity: -2, CheckArgs: maxArgs(3), Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "6.2.0", Summary: "Returns one or more random fields from a hash."},
+		&Command{Name: "hscan", Func: simpleCmd((*SimpleProc).HSCAN), Arity: -3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_HASH, Since: "2.8.0", Summary: "Iterates over fields and values of a hash."},
+	)
+}
@@ -0,0 +1,402 @@
+package processor
+
+import (
+	"errors"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"gredissimulate/helper"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Limits of compact encodings replied by OBJECT ENCODING, same as defaults
+// of redis configuration
+const (
+	ENCODING_EMBSTR_SIZE      = 44   // Max length of embstr string
+	ENCODING_LISTPACK_ENTRIES = 128  // Max entries of listpack hash, set and zset
+	ENCODING_LISTPACK_VALUE   = 64   // Max length of value in listpack hash, set and zset
+	ENCODING_LISTPACK_SIZE    = 8192 // Max bytes of listpack list
+	ENCODING_INTSET_ENTRIES   = 512  // Max entries of intset
+	OBJ_SHARED_INTEGERS       = 10000
+	OBJ_SHARED_REFCOUNT       = math.MaxInt32
+)
+
+// DEL : Delete keys, reply count of deleted keys
+func (proc *SimpleProc) DEL(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	ks := proc.GetKeyspace(sess)
+	count := 0
+	for _, key := range req.Params {
+		if ks.Delete(key) > 0 {
+			proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "del", key)
+			count++
+		}
+	}
+	res = newIntRes(count)
+	return
+}
+
+// UNLINK : Same as DEL, memory is always reclaimed by go runtime
+func (proc *SimpleProc) UNLINK(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.DEL(sess, req)
+}
+
+// EXISTS : Count of existing keys, key given multiple times is counted
+// multiple times
+func (proc *SimpleProc) EXISTS(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	ks := proc.GetKeyspace(sess)
+	count := 0
+	for _, key := range req.Params {
+		if ks.Exists(key) {
+			count++
+		}
+	}
+	res = newIntRes(count)
+	return
+}
+
+// TYPE : Type of value stored at key, none if key does not exist
+func (proc *SimpleProc) TYPE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = newStatusRes(proc.GetKeyspace(sess).Type(req.Params[0]))
+	return
+}
+
+// KEYS : All keys matching glob pattern
+func (proc *SimpleProc) KEYS(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	pattern := req.Params[0]
+	all := "*" == pattern
+	keys := []string{}
+	proc.GetKeyspace(sess).Range(func(key string, t string) bool {
+		if all || helper.GlobMatch(pattern, key, false) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, keys)
+	return
+}
+
+// SCAN : Iterate keys of database by cursor, keys not matching pattern or
+// type are filtered after they are scanned
+func (proc *SimpleProc) SCAN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	cursor, opts, res := parseScanArgs(req.Params, true)
+	if nil != res {
+		return
+	}
+	keys := []string{}
+	cursor = proc.GetKeyspace(sess).Scan(cursor, opts.count, func(key string, t string) {
+		if ("" != opts.typ) && !strings.EqualFold(opts.typ, t) {
+			return
+		}
+		if ("" != opts.pattern) && !helper.GlobMatch(opts.pattern, key, false) {
+			return
+		}
+		keys = append(keys, key)
+	})
+	res = newScanRes(cursor, keys)
+	return
+}
+
+// RANDOMKEY : A random key, null if database is empty
+func (proc *SimpleProc) RANDOMKEY(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = newNullableBulkRes(proc.GetKeyspace(sess).RandomKey())
+	return
+}
+
+// RENAME : Rename key, destination is overwritten
+func (proc *SimpleProc) RENAME(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.renameGeneric(sess, req, false)
+}
+
+// RENAMENX : Rename key only if destination does not exist
+func (proc *SimpleProc) RENAMENX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.renameGeneric(sess, req, true)
+}
+
+// renameGeneric : Move object of source key to destination key with its
+// expire time, RENAME replies OK and RENAMENX replies 1 if renamed
+func (proc *SimpleProc) renameGeneric(sess *Session, req *proto.Request, nx bool) (res *proto.Response, err error) {
+	src, dst := req.Params[0], req.Params[1]
+	renamed := false
+	e := proc.GetKeyspace(sess).MutateMulti([]string{src, dst}, func(objs []*store.Object) ([]*store.Object, error) {
+		if nil == objs[0] {
+			return nil, errors.New("ERR no such key")
+		}
+		if nx && (nil != objs[1]) {
+			return objs, nil
+		}
+		if src != dst {
+			objs[0], objs[1] = nil, objs[0]
+		}
+		renamed = true
+		return objs, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if renamed && (src != dst) {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "rename_from", src)
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "rename_to", dst)
+		proc.signalKey(sess, dst)
+	}
+	if !nx {
+		res = newStatusRes("OK")
+		return
+	}
+	res = newBoolIntRes(renamed)
+	return
+}
+
+// COPY : Copy value of source key to destination key, which may be in other
+// database, reply 1 if copied
+func (proc *SimpleProc) COPY(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	src, dst := req.Params[0], req.Params[1]
+	db := sess.DB
+	replace := false
+	for i := 2; i < len(req.Params); i++ {
+		switch strings.ToUpper(req.Params[i]) {
+		case "DB":
+			if i+1 >= len(req.Params) {
+				res = proto.NewErrorRes(ERR_SYNTAX)
+				return
+			}
+			index, e := strconv.Atoi(req.Params[i+1])
+			if nil != e {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			db = index
+			i++
+		case "REPLACE":
+			replace = true
+		default:
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+	if !proc.databases.IsValid(db) {
+		res = proto.NewErrorRes("ERR DB index is out of range")
+		return
+	}
+	if (db == sess.DB) && (src == dst) {
+		res = proto.NewErrorRes("ERR source and destination objects are the same")
+		return
+	}
+
+	var obj *store.Object
+	proc.GetKeyspace(sess).Peek(src, func(o *store.Object) {
+		if nil != o {
+			obj = o.Copy()
+		}
+	})
+	if (nil == obj) || !proc.databases.Get(db).Put(dst, obj, !replace) {
+		res = newIntRes(0)
+		return
+	}
+	proc.databases.Notify(store.NOTIFY_GENERIC, "copy_to", db, dst)
+	proc.GetDatabases().GetBlocking().Signal(db, dst)
+	res = newIntRes(1)
+	return
+}
+
+// TOUCH : Update access time of keys, reply count of existing keys
+func (proc *SimpleProc) TOUCH(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	ks := proc.GetKeyspace(sess)
+	count := 0
+	for _, key := range req.Params {
+		if ks.Touch(key) {
+			count++
+		}
+	}
+	res = newIntRes(count)
+	return
+}
+
+// objectEncoding : OBJECT ENCODING, internal encoding redis would use for
+// value of key
+func (proc *SimpleProc) objectEncoding(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.objectGeneric(sess, req, func(obj *store.Object) *proto.Response {
+		return newBulkRes(objectEncoding(obj))
+	})
+}
+
+// objectRefcount : OBJECT REFCOUNT, small integers are shared objects
+func (proc *SimpleProc) objectRefcount(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.objectGeneric(sess, req, func(obj *store.Object) *proto.Response {
+		if str, ok := obj.Value.(string); ok {
+			if n, ok := parseInt(str); ok && (n >= 0) && (n < OBJ_SHARED_INTEGERS) {
+				return newIntRes(OBJ_SHARED_REFCOUNT)
+			}
+		}
+		return newIntRes(1)
+	})
+}
+
+// objectIdletime : OBJECT IDLETIME, seconds since key was accessed last time
+func (proc *SimpleProc) objectIdletime(sess *Session, req *proto.Request) (*proto.Response, error) {
+	now := proc.nowMs()
+	return proc.objectGeneric(sess, req, func(obj *store.Object) *proto.Response {
+		return newIntRes(int(obj.IdleTime(now) / 1000))
+	})
+}
+
+// objectFreq : OBJECT FREQ, logarithmic access frequency counter of key
+func (proc *SimpleProc) objectFreq(sess *Session, req *proto.Request) (*proto.Response, error) {
+	now := proc.nowMs()
+	return proc.objectGeneric(sess, req, func(obj *store.Object) *proto.Response {
+		return newIntRes(obj.Freq(now))
+	})
+}
+
+// objectHelp : OBJECT HELP
+func (proc *SimpleProc) objectHelp(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	lines := []string{
+		"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"ENCODING <key>",
+		"    Return the kind of internal representation used in order to store the value",
+		"    associated with a <key>.",
+		"FREQ <key>",
+		"    Return the access frequency index of the <key>. The returned integer is",
+		"    proportional to the logarithm of the recent access frequency of the key.",
+		"IDLETIME <key>",
+		"    Return the idle time of the <key>, that is the approximated number of",
+		"    seconds elapsed since the last access to the key.",
+		"REFCOUNT <key>",
+		"    Return the number of references of the value associated with the specified",
+		"    <key>.",
+		"HELP",
+		"    Print this help.",
+	}
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, line := range lines {
+		r := proto.NewResponse(proto.RES_TYPE_STATE)
+		r.SetString(line)
+		res.SetResponse(r)
+	}
+	return
+}
+
+// objectGeneric : Reply of OBJECT subcommand on object of key, null if key
+// does not exist, access time of key is not updated
+func (proc *SimpleProc) objectGeneric(sess *Session, req *proto.Request, function func(obj *store.Object) *proto.Response) (res *proto.Response, err error) {
+	proc.GetKeyspace(sess).Peek(req.Params[1], func(obj *store.Object) {
+		if nil != obj {
+			res = function(obj)
+		}
+	})
+	if nil == res {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	}
+	return
+}
+
+// objectEncoding : Encoding of object by limits of compact encodings, value
+// never converts back to compact encoding in redis, which is ignored here
+func objectEncoding(obj *store.Object) string {
+	switch v := obj.Value.(type) {
+	case string:
+		if _, ok := parseInt(v); ok {
+			return "int"
+		}
+		if len(v) <= ENCODING_EMBSTR_SIZE {
+			return "embstr"
+		}
+		return "raw"
+	case *store.List:
+		size := 0
+		for _, value := range v.Values() {
+			size += len(value)
+		}
+		if size <= ENCODING_LISTPACK_SIZE {
+			return "listpack"
+		}
+		return "quicklist"
+	case *store.Dict:
+		if store.TYPE_SET == obj.Type {
+			return setEncoding(v)
+		}
+		if isListpackDict(v, true) {
+			return "listpack"
+		}
+		return "hashtable"
+	case *store.ZSet:
+		if isListpackDict(v.Dict(), false) {
+			return "listpack"
+		}
+		return "skiplist"
+	}
+	return obj.Type
+}
+
+// setEncoding : Encoding of set, intset if all members are integers
+func setEncoding(set *store.Dict) string {
+	intset := set.Len() <= ENCODING_INTSET_ENTRIES
+	if intset {
+		set.Range(func(member string, v interface{}) bool {
+			_, intset = parseInt(member)
+			return intset
+		})
+	}
+	if intset {
+		return "intset"
+	}
+	if isListpackDict(set, false) {
+		return "listpack"
+	}
+	return "hashtable"
+}
+
+// isListpackDict : Whether dict is small enough for listpack, values are
+// checked for hash
+func isListpackDict(d *store.Dict, withValues bool) bool {
+	if d.Len() > ENCODING_LISTPACK_ENTRIES {
+		return false
+	}
+	ok := true
+	d.Range(func(key string, v interface{}) bool {
+		ok = len(key) <= ENCODING_LISTPACK_VALUE
+		if ok && withValues {
+			ok = len(v.(string)) <= ENCODING_LISTPACK_VALUE
+		}
+		return ok
+	})
+	return ok
+}
+
+func registerKeysCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "del", Func: simpleCmd((*SimpleProc).DEL), Arity: -2, Flags: CMD_WRITE, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Deletes one or more keys."},
+		&Command{Name: "unlink", Func: simpleCmd((*SimpleProc).UNLINK), Arity: -2, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "4.0.0", Summary: "Asynchronously deletes one or more keys."},
+		&Command{Name: "exists", Func: simpleCmd((*SimpleProc).EXISTS), Arity: -2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Determines whether one or more keys exist."},
+		&Command{Name: "type", Func: simpleCmd((*SimpleProc).TYPE), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Determines the type of value stored at a key."},
+		&Command{Name: "keys", Func: simpleCmd((*SimpleProc).KEYS), Arity: 2, Flags: CMD_READONLY,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Returns all key names that match a pattern."},
+		&Command{Name: "scan", Func: simpleCmd((*SimpleProc).SCAN), Arity: -2, Flags: CMD_READONLY,
+			Group: GROUP_GENERIC, Since: "2.8.0", Summary: "Iterates over the key names in the database."},
+		&Command{Name: "randomkey", Func: simpleCmd((*SimpleProc).RANDOMKEY), Arity: 1, Flags: CMD_READONLY,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Returns a random key name from the database."},
+		&Command{Name: "rename", Func: simpleCmd((*SimpleProc).RENAME), Arity: 3, Flags: CMD_WRITE, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Renames a key and overwrites the destination."},
+		&Command{Name: "renamenx", Func: simpleCmd((*SimpleProc).RENAMENX), Arity: 3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "1.0.0", Summary: "Renames a key only when the target key name doesn't exist."},
+		&Command{Name: "copy", Func: simpleCmd((*SimpleProc).COPY), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "6.2.0", Summary: "Copies the value of a key to a new key."},
+		&Command{Name: "touch", Func: simpleCmd((*SimpleProc).TOUCH), Arity: -2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "3.2.1", Summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed."},
+		&Command{Name: "object|encoding", Func: simpleCmd((*SimpleProc).objectEncoding), Arity: 3, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.2.3", Summary: "Returns the internal encoding of a Redis object."},
+		&Command{Name: "object|refcount", Func: simpleCmd((*SimpleProc).objectRefcount), Arity: 3, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.2.3", Summary: "Returns the reference count of a value of a key."},
+		&Command{Name: "object|idletime", Func: simpleCmd((*SimpleProc).objectIdletime), Arity: 3, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "2.2.3", Summary: "Returns the time since the last access to a Redis object."},
+		&Command{Name: "object|freq", Func: simpleCmd((*SimpleProc).objectFreq), Arity: 3, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_GENERIC, Since: "4.0.0", Summary: "Returns the logarithmic access frequency counter of a Redis object."},
+		&Command{Name: "object|help", Func: simpleCmd((*SimpleProc).objectHelp), Arity: 2, Flags: CMD_LOADING | CMD_STALE,
+			Group: GROUP_GENERIC, Since: "6.2.0", Summary: "Returns helpful text about the different subcommands."},
+	)
+}
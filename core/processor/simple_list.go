@@ -0,0 +1,902 @@
+package processor
+
+import (
+	"errors"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// viewList : Call function with list of key under read lock, list is nil if
+// key does not exist
+func (proc *SimpleProc) viewList(sess *Session, key string, function func(list *store.List)) error {
+	return proc.GetKeyspace(sess).View(key, store.TYPE_LIST, func(value interface{}) error {
+		list, _ := value.(*store.List)
+		function(list)
+		return nil
+	})
+}
+
+// updateList : Call function with list of key under write lock, list is nil
+// if key does not exist and key is deleted if list becomes empty
+func (proc *SimpleProc) updateList(sess *Session, key string, function func(list *store.List) (*store.List, error)) error {
+	return proc.GetKeyspace(sess).Update(key, store.TYPE_LIST, func(value interface{}) (interface{}, error) {
+		list, _ := value.(*store.List)
+		list, err := function(list)
+		if (nil == list) || (0 == list.Len()) {
+			return nil, err
+		}
+		return list, err
+	})
+}
+
+// signalKey : Key may be ready for clients blocked on it
+func (proc *SimpleProc) signalKey(sess *Session, key string) {
+	proc.GetDatabases().GetBlocking().Signal(sess.DB, key)
+}
+
+// listEvent : Event of pushing or popping at one end of list
+func listEvent(left bool, push bool) string {
+	if left && push {
+		return "lpush"
+	} else if push {
+		return "rpush"
+	} else if left {
+		return "lpop"
+	}
+	return "rpop"
+}
+
+// LPUSH : Insert values at head of list, reply length of list
+func (proc *SimpleProc) LPUSH(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.pushGeneric(sess, req, true, false)
+}
+
+// RPUSH : Insert values at tail of list, reply length of list
+func (proc *SimpleProc) RPUSH(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.pushGeneric(sess, req, false, false)
+}
+
+// LPUSHX : Insert values at head of list only if list exists
+func (proc *SimpleProc) LPUSHX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.pushGeneric(sess, req, true, true)
+}
+
+// RPUSHX : Insert values at tail of list only if list exists
+func (proc *SimpleProc) RPUSHX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.pushGeneric(sess, req, false, true)
+}
+
+func (proc *SimpleProc) pushGeneric(sess *Session, req *proto.Request, left bool, xx bool) (res *proto.Response, err error) {
+	length := 0
+	e := proc.updateList(sess, req.Params[0], func(list *store.List) (*store.List, error) {
+		if nil == list {
+			if xx {
+				return nil, nil
+			}
+			list = store.NewList()
+		}
+		for _, value := range req.Params[1:] {
+			listPush(list, left, value)
+		}
+		length = list.Len()
+		return list, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if length > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_LIST, listEvent(left, true), req.Params[0])
+		proc.signalKey(sess, req.Params[0])
+	}
+	res = newIntRes(length)
+	return
+}
+
+// LPOP : Remove and get values at head of list
+func (proc *SimpleProc) LPOP(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.popGeneric(sess, req, true)
+}
+
+// RPOP : Remove and get values at tail of list
+func (proc *SimpleProc) RPOP(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.popGeneric(sess, req, false)
+}
+
+func (proc *SimpleProc) popGeneric(sess *Session, req *proto.Request, left bool) (res *proto.Response, err error) {
+	count := int64(1)
+	if 2 == len(req.Params) {
+		var ok bool
+		if count, ok = parseInt(req.Params[1]); !ok || (count < 0) {
+			res = proto.NewErrorRes("ERR value is out of range, must be positive")
+			return
+		}
+	}
+
+	// Stored lists are never empty, so nothing popped means key does not
+	// exist unless count is 0
+	exists := false
+	var values []string
+	var e error
+	if 0 == count {
+		e = proc.viewList(sess, req.Params[0], func(list *store.List) {
+			exists = nil != list
+		})
+	} else {
+		values, e = proc.popList(sess, req.Params[0], left, count)
+		exists = len(values) > 0
+	}
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if 1 == len(req.Params) {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		if exists {
+			res.SetString(values[0])
+		}
+	} else if !exists {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	} else {
+		res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, values)
+	}
+	return
+}
+
+// popList : Pop at most count values from one end of list of key
+func (proc *SimpleProc) popList(sess *Session, key string, left bool, count int64) (values []string, err error) {
+	values = []string{}
+	deleted := false
+	err = proc.updateList(sess, key, func(list *store.List) (*store.List, error) {
+		if nil == list {
+			return nil, nil
+		}
+		for (int64(len(values)) < count) && (list.Len() > 0) {
+			values = append(values, listPop(list, left))
+		}
+		deleted = 0 == list.Len()
+		return list, nil
+	})
+	if len(values) > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_LIST, listEvent(left, false), key, deleted)
+	}
+	return
+}
+
+// LLEN : Length of list
+func (proc *SimpleProc) LLEN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.viewList(sess, req.Params[0], func(list *store.List) {
+		if nil != list {
+			length = list.Len()
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(length)
+	return
+}
+
+// LINDEX : Get value of list at index, negative index is counted from tail
+func (proc *SimpleProc) LINDEX(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	index, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+
+	res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	e := proc.viewList(sess, req.Params[0], func(list *store.List) {
+		if nil == list {
+			return
+		}
+		if i, ok := listIndex(list, index); ok {
+			res.SetString(list.Index(i))
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// LSET : Set value of list at index
+func (proc *SimpleProc) LSET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	index, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+
+	e := proc.updateList(sess, req.Params[0], func(list *store.List) (*store.List, error) {
+		if nil == list {
+			return nil, errors.New("ERR no such key")
+		}
+		i, ok := listIndex(list, index)
+		if !ok {
+			return list, errors.New("ERR index out of range")
+		}
+		list.Set(i, req.Params[2])
+		return list, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_LIST, "lset", req.Params[0])
+	res = newStatusRes("OK")
+	return
+}
+
+// LRANGE : Get values of list from start to stop inclusive
+func (proc *SimpleProc) LRANGE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	start, ok1 := parseInt(req.Params[1])
+	stop, ok2 := parseInt(req.Params[2])
+	if !ok1 || !ok2 {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+
+	values := []string{}
+	e := proc.viewList(sess, req.Params[0], func(list *store.List) {
+		if nil == list {
+			return
+		}
+		if first, last, ok := listRange(list, start, stop); ok {
+			values = list.Range(first, last)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, values)
+	return
+}
+
+// LTRIM : Keep only values of list from start to stop inclusive
+func (proc *SimpleProc) LTRIM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	start, ok1 := parseInt(req.Params[1])
+	stop, ok2 := parseInt(req.Params[2])
+	if !ok1 || !ok2 {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+
+	exists := false
+	deleted := false
+	e := proc.updateList(sess, req.Params[0], func(list *store.List) (*store.List, error) {
+		if nil == list {
+			return nil, nil
+		}
+		exists = true
+		first, last, ok := listRange(list, start, stop)
+		if !ok {
+			deleted = true
+			return nil, nil
+		}
+		for i := list.Len() - 1; i > last; i-- {
+			list.PopBack()
+		}
+		for i := 0; i < first; i++ {
+			list.PopFront()
+		}
+		return list, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if exists {
+		proc.notifyChanged(sess, store.NOTIFY_LIST, "ltrim", req.Params[0], deleted)
+	}
+	res = newStatusRes("OK")
+	return
+}
+
+// LINSERT : Insert value before or after pivot of list, reply length of list,
+// -1 if pivot is not found and 0 if list does not exist
+func (proc *SimpleProc) LINSERT(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	where := strings.ToUpper(req.Params[1])
+	if ("BEFORE" != where) && ("AFTER" != where) {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+
+	length := 0
+	e := proc.updateList(sess, req.Params[0], func(list *store.List) (*store.List, error) {
+		if nil == list {
+			return nil, nil
+		}
+		values := list.Values()
+		length = -1
+		for i, value := range values {
+			if value != req.Params[2] {
+				continue
+			}
+			if "AFTER" == where {
+				i++
+			}
+			values = append(values, "")
+			copy(values[i+1:], values[i:])
+			values[i] = req.Params[3]
+			list.Reset(values)
+			length = list.Len()
+			break
+		}
+		return list, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if length > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_LIST, "linsert", req.Params[0])
+	}
+	res = newIntRes(length)
+	return
+}
+
+// LREM : Remove count occurrences of value from list, from head if count is
+// positive, from tail if count is negative and all if count is 0
+func (proc *SimpleProc) LREM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+
+	limit := count
+	if count < 0 {
+		limit = -count
+	}
+	removed := int64(0)
+	deleted := false
+	e := proc.updateList(sess, req.Params[0], func(list *store.List) (*store.List, error) {
+		if nil == list {
+			return nil, nil
+		}
+		values := list.Values()
+		kept := make([]string, 0, len(values))
+		for i := range values {
+			// Walk from tail for negative count, kept values are reversed back
+			j := i
+			if count < 0 {
+				j = len(values) - 1 - i
+			}
+			if (values[j] == req.Params[2]) && ((0 == limit) || (removed < limit)) {
+				removed++
+			} else {
+				kept = append(kept, values[j])
+			}
+		}
+		if count < 0 {
+			for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+				kept[i], kept[j] = kept[j], kept[i]
+			}
+		}
+		if removed > 0 {
+			list.Reset(kept)
+			deleted = 0 == len(kept)
+		}
+		return list, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if removed > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_LIST, "lrem", req.Params[0], deleted)
+	}
+	res = newIntRes(int(removed))
+	return
+}
+
+// LPOS : Get indexes of value in list, options RANK, COUNT and MAXLEN select
+// the first match, count of matches and count of values compared
+func (proc *SimpleProc) LPOS(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	params := req.Params[2:]
+	for i := 0; i < len(params); i++ {
+		option := strings.ToUpper(params[i])
+		if i+1 >= len(params) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		var ok bool
+		switch option {
+		case "RANK":
+			if rank, ok = parseInt(params[i+1]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if math.MinInt64 == rank {
+				res = proto.NewErrorRes("ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807")
+				return
+			}
+			if 0 == rank {
+				res = proto.NewErrorRes("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+				return
+			}
+		case "COUNT":
+			if count, ok = parseInt(params[i+1]); !ok || (count < 0) {
+				res = proto.NewErrorRes("ERR COUNT can't be negative")
+				return
+			}
+		case "MAXLEN":
+			if maxLen, ok = parseInt(params[i+1]); !ok || (maxLen < 0) {
+				res = proto.NewErrorRes("ERR MAXLEN can't be negative")
+				return
+			}
+		default:
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		i++
+	}
+
+	indexes := []int{}
+	limit := count
+	if limit < 0 {
+		limit = 1
+	}
+	e := proc.viewList(sess, req.Params[0], func(list *store.List) {
+		if nil == list {
+			return
+		}
+		skip := rank - 1
+		if rank < 0 {
+			skip = -rank - 1
+		}
+		for i := 0; i < list.Len(); i++ {
+			if (maxLen > 0) && (int64(i) >= maxLen) {
+				break
+			}
+			index := i
+			if rank < 0 {
+				index = list.Len() - 1 - i
+			}
+			if list.Index(index) != req.Params[1] {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			indexes = append(indexes, index)
+			if (0 != limit) && (int64(len(indexes)) >= limit) {
+				break
+			}
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count >= 0 {
+		res = newIntListRes(indexes...)
+	} else if 0 == len(indexes) {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	} else {
+		res = newIntRes(indexes[0])
+	}
+	return
+}
+
+// LMOVE : Pop value from one end of source list and push it to one end of
+// destination list
+func (proc *SimpleProc) LMOVE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	fromLeft, ok1 := parseListEnd(req.Params[2])
+	toLeft, ok2 := parseListEnd(req.Params[3])
+	if !ok1 || !ok2 {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+	return proc.moveGeneric(sess, req.Params[0], req.Params[1], fromLeft, toLeft)
+}
+
+// RPOPLPUSH : Pop value from tail of source list and push it to head of
+// destination list
+func (proc *SimpleProc) RPOPLPUSH(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.moveGeneric(sess, req.Params[0], req.Params[1], false, true)
+}
+
+func (proc *SimpleProc) moveGeneric(sess *Session, src string, dst string, fromLeft bool, toLeft bool) (res *proto.Response, err error) {
+	value, ok, e := proc.moveList(sess, src, dst, fromLeft, toLeft)
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if ok {
+		proc.signalKey(sess, dst)
+	}
+	res = newNullableBulkRes(value, ok)
+	return
+}
+
+// moveList : Move a value between lists atomically, false if source list
+// does not exist
+func (proc *SimpleProc) moveList(sess *Session, src string, dst string, fromLeft bool, toLeft bool) (value string, ok bool, err error) {
+	deleted := false
+	err = proc.GetKeyspace(sess).MutateMulti([]string{src, dst}, func(objs []*store.Object) ([]*store.Object, error) {
+		if nil == objs[0] {
+			return objs, nil
+		}
+		if (store.TYPE_LIST != objs[0].Type) || ((nil != objs[1]) && (store.TYPE_LIST != objs[1].Type)) {
+			return objs, store.ErrWrongType
+		}
+
+		list := objs[0].Value.(*store.List)
+		value, ok = listPop(list, fromLeft), true
+		if 0 == list.Len() {
+			objs[0] = nil
+			deleted = src != dst
+		}
+		if nil == objs[1] {
+			objs[1] = &store.Object{Type: store.TYPE_LIST, Value: store.NewList()}
+		}
+		listPush(objs[1].Value.(*store.List), toLeft, value)
+		return objs, nil
+	})
+	if ok {
+		proc.notifyChanged(sess, store.NOTIFY_LIST, listEvent(fromLeft, false), src, deleted)
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_LIST, listEvent(toLeft, true), dst)
+	}
+	return
+}
+
+// LMPOP : Pop values from one end of the first non-empty list of keys
+func (proc *SimpleProc) LMPOP(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	keys, left, count, res := parseListMpopArgs(req.Params)
+	if nil != res {
+		return
+	}
+
+	for _, key := range keys {
+		values, e := proc.popList(sess, key, left, count)
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+			return
+		}
+		if len(values) > 0 {
+			res = newMpopRes(key, values)
+			return
+		}
+	}
+	res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	return
+}
+
+// parseListMpopArgs : Parse numkeys, keys, end and COUNT option of LMPOP
+func parseListMpopArgs(params []string) (keys []string, left bool, count int64, res *proto.Response) {
+	numKeys, ok := parseInt(params[0])
+	if !ok || (numKeys <= 0) {
+		res = proto.NewErrorRes("ERR numkeys should be greater than 0")
+		return
+	}
+	if numKeys > int64(len(params)-2) {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+	keys = params[1 : 1+numKeys]
+	if left, ok = parseListEnd(params[1+numKeys]); !ok {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+
+	count = 1
+	options := params[2+numKeys:]
+	if 0 == len(options) {
+		return
+	}
+	if (2 != len(options)) || ("COUNT" != strings.ToUpper(options[0])) {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+	if count, ok = parseInt(options[1]); !ok || (count <= 0) {
+		res = proto.NewErrorRes("ERR count should be greater than 0")
+	}
+	return
+}
+
+func newMpopRes(key string, values []string) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newBulkRes(key))
+	res.SetResponse(proto.NewBulkListRes(proto.RES_TYPE_MULTI, values))
+	return res
+}
+
+// BLPOP : Pop value at head of the first non-empty list of keys, block until
+// a list is pushed or timeout passes if all lists are empty
+func (proc *SimpleProc) BLPOP(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.bpopGeneric(sess, req, true)
+}
+
+// BRPOP : Pop value at tail of the first non-empty list of keys, block until
+// a list is pushed or timeout passes if all lists are empty
+func (proc *SimpleProc) BRPOP(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.bpopGeneric(sess, req, false)
+}
+
+func (proc *SimpleProc) bpopGeneric(sess *Session, req *proto.Request, left bool) (res *proto.Response, err error) {
+	keys := req.Params[:len(req.Params)-1]
+	timeout, res := parseTimeout(req.Params[len(req.Params)-1])
+	if nil != res {
+		return
+	}
+
+	served := proc.blockOn(sess, keys, timeout, func(key string, blocked bool) (bool, []string) {
+		values, e := proc.popList(sess, key, left, 1)
+		if nil != e {
+			return blockError(e, blocked, &res), nil
+		}
+		if 0 == len(values) {
+			return false, nil
+		}
+		res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, []string{key, values[0]})
+		return true, nil
+	})
+	if !served {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	return
+}
+
+// BLMPOP : Pop values from one end of the first non-empty list of keys,
+// block until a list is pushed or timeout passes if all lists are empty
+func (proc *SimpleProc) BLMPOP(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	timeout, res := parseTimeout(req.Params[0])
+	if nil != res {
+		return
+	}
+	keys, left, count, res := parseListMpopArgs(req.Params[1:])
+	if nil != res {
+		return
+	}
+
+	served := proc.blockOn(sess, keys, timeout, func(key string, blocked bool) (bool, []string) {
+		values, e := proc.popList(sess, key, left, count)
+		if nil != e {
+			return blockError(e, blocked, &res), nil
+		}
+		if 0 == len(values) {
+			return false, nil
+		}
+		res = newMpopRes(key, values)
+		return true, nil
+	})
+	if !served {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	return
+}
+
+// BLMOVE : Move value between lists like LMOVE, block until source list is
+// pushed or timeout passes if it is empty
+func (proc *SimpleProc) BLMOVE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	fromLeft, ok1 := parseListEnd(req.Params[2])
+	toLeft, ok2 := parseListEnd(req.Params[3])
+	if !ok1 || !ok2 {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+	return proc.bmoveGeneric(sess, req.Params[0], req.Params[1], fromLeft, toLeft, req.Params[4])
+}
+
+// BRPOPLPUSH : Move value between lists like RPOPLPUSH, block until source
+// list is pushed or timeout passes if it is empty
+func (proc *SimpleProc) BRPOPLPUSH(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.bmoveGeneric(sess, req.Params[0], req.Params[1], false, true, req.Params[2])
+}
+
+func (proc *SimpleProc) bmoveGeneric(sess *Session, src string, dst string, fromLeft bool, toLeft bool, timeoutStr string) (res *proto.Response, err error) {
+	timeout, res := parseTimeout(timeoutStr)
+	if nil != res {
+		return
+	}
+
+	served := proc.blockOn(sess, []string{src}, timeout, func(key string, blocked bool) (bool, []string) {
+		value, ok, e := proc.moveList(sess, src, dst, fromLeft, toLeft)
+		if nil != e {
+			return blockError(e, blocked, &res), nil
+		}
+		if !ok {
+			return false, nil
+		}
+		res = newBulkRes(value)
+		return true, []string{dst}
+	})
+	if !served {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	}
+	return
+}
+
+// blockTry : Try to serve blocked client with key like store.TryFunc,
+// blocked is false for the first try before client is blocked
+type blockTry func(key string, blocked bool) (served bool, touched []string)
+
+// blockOn : Serve client by try on keys in order, client is blocked until it
+// is served, timeout passes or connection is closed if no key is ready,
+// commands executed by EXEC never block
+//
+// @param timeout int64 : Timeout in milliseconds, 0 to wait forever
+func (proc *SimpleProc) blockOn(sess *Session, keys []string, timeout int64, try blockTry) bool {
+	blocking := proc.GetDatabases().GetBlocking()
+	served := blocking.Try(sess.DB, keys, func(key string) (bool, []string) {
+		return try(key, false)
+	})
+	if served || sess.IsExec() {
+		return served
+	}
+
+	deadline := int64(0)
+	if timeout > 0 {
+		deadline = proc.nowMs() + timeout
+	}
+	w := blocking.Block(sess.DB, keys, deadline, func(key string) (bool, []string) {
+		return try(key, true)
+	})
+	select {
+	case <-w.Done():
+		return w.Served()
+	default:
+	}
+
+	// Shared lock of databases held by ProcessReq is released while client
+	// is blocked, so a transaction pushing to the keys can run
+	proc.databases.RUnlock()
+	defer proc.databases.RLock()
+	closed, stop := sess.WatchConn()
+	select {
+	case <-w.Done():
+	case <-closed:
+		blocking.Cancel(w)
+	}
+	stop()
+	return w.Served()
+}
+
+// blockError : Reply error to client at the first try, client keeps blocked
+// if key holds other type when it is signaled, return whether client is served
+func blockError(err error, blocked bool, res **proto.Response) bool {
+	if blocked && (store.ErrWrongType == err) {
+		return false
+	}
+	*res = proto.NewErrorRes(err.Error())
+	return true
+}
+
+// parseTimeout : Parse timeout of blocking commands in seconds, return
+// timeout in milliseconds
+func parseTimeout(str string) (int64, *proto.Response) {
+	seconds, err := strconv.ParseFloat(str, 64)
+	if (nil != err) || math.IsNaN(seconds) {
+		return 0, proto.NewErrorRes("ERR timeout is not a float or out of range")
+	}
+	ms := math.Ceil(seconds * 1000)
+	if ms >= math.MaxInt64/2 {
+		return 0, proto.NewErrorRes("ERR timeout is out of range")
+	}
+	if ms < 0 {
+		return 0, proto.NewErrorRes("ERR timeout is negative")
+	}
+	return int64(ms), nil
+}
+
+// parseListEnd : Parse LEFT or RIGHT, true if it is LEFT
+func parseListEnd(str string) (left bool, ok bool) {
+	switch strings.ToUpper(str) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// listIndex : Convert index counted from tail if negative to index from head,
+// false if it is out of range
+func listIndex(list *store.List, index int64) (int, bool) {
+	if index < 0 {
+		index = int64(list.Len()) + index
+	}
+	if (index < 0) || (index >= int64(list.Len())) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// listRange : Normalize range of LRANGE and LTRIM to indexes from head, false
+// if range is empty
+func listRange(list *store.List, start int64, stop int64) (int, int, bool) {
+	length := int64(list.Len())
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if (start > stop) || (start >= length) {
+		return 0, 0, false
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return int(start), int(stop), true
+}
+
+func listPush(list *store.List, left bool, value string) {
+	if left {
+		list.PushFront(value)
+	} else {
+		list.PushBack(value)
+	}
+}
+
+// listPop : Pop value of non-empty list
+func listPop(list *store.List, left bool) string {
+	if left {
+		value, _ := list.PopFront()
+		return value
+	}
+	value, _ := list.PopBack()
+	return value
+}
+
+func registerListCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "lpush", Func: simpleCmd((*SimpleProc).LPUSH), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist."},
+		&Command{Name: "rpush", Func: simpleCmd((*SimpleProc).RPUSH), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist."},
+		&Command{Name: "lpushx", Func: simpleCmd((*SimpleProc).LPUSHX), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "2.2.0", Summary: "Prepends one or more elements to a list only when the list exists."},
+		&Command{Name: "rpushx", Func: simpleCmd((*SimpleProc).RPUSHX), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "2.2.0", Summary: "Appends an element to a list only when the list exists."},
+		&Command{Name: "lpop", Func: simpleCmd((*SimpleProc).LPOP), Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped."},
+		&Command{Name: "rpop", Func: simpleCmd((*SimpleProc).RPOP), Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped."},
+		&Command{Name: "llen", Func: simpleCmd((*SimpleProc).LLEN), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Returns the length of a list."},
+		&Command{Name: "lindex", Func: simpleCmd((*SimpleProc).LINDEX), Arity: 3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Returns an element from a list by its index."},
+		&Command{Name: "lset", Func: simpleCmd((*SimpleProc).LSET), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Sets the value of an element in a list by its index."},
+		&Command{Name: "lrange", Func: simpleCmd((*SimpleProc).LRANGE), Arity: 4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Returns a range of elements from a list."},
+		&Command{Name: "ltrim", Func: simpleCmd((*SimpleProc).LTRIM), Arity: 4, Flags: CMD_WRITE, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed."},
+		&Command{Name: "linsert", Func: simpleCmd((*SimpleProc).LINSERT), Arity: 5, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "2.2.0", Summary: "Inserts an element before or after another element in a list."},
+		&Command{Name: "lrem", Func: simpleCmd((*SimpleProc).LREM), Arity: 4, Flags: CMD_WRITE, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.0.0", Summary: "Removes elements from a list. Deletes the list if the last element was removed."},
+		&Command{Name: "lpos", Func: simpleCmd((*SimpleProc).LPOS), Arity: -3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_LIST, Since: "6.0.6", Summary: "Returns the index of matching elements in a list."},
+		&Command{Name: "lmove", Func: simpleCmd((*SimpleProc).LMOVE), Arity: 5, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_LIST, Since: "6.2.0", Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved."},
+		&Command{Name: "rpoplpush", Func: simpleCmd((*SimpleProc).RPOPLPUSH), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_LIST, Since: "1.2.0", Summary: "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped."},
+		&Command{Name: "lmpop", Func: simpleCmd((*SimpleProc).LMPOP), Arity: -4, Flags: CMD_WRITE, KeysFunc: numKeysAt(0),
+			Group: GROUP_LIST, Since: "7.0.0", Summary: "Returns multiple elements from a list after removing them. Deletes the list if the last element was popped."},
+		&Command{Name: "blpop", Func: simpleCmd((*SimpleProc).BLPOP), Arity: -3, Flags: CMD_WRITE | CMD_BLOCKING, FirstKey: 1, LastKey: -2, KeyStep: 1,
+			Group: GROUP_LIST, Since: "2.0.0", Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+		&Command{Name: "brpop", Func: simpleCmd((*SimpleProc).BRPOP), Arity: -3, Flags: CMD_WRITE | CMD_BLOCKING, FirstKey: 1, LastKey: -2, KeyStep: 1,
+			Group: GROUP_LIST, Since: "2.0.0", Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+		&Command{Name: "blmpop", Func: simpleCmd((*SimpleProc).BLMPOP), Arity: -5, Flags: CMD_WRITE | CMD_BLOCKING, KeysFunc: numKeysAt(1),
+			Group: GROUP_LIST, Since: "7.0.0", Summary: "Pops the first element from one of multiple lists. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+		&Command{Name: "blmove", Func: simpleCmd((*SimpleProc).BLMOVE), Arity: 6, Flags: CMD_WRITE | CMD_DENYOOM | CMD_BLOCKING, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_LIST, Since: "6.2.0", Summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved."},
+		&Command{Name: "brpoplpush", Func: simpleCmd((*SimpleProc).BRPOPLPUSH), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM | CMD_BLOCKING, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_LIST, Since: "2.2.0", Summary: "Pops an element from a list, pushes it to another list and returns it. Block until an element is available otherwise. Deletes the list if the last element was popped."},
+	)
+}
@@ -0,0 +1,555 @@
+package processor
+
+import (
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Operations of set algebra
+const (
+	setOpInter = iota
+	setOpUnion
+	setOpDiff
+)
+
+// viewSet : Call function with set of key under read lock, set is nil if key
+// does not exist
+func (proc *SimpleProc) viewSet(sess *Session, key string, function func(set *store.Dict)) error {
+	return proc.GetKeyspace(sess).View(key, store.TYPE_SET, func(value interface{}) error {
+		set, _ := value.(*store.Dict)
+		function(set)
+		return nil
+	})
+}
+
+// updateSet : Call function with set of key under write lock, set is nil if
+// key does not exist and key is deleted if set becomes empty
+func (proc *SimpleProc) updateSet(sess *Session, key string, function func(set *store.Dict) *store.Dict) error {
+	return proc.GetKeyspace(sess).Update(key, store.TYPE_SET, func(value interface{}) (interface{}, error) {
+		set, _ := value.(*store.Dict)
+		set = function(set)
+		if (nil == set) || (0 == set.Len()) {
+			return nil, nil
+		}
+		return set, nil
+	})
+}
+
+// SADD : Add members to set, reply count of members added
+func (proc *SimpleProc) SADD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count := 0
+	e := proc.updateSet(sess, req.Params[0], func(set *store.Dict) *store.Dict {
+		if nil == set {
+			set = store.NewDict()
+		}
+		for _, member := range req.Params[1:] {
+			if set.Set(member, nil) {
+				count++
+			}
+		}
+		return set
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_SET, "sadd", req.Params[0])
+	}
+	res = newIntRes(count)
+	return
+}
+
+// SREM : Remove members from set, reply count of members removed
+func (proc *SimpleProc) SREM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count := 0
+	deleted := false
+	e := proc.updateSet(sess, req.Params[0], func(set *store.Dict) *store.Dict {
+		if nil == set {
+			return nil
+		}
+		for _, member := range req.Params[1:] {
+			if set.Delete(member) {
+				count++
+			}
+		}
+		deleted = 0 == set.Len()
+		return set
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_SET, "srem", req.Params[0], deleted)
+	}
+	res = newIntRes(count)
+	return
+}
+
+// SMEMBERS : Get all members of set
+func (proc *SimpleProc) SMEMBERS(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	members := []string{}
+	e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+		if nil != set {
+			members = dictKeys(set)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_SET, members)
+	return
+}
+
+// SISMEMBER : Whether member belongs to set
+func (proc *SimpleProc) SISMEMBER(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	exists := false
+	e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+		if nil != set {
+			_, exists = set.Get(req.Params[1])
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newBoolIntRes(exists)
+	return
+}
+
+// SMISMEMBER : Whether each member belongs to set
+func (proc *SimpleProc) SMISMEMBER(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	flags := make([]int, len(req.Params)-1)
+	e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+		if nil == set {
+			return
+		}
+		for i, member := range req.Params[1:] {
+			if _, ok := set.Get(member); ok {
+				flags[i] = 1
+			}
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntListRes(flags...)
+	return
+}
+
+// SCARD : Count of members of set
+func (proc *SimpleProc) SCARD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+		if nil != set {
+			length = set.Len()
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(length)
+	return
+}
+
+// SPOP : Remove and get random members of set
+func (proc *SimpleProc) SPOP(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count := int64(1)
+	if 2 == len(req.Params) {
+		var ok bool
+		if count, ok = parseInt(req.Params[1]); !ok || (count < 0) {
+			res = proto.NewErrorRes("ERR value is out of range, must be positive")
+			return
+		}
+	}
+
+	members := []string{}
+	deleted := false
+	e := proc.updateSet(sess, req.Params[0], func(set *store.Dict) *store.Dict {
+		if (nil == set) || (0 == count) {
+			return set
+		}
+		members = randomDictKeys(set, count)
+		for _, member := range members {
+			set.Delete(member)
+		}
+		deleted = 0 == set.Len()
+		return set
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if len(members) > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_SET, "spop", req.Params[0], deleted)
+	}
+	if 2 == len(req.Params) {
+		res = proto.NewBulkListRes(proto.RES_TYPE_SET, members)
+	} else if 0 == len(members) {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	} else {
+		res = newBulkRes(members[0])
+	}
+	return
+}
+
+// SRANDMEMBER : Get random members of set, count > 0 replies distinct
+// members and count < 0 allows the same member multiple times
+func (proc *SimpleProc) SRANDMEMBER(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if 1 == len(req.Params) {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+			if nil != set {
+				member, _, _ := set.RandomKey()
+				res.SetString(member)
+			}
+		})
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+		}
+		return
+	}
+
+	count, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	if math.MinInt64 == count {
+		res = proto.NewErrorRes("ERR value is out of range")
+		return
+	}
+
+	members := []string{}
+	e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+		if nil != set {
+			members = randomDictKeys(set, count)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_MULTI, members)
+	return
+}
+
+// SMOVE : Move member from source set to destination set
+func (proc *SimpleProc) SMOVE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	src, dst, member := req.Params[0], req.Params[1], req.Params[2]
+	moved := false
+	added := false
+	deleted := false
+	e := proc.GetKeyspace(sess).MutateMulti([]string{src, dst}, func(objs []*store.Object) ([]*store.Object, error) {
+		if nil == objs[0] {
+			return objs, nil
+		}
+		if (store.TYPE_SET != objs[0].Type) || ((nil != objs[1]) && (store.TYPE_SET != objs[1].Type)) {
+			return objs, store.ErrWrongType
+		}
+
+		set := objs[0].Value.(*store.Dict)
+		if src == dst {
+			_, moved = set.Get(member)
+			return objs, nil
+		}
+		if moved = set.Delete(member); !moved {
+			return objs, nil
+		}
+		if 0 == set.Len() {
+			objs[0] = nil
+			deleted = true
+		}
+		if nil == objs[1] {
+			objs[1] = &store.Object{Type: store.TYPE_SET, Value: store.NewDict()}
+		}
+		added = objs[1].Value.(*store.Dict).Set(member, nil)
+		return objs, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if moved && (src != dst) {
+		proc.notifyChanged(sess, store.NOTIFY_SET, "srem", src, deleted)
+		if added {
+			proc.notifyKeyspaceEvent(sess, store.NOTIFY_SET, "sadd", dst)
+		}
+	}
+	res = newBoolIntRes(moved)
+	return
+}
+
+// SINTER : Get intersection of sets
+func (proc *SimpleProc) SINTER(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setOpGeneric(sess, req.Params, setOpInter)
+}
+
+// SUNION : Get union of sets
+func (proc *SimpleProc) SUNION(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setOpGeneric(sess, req.Params, setOpUnion)
+}
+
+// SDIFF : Get members of the first set not in the other sets
+func (proc *SimpleProc) SDIFF(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setOpGeneric(sess, req.Params, setOpDiff)
+}
+
+func (proc *SimpleProc) setOpGeneric(sess *Session, keys []string, op int) (res *proto.Response, err error) {
+	members := []string{}
+	e := proc.GetKeyspace(sess).ViewMulti(keys, func(objs []*store.Object) error {
+		sets, err := setsOf(objs)
+		if nil != err {
+			return err
+		}
+		members = dictKeys(computeSetOp(sets, op))
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = proto.NewBulkListRes(proto.RES_TYPE_SET, members)
+	return
+}
+
+// SINTERSTORE : Store intersection of sets to destination, reply its size
+func (proc *SimpleProc) SINTERSTORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setOpStoreGeneric(sess, req.Params, setOpInter, "sinterstore")
+}
+
+// SUNIONSTORE : Store union of sets to destination, reply its size
+func (proc *SimpleProc) SUNIONSTORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setOpStoreGeneric(sess, req.Params, setOpUnion, "sunionstore")
+}
+
+// SDIFFSTORE : Store difference of sets to destination, reply its size
+func (proc *SimpleProc) SDIFFSTORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setOpStoreGeneric(sess, req.Params, setOpDiff, "sdiffstore")
+}
+
+// setOpStoreGeneric : Destination is overwritten whatever type it holds,
+// and deleted if result is empty
+func (proc *SimpleProc) setOpStoreGeneric(sess *Session, keys []string, op int, event string) (res *proto.Response, err error) {
+	length := 0
+	existed := false
+	e := proc.GetKeyspace(sess).MutateMulti(keys, func(objs []*store.Object) ([]*store.Object, error) {
+		sets, err := setsOf(objs[1:])
+		if nil != err {
+			return objs, err
+		}
+		existed = nil != objs[0]
+		result := computeSetOp(sets, op)
+		length = result.Len()
+		if 0 == length {
+			objs[0] = nil
+		} else {
+			objs[0] = &store.Object{Type: store.TYPE_SET, Value: result}
+		}
+		// Source keys equal to destination must see the result
+		for i := 1; i < len(keys); i++ {
+			if keys[i] == keys[0] {
+				objs[i] = objs[0]
+			}
+		}
+		return objs, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyStored(sess, store.NOTIFY_SET, event, keys[0], length > 0, existed)
+	res = newIntRes(length)
+	return
+}
+
+// SINTERCARD : Get size of intersection of sets, counting stops at LIMIT
+func (proc *SimpleProc) SINTERCARD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	numKeys, ok := parseInt(req.Params[0])
+	if !ok || (numKeys <= 0) {
+		res = proto.NewErrorRes("ERR numkeys should be greater than 0")
+		return
+	}
+	if numKeys > int64(len(req.Params)-1) {
+		res = proto.NewErrorRes("ERR Number of keys can't be greater than number of args")
+		return
+	}
+	keys := req.Params[1 : 1+numKeys]
+	limit := int64(0)
+	options := req.Params[1+numKeys:]
+	for i := 0; i < len(options); i++ {
+		if ("LIMIT" != strings.ToUpper(options[i])) || (i+1 >= len(options)) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		if limit, ok = parseInt(options[i+1]); !ok || (limit < 0) {
+			res = proto.NewErrorRes("ERR LIMIT can't be negative")
+			return
+		}
+		i++
+	}
+
+	count := int64(0)
+	e := proc.GetKeyspace(sess).ViewMulti(keys, func(objs []*store.Object) error {
+		sets, err := setsOf(objs)
+		if nil != err {
+			return err
+		}
+		count = int64(interSets(sets, limit).Len())
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(int(count))
+	return
+}
+
+// SSCAN : Iterate members of set by cursor
+func (proc *SimpleProc) SSCAN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	cursor, opts, res := parseScanArgs(req.Params[1:], false)
+	if nil != res {
+		return
+	}
+
+	items := []string{}
+	e := proc.viewSet(sess, req.Params[0], func(set *store.Dict) {
+		if nil == set {
+			cursor = 0
+			return
+		}
+		cursor, items = scanDict(set, cursor, opts, nil)
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newScanRes(cursor, items)
+	return
+}
+
+// setsOf : Get sets of objects, nil for key does not exist, ErrWrongType if
+// any object holds other type
+func setsOf(objs []*store.Object) ([]*store.Dict, error) {
+	sets := make([]*store.Dict, len(objs))
+	for i, obj := range objs {
+		if nil == obj {
+			continue
+		}
+		if store.TYPE_SET != obj.Type {
+			return nil, store.ErrWrongType
+		}
+		sets[i] = obj.Value.(*store.Dict)
+	}
+	return sets, nil
+}
+
+// computeSetOp : Compute a new set by operation on sets, nil set is empty
+func computeSetOp(sets []*store.Dict, op int) *store.Dict {
+	switch op {
+	case setOpInter:
+		return interSets(sets, 0)
+	case setOpUnion:
+		result := store.NewDict()
+		for _, set := range sets {
+			if nil == set {
+				continue
+			}
+			set.Range(func(member string, v interface{}) bool {
+				result.Set(member, nil)
+				return true
+			})
+		}
+		return result
+	default:
+		result := store.NewDict()
+		if nil == sets[0] {
+			return result
+		}
+		sets[0].Range(func(member string, v interface{}) bool {
+			for _, set := range sets[1:] {
+				if nil == set {
+					continue
+				}
+				if _, ok := set.Get(member); ok {
+					return true
+				}
+			}
+			result.Set(member, nil)
+			return true
+		})
+		return result
+	}
+}
+
+// interSets : Intersection of sets, members of the smallest set are checked
+// against the others, stop when size of result reaches limit if it is not 0
+func interSets(sets []*store.Dict, limit int64) *store.Dict {
+	result := store.NewDict()
+	for _, set := range sets {
+		if nil == set {
+			return result
+		}
+	}
+	sorted := append([]*store.Dict{}, sets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+	sorted[0].Range(func(member string, v interface{}) bool {
+		for _, set := range sorted[1:] {
+			if _, ok := set.Get(member); !ok {
+				return true
+			}
+		}
+		result.Set(member, nil)
+		return (0 == limit) || (int64(result.Len()) < limit)
+	})
+	return result
+}
+
+func registerSetCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "sadd", Func: simpleCmd((*SimpleProc).SADD), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Adds one or more members to a set. Creates the key if it doesn't exist."},
+		&Command{Name: "srem", Func: simpleCmd((*SimpleProc).SREM), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Removes one or more members from a set. Deletes the set if the last member was removed."},
+		&Command{Name: "smembers", Func: simpleCmd((*SimpleProc).SMEMBERS), Arity: 2, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Returns all members of a set."},
+		&Command{Name: "sismember", Func: simpleCmd((*SimpleProc).SISMEMBER), Arity: 3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Determines whether a member belongs to a set."},
+		&Command{Name: "smismember", Func: simpleCmd((*SimpleProc).SMISMEMBER), Arity: -3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "6.2.0", Summary: "Determines whether multiple members belong to a set."},
+		&Command{Name: "scard", Func: simpleCmd((*SimpleProc).SCARD), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Returns the number of members in a set."},
+		&Command{Name: "spop", Func: simpleCmd((*SimpleProc).SPOP), Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped."},
+		&Command{Name: "srandmember", Func: simpleCmd((*SimpleProc).SRANDMEMBER), Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Get one or multiple random members from a set"},
+		&Command{Name: "smove", Func: simpleCmd((*SimpleProc).SMOVE), Arity: 4, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Moves a member from one set to another."},
+		&Command{Name: "sinter", Func: simpleCmd((*SimpleProc).SINTER), Arity: -2, Flags: CMD_READONLY, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Returns the intersect of multiple sets."},
+		&Command{Name: "sinterstore", Func: simpleCmd((*SimpleProc).SINTERSTORE), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Stores the intersect of multiple sets in a key."},
+		&Command{Name: "sintercard", Func: simpleCmd((*SimpleProc).SINTERCARD), Arity: -3, Flags: CMD_READONLY, KeysFunc: numKeysAt(0),
+			Group: GROUP_SET, Since: "7.0.0", Summary: "Returns the number of members of the intersect of multiple sets."},
+		&Command{Name: "sunion", Func: simpleCmd((*SimpleProc).SUNION), Arity: -2, Flags: CMD_READONLY, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Returns the union of multiple sets."},
+		&Command{Name: "sunionstore", Func: simpleCmd((*SimpleProc).SUNIONSTORE), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Stores the union of multiple sets in a key."},
+		&Command{Name: "sdiff", Func: simpleCmd((*SimpleProc).SDIFF), Arity: -2, Flags: CMD_READONLY, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Returns the difference of multiple sets."},
+		&Command{Name: "sdiffstore", Func: simpleCmd((*SimpleProc).SDIFFSTORE), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_SET, Since: "1.0.0", Summary: "Stores the difference of multiple sets in a key."},
+		&Command{Name: "sscan", Func: simpleCmd((*SimpleProc).SSCAN), Arity: -3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SET, Since: "2.8.0", Summary: "Iterates over members of a set."},
+	)
+}
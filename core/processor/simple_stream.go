@@ -0,0 +1,1584 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"strconv"
+	"strings"
+)
+
+// Error of invalid stream ID
+const ERR_INVALID_STREAM_ID = "ERR Invalid stream ID specified as stream command argument"
+
+// Trimming strategies of XADD and XTRIM
+const (
+	streamTrimNone = iota
+	streamTrimMaxLen
+	streamTrimMinID
+)
+
+// STREAM_TRIM_APPROX_LIMIT : Default LIMIT of trimming with `~`, same as
+// redis with default stream-node-max-entries
+const STREAM_TRIM_APPROX_LIMIT = 100 * 100
+
+// STREAM_NODE_MAX_ENTRIES : Entries of a radix tree node of redis, used to
+// report radix tree size by XINFO STREAM
+const STREAM_NODE_MAX_ENTRIES = 100
+
+// viewStream : Call function with stream of key under read lock, stream is
+// nil if key does not exist
+func (proc *SimpleProc) viewStream(sess *Session, key string, function func(stream *store.Stream)) error {
+	return proc.GetKeyspace(sess).View(key, store.TYPE_STREAM, func(value interface{}) error {
+		stream, _ := value.(*store.Stream)
+		function(stream)
+		return nil
+	})
+}
+
+// updateStream : Call function with stream of key under write lock, stream
+// is nil if key does not exist, unlike other types an empty stream is kept
+func (proc *SimpleProc) updateStream(sess *Session, key string, function func(stream *store.Stream) (*store.Stream, error)) error {
+	return proc.GetKeyspace(sess).Update(key, store.TYPE_STREAM, func(value interface{}) (interface{}, error) {
+		stream, _ := value.(*store.Stream)
+		stream, err := function(stream)
+		if nil == stream {
+			return nil, err
+		}
+		return stream, err
+	})
+}
+
+// streamTrimSpec : Trimming options of XADD and XTRIM
+type streamTrimSpec struct {
+	strategy int   // One of streamTrim*
+	approx   bool  // `~` is given, trimming is exact anyway but LIMIT is allowed
+	maxLen   int64 // Threshold of MAXLEN
+	minID    store.StreamID
+	limit    int64 // Max count of entries trimmed, 0 if no limit
+}
+
+// parseStreamTrimArgs : Parse options of XADD or XTRIM, XADD stops at the
+// first param that is not an option and returns its index as the ID
+func parseStreamTrimArgs(params []string, xadd bool) (spec streamTrimSpec, nomkstream bool, next int, res *proto.Response) {
+	hasLimit := false
+	next = 0
+	for ; next < len(params); next++ {
+		option := strings.ToUpper(params[next])
+		if ("NOMKSTREAM" == option) && xadd {
+			nomkstream = true
+		} else if (("MAXLEN" == option) || ("MINID" == option)) && (next+1 < len(params)) {
+			strategy := streamTrimMaxLen
+			if "MINID" == option {
+				strategy = streamTrimMinID
+			}
+			if (streamTrimNone != spec.strategy) && (strategy != spec.strategy) {
+				res = proto.NewErrorRes("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+				return
+			}
+			spec.strategy = strategy
+			next++
+			if (("~" == params[next]) || ("=" == params[next])) && (next+1 < len(params)) {
+				spec.approx = "~" == params[next]
+				next++
+			}
+			if streamTrimMaxLen == strategy {
+				var ok bool
+				if spec.maxLen, ok = parseInt(params[next]); !ok {
+					res = proto.NewErrorRes(ERR_NOT_INTEGER)
+					return
+				}
+				if spec.maxLen < 0 {
+					res = proto.NewErrorRes("ERR The MAXLEN argument must be >= 0.")
+					return
+				}
+			} else if spec.minID, res = parseStreamID(params[next]); nil != res {
+				return
+			}
+		} else if ("LIMIT" == option) && (next+1 < len(params)) {
+			next++
+			var ok bool
+			if spec.limit, ok = parseInt(params[next]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if spec.limit < 0 {
+				res = proto.NewErrorRes("ERR The LIMIT argument must be >= 0.")
+				return
+			}
+			hasLimit = true
+		} else if xadd {
+			break
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+
+	if hasLimit && !spec.approx {
+		res = proto.NewErrorRes("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		return
+	}
+	if spec.approx && !hasLimit {
+		spec.limit = STREAM_TRIM_APPROX_LIMIT
+	}
+	return
+}
+
+// trim : Trim stream, return count of entries deleted
+func (spec *streamTrimSpec) trim(stream *store.Stream) int64 {
+	switch spec.strategy {
+	case streamTrimMaxLen:
+		return stream.TrimMaxLen(spec.maxLen, spec.limit)
+	case streamTrimMinID:
+		return stream.TrimMinID(spec.minID, spec.limit)
+	}
+	return 0
+}
+
+// XADD : Append entry to stream, reply its ID
+func (proc *SimpleProc) XADD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key := req.Params[0]
+	trim, nomkstream, i, res := parseStreamTrimArgs(req.Params[1:], true)
+	if nil != res {
+		return
+	}
+	args := req.Params[1+i:]
+	if (len(args) < 3) || (0 == len(args)%2) {
+		res = proto.NewErrorRes("ERR wrong number of arguments for 'xadd' command")
+		return
+	}
+
+	// `*` generates the whole ID and `ms-*` generates sequence number only
+	idStr, autoID, autoSeq := args[0], false, false
+	var id store.StreamID
+	if "*" == idStr {
+		autoID = true
+	} else if strings.HasSuffix(idStr, "-*") {
+		ms, e := strconv.ParseUint(strings.TrimSuffix(idStr, "-*"), 10, 64)
+		if nil != e {
+			res = proto.NewErrorRes(ERR_INVALID_STREAM_ID)
+			return
+		}
+		id.Ms, autoSeq = ms, true
+	} else if id, res = parseStreamID(idStr); nil != res {
+		return
+	} else if id.IsZero() {
+		res = proto.NewErrorRes("ERR The ID specified in XADD must be greater than 0-0")
+		return
+	}
+
+	added := false
+	trimmed := int64(0)
+	e := proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			if nomkstream {
+				return nil, nil
+			}
+			stream = store.NewStream()
+		}
+
+		var ok bool
+		last := stream.LastID()
+		switch {
+		case autoID:
+			if id, ok = stream.NextID(uint64(proc.nowMs())); !ok {
+				return nil, errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+			}
+		case autoSeq && (id.Ms == last.Ms):
+			if id, ok = last.Next(); !ok || (id.Ms != last.Ms) {
+				return nil, errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+			}
+		case autoSeq && (id.Ms < last.Ms), !autoSeq && !last.Less(id):
+			return nil, errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+
+		stream.Add(id, append([]string{}, args[1:]...))
+		trimmed = trim.trim(stream)
+		added = true
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if !added {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xadd", key)
+	if trimmed > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xtrim", key)
+	}
+	proc.signalKey(sess, key)
+	res = newBulkRes(id.String())
+	return
+}
+
+// XLEN : Count of entries of stream
+func (proc *SimpleProc) XLEN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.viewStream(sess, req.Params[0], func(stream *store.Stream) {
+		if nil != stream {
+			length = stream.Len()
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(length)
+	return
+}
+
+// XRANGE : Get entries of stream with ID in range
+func (proc *SimpleProc) XRANGE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.xrangeGeneric(sess, req, false)
+}
+
+// XREVRANGE : Get entries of stream with ID in range in reverse order, end
+// is given before start
+func (proc *SimpleProc) XREVRANGE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.xrangeGeneric(sess, req, true)
+}
+
+func (proc *SimpleProc) xrangeGeneric(sess *Session, req *proto.Request, reverse bool) (res *proto.Response, err error) {
+	startStr, endStr := req.Params[1], req.Params[2]
+	if reverse {
+		startStr, endStr = endStr, startStr
+	}
+	start, res := parseRangeStart(startStr)
+	if nil != res {
+		return
+	}
+	end, res := parseRangeEnd(endStr)
+	if nil != res {
+		return
+	}
+	count := int64(-1)
+	if len(req.Params) > 3 {
+		if (5 != len(req.Params)) || ("COUNT" != strings.ToUpper(req.Params[3])) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		var ok bool
+		if count, ok = parseInt(req.Params[4]); !ok {
+			res = proto.NewErrorRes(ERR_NOT_INTEGER)
+			return
+		}
+		if count < 0 {
+			count = 0
+		}
+	}
+
+	exists := false
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	e := proc.viewStream(sess, req.Params[0], func(stream *store.Stream) {
+		if (nil == stream) || (0 == count) {
+			exists = nil != stream
+			return
+		}
+		exists = true
+		stream.Range(start, end, reverse, func(entry store.StreamEntry) bool {
+			res.SetResponse(newStreamEntryRes(entry))
+			return (count < 0) || (int64(len(res.Nest)) < count)
+		})
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	} else if exists && (0 == count) {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	return
+}
+
+// XDEL : Delete entries of stream, reply count of entries deleted
+func (proc *SimpleProc) XDEL(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	ids, res := parseStreamIDs(req.Params[1:])
+	if nil != res {
+		return
+	}
+
+	count := 0
+	e := proc.updateStream(sess, req.Params[0], func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			return nil, nil
+		}
+		for _, id := range ids {
+			if stream.Delete(id) {
+				count++
+			}
+		}
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xdel", req.Params[0])
+	}
+	res = newIntRes(count)
+	return
+}
+
+// XTRIM : Trim stream by MAXLEN or MINID, reply count of entries deleted
+func (proc *SimpleProc) XTRIM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	trim, _, _, res := parseStreamTrimArgs(req.Params[1:], false)
+	if nil != res {
+		return
+	}
+	if streamTrimNone == trim.strategy {
+		res = proto.NewErrorRes("ERR syntax error, XTRIM must be called with a trimming strategy")
+		return
+	}
+
+	count := int64(0)
+	e := proc.updateStream(sess, req.Params[0], func(stream *store.Stream) (*store.Stream, error) {
+		if nil != stream {
+			count = trim.trim(stream)
+		}
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xtrim", req.Params[0])
+	}
+	res = newIntRes(int(count))
+	return
+}
+
+// xreadSpec : Options of XREAD and XREADGROUP
+type xreadSpec struct {
+	group    string
+	consumer string
+	hasGroup bool
+	count    int64 // Max count of entries read from each stream, 0 if no limit
+	block    int64 // Timeout in milliseconds, 0 blocks forever, -1 if BLOCK is not given
+	noack    bool
+	keys     []string
+	ids      []string
+}
+
+// parseXreadArgs : Parse options, keys and IDs of XREAD or XREADGROUP
+func parseXreadArgs(params []string, xreadgroup bool) (spec xreadSpec, res *proto.Response) {
+	spec.block = -1
+	name, special := "xread", "'$'"
+	if xreadgroup {
+		name, special = "xreadgroup", "'>'"
+	}
+
+	for i := 0; i < len(params); i++ {
+		option := strings.ToUpper(params[i])
+		if ("BLOCK" == option) && (i+1 < len(params)) {
+			i++
+			var ok bool
+			if spec.block, ok = parseInt(params[i]); !ok {
+				res = proto.NewErrorRes("ERR timeout is not an integer or out of range")
+				return
+			}
+			if spec.block < 0 {
+				res = proto.NewErrorRes("ERR timeout is negative")
+				return
+			}
+		} else if ("COUNT" == option) && (i+1 < len(params)) {
+			i++
+			var ok bool
+			if spec.count, ok = parseInt(params[i]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if spec.count < 0 {
+				spec.count = 0
+			}
+		} else if "STREAMS" == option {
+			streams := params[i+1:]
+			if (0 == len(streams)) || (0 != len(streams)%2) {
+				res = proto.NewErrorRes("ERR Unbalanced '" + name + "' list of streams: for each stream key an ID or " + special + " must be specified.")
+				return
+			}
+			spec.keys = streams[:len(streams)/2]
+			spec.ids = streams[len(streams)/2:]
+			break
+		} else if ("GROUP" == option) && (i+2 < len(params)) {
+			if !xreadgroup {
+				res = proto.NewErrorRes("ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead.")
+				return
+			}
+			spec.group, spec.consumer, spec.hasGroup = params[i+1], params[i+2], true
+			i = i + 2
+		} else if ("NOACK" == option) && xreadgroup {
+			spec.noack = true
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+
+	if nil == spec.keys {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+	} else if xreadgroup && !spec.hasGroup {
+		res = proto.NewErrorRes("ERR Missing GROUP option for XREADGROUP")
+	}
+	return
+}
+
+// streamRead : Entries read from stream of key
+type streamRead struct {
+	key     string
+	entries *proto.Response
+}
+
+// XREAD : Read entries with ID greater than the given ones from streams,
+// block until an entry is added or timeout passes if BLOCK is given and
+// there is nothing to read
+func (proc *SimpleProc) XREAD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	spec, res := parseXreadArgs(req.Params, false)
+	if nil != res {
+		return
+	}
+
+	// `$` is resolved once, so entries added while blocked are read
+	ids := make([]store.StreamID, len(spec.keys))
+	for i, key := range spec.keys {
+		switch spec.ids[i] {
+		case "$":
+			e := proc.viewStream(sess, key, func(stream *store.Stream) {
+				if nil != stream {
+					ids[i] = stream.LastID()
+				}
+			})
+			if nil != e {
+				res = proto.NewErrorRes(e.Error())
+				return
+			}
+		case ">":
+			res = proto.NewErrorRes("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+			return
+		default:
+			if ids[i], res = parseStreamID(spec.ids[i]); nil != res {
+				return
+			}
+		}
+	}
+
+	read := func(i int) (*proto.Response, error) {
+		entries := proto.NewResponse(proto.RES_TYPE_MULTI)
+		err := proc.viewStream(sess, spec.keys[i], func(stream *store.Stream) {
+			start, ok := ids[i].Next()
+			if (nil == stream) || !ok {
+				return
+			}
+			stream.Range(start, store.MaxStreamID, false, func(entry store.StreamEntry) bool {
+				entries.SetResponse(newStreamEntryRes(entry))
+				return (0 == spec.count) || (int64(len(entries.Nest)) < spec.count)
+			})
+		})
+		return entries, err
+	}
+
+	reads := []streamRead{}
+	for i, key := range spec.keys {
+		entries, e := read(i)
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+			return
+		}
+		if len(entries.Nest) > 0 {
+			reads = append(reads, streamRead{key, entries})
+		}
+	}
+	if (len(reads) > 0) || (spec.block < 0) {
+		res = newStreamReadsRes(sess, reads)
+		return
+	}
+
+	served := proc.blockOn(sess, spec.keys, spec.block, func(key string, blocked bool) (bool, []string) {
+		entries, e := read(indexOfKey(spec.keys, key))
+		if nil != e {
+			return blockError(e, blocked, &res), nil
+		}
+		if 0 == len(entries.Nest) {
+			return false, nil
+		}
+		res = newStreamReadsRes(sess, []streamRead{{key, entries}})
+		return true, nil
+	})
+	if !served {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	return
+}
+
+// XREADGROUP : Read entries from streams as consumer of group, ID `>` reads
+// entries never delivered to group and other IDs read pending entries of
+// consumer, block until an entry is added or timeout passes if BLOCK is
+// given and there is nothing to read
+func (proc *SimpleProc) XREADGROUP(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	spec, res := parseXreadArgs(req.Params, true)
+	if nil != res {
+		return
+	}
+
+	// IDs of pending entries to read, nil for `>`
+	ids := make([]*store.StreamID, len(spec.keys))
+	for i := range spec.keys {
+		switch spec.ids[i] {
+		case ">":
+		case "$":
+			res = proto.NewErrorRes("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+			return
+		default:
+			id, r := parseStreamID(spec.ids[i])
+			if nil != r {
+				res = r
+				return
+			}
+			ids[i] = &id
+		}
+	}
+
+	// All groups are checked before anything is delivered
+	history := false
+	for i, key := range spec.keys {
+		found := false
+		e := proc.viewStream(sess, key, func(stream *store.Stream) {
+			found = (nil != stream) && (nil != stream.Group(spec.group))
+		})
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+			return
+		}
+		if !found {
+			res = proto.NewErrorRes(noGroupError(key, spec.group, true).Error())
+			return
+		}
+		history = history || (nil != ids[i])
+	}
+
+	reads := []streamRead{}
+	for i, key := range spec.keys {
+		entries, e := proc.readGroup(sess, key, &spec, ids[i])
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+			return
+		}
+		if (nil != ids[i]) || (len(entries.Nest) > 0) {
+			reads = append(reads, streamRead{key, entries})
+		}
+	}
+	if (len(reads) > 0) || (spec.block < 0) || history {
+		res = newStreamReadsRes(sess, reads)
+		return
+	}
+
+	served := proc.blockOn(sess, spec.keys, spec.block, func(key string, blocked bool) (bool, []string) {
+		entries, e := proc.readGroup(sess, key, &spec, nil)
+		if nil != e {
+			return blockError(e, blocked, &res), nil
+		}
+		if 0 == len(entries.Nest) {
+			return false, nil
+		}
+		res = newStreamReadsRes(sess, []streamRead{{key, entries}})
+		return true, nil
+	})
+	if !served {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	return
+}
+
+// readGroup : Read entries of stream as consumer of group, entries never
+// delivered are read if id is nil, otherwise pending entries of consumer
+// after id are read again
+func (proc *SimpleProc) readGroup(sess *Session, key string, spec *xreadSpec, id *store.StreamID) (*proto.Response, error) {
+	entries := proto.NewResponse(proto.RES_TYPE_MULTI)
+	more := func() bool {
+		return (0 == spec.count) || (int64(len(entries.Nest)) < spec.count)
+	}
+	err := proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		var group *store.ConsumerGroup
+		if nil != stream {
+			group = stream.Group(spec.group)
+		}
+		if nil == group {
+			return stream, noGroupError(key, spec.group, true)
+		}
+		now := proc.nowMs()
+		consumer := group.LookupConsumer(spec.consumer, now)
+
+		if nil == id {
+			start, ok := group.LastID.Next()
+			if !ok {
+				return stream, nil
+			}
+			stream.Range(start, store.MaxStreamID, false, func(entry store.StreamEntry) bool {
+				stream.MarkDelivered(group, entry.ID)
+				if !spec.noack {
+					group.Deliver(entry.ID, consumer, now)
+				}
+				entries.SetResponse(newStreamEntryRes(entry))
+				return more()
+			})
+			if len(entries.Nest) > 0 {
+				consumer.ActiveTime = now
+			}
+			return stream, nil
+		}
+
+		start, ok := id.Next()
+		if !ok {
+			return stream, nil
+		}
+		consumer.RangePending(start, store.MaxStreamID, func(p *store.PendingEntry) bool {
+			if entry, found := stream.Get(p.ID); found {
+				entries.SetResponse(newStreamEntryRes(entry))
+				p.DeliveryTime = now
+				p.DeliveryCount++
+			} else {
+				entries.SetResponse(newDeletedEntryRes(p.ID))
+			}
+			return more()
+		})
+		return stream, nil
+	})
+	return entries, err
+}
+
+// XACK : Acknowledge pending entries of group, reply count of entries acknowledged
+func (proc *SimpleProc) XACK(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	ids, res := parseStreamIDs(req.Params[2:])
+	if nil != res {
+		return
+	}
+
+	count := 0
+	e := proc.updateStream(sess, req.Params[0], func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			return nil, nil
+		}
+		if group := stream.Group(req.Params[1]); nil != group {
+			for _, id := range ids {
+				if group.Ack(id) {
+					count++
+				}
+			}
+		}
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(count)
+	return
+}
+
+// XPENDING : Get summary of pending entries of group, or pending entries in
+// range with their consumers, idle time and delivery count
+func (proc *SimpleProc) XPENDING(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key, name := req.Params[0], req.Params[1]
+	args := req.Params[2:]
+	extended := len(args) > 0
+	minIdle := int64(0)
+	var start, end store.StreamID
+	var count int64
+	consumerName, hasConsumer := "", false
+	if extended {
+		if (len(args) >= 2) && ("IDLE" == strings.ToUpper(args[0])) {
+			var ok bool
+			if minIdle, ok = parseInt(args[1]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			args = args[2:]
+		}
+		if (len(args) < 3) || (len(args) > 4) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		if start, res = parseRangeStart(args[0]); nil != res {
+			return
+		}
+		if end, res = parseRangeEnd(args[1]); nil != res {
+			return
+		}
+		var ok bool
+		if count, ok = parseInt(args[2]); !ok {
+			res = proto.NewErrorRes(ERR_NOT_INTEGER)
+			return
+		}
+		if count < 0 {
+			count = 0
+		}
+		if 4 == len(args) {
+			consumerName, hasConsumer = args[3], true
+		}
+	}
+
+	e := proc.viewStream(sess, key, func(stream *store.Stream) {
+		var group *store.ConsumerGroup
+		if nil != stream {
+			group = stream.Group(name)
+		}
+		if nil == group {
+			res = proto.NewErrorRes(noGroupError(key, name, false).Error())
+			return
+		}
+
+		if !extended {
+			res = newPendingSummaryRes(group)
+			return
+		}
+		now := proc.nowMs()
+		res = proto.NewResponse(proto.RES_TYPE_MULTI)
+		collect := func(p *store.PendingEntry) bool {
+			if int64(len(res.Nest)) >= count {
+				return false
+			}
+			if idle := now - p.DeliveryTime; idle >= minIdle {
+				item := proto.NewResponse(proto.RES_TYPE_MULTI)
+				item.SetResponse(newBulkRes(p.ID.String()))
+				item.SetResponse(newBulkRes(p.Consumer.Name))
+				item.SetResponse(newIntRes(int(idle)))
+				item.SetResponse(newIntRes(int(p.DeliveryCount)))
+				res.SetResponse(item)
+			}
+			return true
+		}
+		if !hasConsumer {
+			group.RangePending(start, end, collect)
+		} else if consumer := group.Consumer(consumerName); nil != consumer {
+			consumer.RangePending(start, end, collect)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// newPendingSummaryRes : Reply count of pending entries, the least and the
+// greatest ID and count of pending entries of each consumer
+func newPendingSummaryRes(group *store.ConsumerGroup) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newIntRes(group.PendingLen()))
+	if 0 == group.PendingLen() {
+		res.SetResponse(proto.NewNullRes(proto.RES_TYPE_BULK))
+		res.SetResponse(proto.NewNullRes(proto.RES_TYPE_BULK))
+		res.SetResponse(proto.NewNullRes(proto.RES_TYPE_MULTI))
+		return res
+	}
+
+	var first, last store.StreamID
+	group.RangePending(store.StreamID{}, store.MaxStreamID, func(p *store.PendingEntry) bool {
+		if first.IsZero() {
+			first = p.ID
+		}
+		last = p.ID
+		return true
+	})
+	res.SetResponse(newBulkRes(first.String()))
+	res.SetResponse(newBulkRes(last.String()))
+	consumers := proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, consumer := range group.Consumers() {
+		if 0 == consumer.PendingLen() {
+			continue
+		}
+		item := proto.NewResponse(proto.RES_TYPE_MULTI)
+		item.SetResponse(newBulkRes(consumer.Name))
+		item.SetResponse(newBulkRes(strconv.Itoa(consumer.PendingLen())))
+		consumers.SetResponse(item)
+	}
+	res.SetResponse(consumers)
+	return res
+}
+
+// XCLAIM : Change owner of pending entries idle for at least min-idle-time
+// to consumer, reply entries claimed
+func (proc *SimpleProc) XCLAIM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key, name, consumerName := req.Params[0], req.Params[1], req.Params[2]
+	minIdle, ok := parseInt(req.Params[3])
+	if !ok {
+		res = proto.NewErrorRes("ERR Invalid min-idle-time argument for XCLAIM")
+		return
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+
+	// IDs are followed by options
+	ids := []store.StreamID{}
+	i := 4
+	for ; i < len(req.Params); i++ {
+		id, ok := store.ParseStreamID(req.Params[i], 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if 0 == len(ids) {
+		res = proto.NewErrorRes(ERR_INVALID_STREAM_ID)
+		return
+	}
+
+	now := proc.nowMs()
+	deliveryTime, retryCount := now, int64(-1)
+	force, justID := false, false
+	var lastID *store.StreamID
+	for ; i < len(req.Params); i++ {
+		option := strings.ToUpper(req.Params[i])
+		hasValue := i+1 < len(req.Params)
+		var value int64
+		switch {
+		case "FORCE" == option:
+			force = true
+		case "JUSTID" == option:
+			justID = true
+		case ("IDLE" == option) && hasValue:
+			i++
+			if value, ok = parseInt(req.Params[i]); !ok {
+				res = proto.NewErrorRes("ERR Invalid IDLE option argument for XCLAIM")
+				return
+			}
+			deliveryTime = now - value
+		case ("TIME" == option) && hasValue:
+			i++
+			if value, ok = parseInt(req.Params[i]); !ok {
+				res = proto.NewErrorRes("ERR Invalid TIME option argument for XCLAIM")
+				return
+			}
+			deliveryTime = value
+		case ("RETRYCOUNT" == option) && hasValue:
+			i++
+			if retryCount, ok = parseInt(req.Params[i]); !ok {
+				res = proto.NewErrorRes("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+				return
+			}
+		case ("LASTID" == option) && hasValue:
+			i++
+			id, r := parseStreamID(req.Params[i])
+			if nil != r {
+				res = r
+				return
+			}
+			lastID = &id
+		default:
+			res = proto.NewErrorRes("ERR Unrecognized XCLAIM option '" + req.Params[i] + "'")
+			return
+		}
+	}
+	if (deliveryTime < 0) || (deliveryTime > now) {
+		deliveryTime = now
+	}
+
+	claimed := proto.NewResponse(proto.RES_TYPE_MULTI)
+	e := proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		var group *store.ConsumerGroup
+		if nil != stream {
+			group = stream.Group(name)
+		}
+		if nil == group {
+			return stream, noGroupError(key, name, false)
+		}
+		if (nil != lastID) && group.LastID.Less(*lastID) {
+			group.LastID = *lastID
+		}
+
+		consumer := group.LookupConsumer(consumerName, now)
+		for _, id := range ids {
+			p := group.Pending(id)
+			entry, exists := stream.Get(id)
+			if (nil == p) && (!force || !exists) {
+				continue
+			}
+			if nil == p {
+				p = group.Claim(id, consumer)
+				p.DeliveryCount = 1
+			} else if !exists {
+				// Entry deleted from stream is removed from pending entries
+				group.Ack(id)
+				continue
+			} else if (minIdle > 0) && (now-p.DeliveryTime < minIdle) {
+				continue
+			} else {
+				group.Claim(id, consumer)
+			}
+
+			p.DeliveryTime = deliveryTime
+			if retryCount >= 0 {
+				p.DeliveryCount = retryCount
+			} else if !justID {
+				p.DeliveryCount++
+			}
+			consumer.ActiveTime = now
+			if justID {
+				claimed.SetResponse(newBulkRes(id.String()))
+			} else {
+				claimed.SetResponse(newStreamEntryRes(entry))
+			}
+		}
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = claimed
+	return
+}
+
+// XAUTOCLAIM : Claim pending entries idle for at least min-idle-time from
+// start like XCLAIM, reply cursor of the next call, entries claimed and IDs
+// of entries no longer in stream
+func (proc *SimpleProc) XAUTOCLAIM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key, name, consumerName := req.Params[0], req.Params[1], req.Params[2]
+	minIdle, ok := parseInt(req.Params[3])
+	if !ok {
+		res = proto.NewErrorRes("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+		return
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+	start, res := parseRangeStart(req.Params[4])
+	if nil != res {
+		return
+	}
+
+	count, justID := int64(100), false
+	for i := 5; i < len(req.Params); i++ {
+		option := strings.ToUpper(req.Params[i])
+		if ("COUNT" == option) && (i+1 < len(req.Params)) {
+			i++
+			if count, ok = parseInt(req.Params[i]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if (count < 1) || (count > (1<<63-1)/10) {
+				res = proto.NewErrorRes("ERR COUNT must be > 0")
+				return
+			}
+		} else if "JUSTID" == option {
+			justID = true
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+
+	now := proc.nowMs()
+	claimed := proto.NewResponse(proto.RES_TYPE_MULTI)
+	deleted := proto.NewResponse(proto.RES_TYPE_MULTI)
+	cursor := store.StreamID{}
+	e := proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		var group *store.ConsumerGroup
+		if nil != stream {
+			group = stream.Group(name)
+		}
+		if nil == group {
+			return stream, noGroupError(key, name, false)
+		}
+
+		consumer := group.LookupConsumer(consumerName, now)
+		attempts, left := count*10, count
+		group.RangePending(start, store.MaxStreamID, func(p *store.PendingEntry) bool {
+			if (0 == attempts) || (0 == left) {
+				cursor = p.ID
+				return false
+			}
+			attempts--
+			entry, exists := stream.Get(p.ID)
+			if !exists {
+				group.Ack(p.ID)
+				deleted.SetResponse(newBulkRes(p.ID.String()))
+				return true
+			}
+			if (minIdle > 0) && (now-p.DeliveryTime < minIdle) {
+				return true
+			}
+			group.Claim(p.ID, consumer)
+			p.DeliveryTime = now
+			if !justID {
+				p.DeliveryCount++
+			}
+			consumer.ActiveTime = now
+			if justID {
+				claimed.SetResponse(newBulkRes(p.ID.String()))
+			} else {
+				claimed.SetResponse(newStreamEntryRes(entry))
+			}
+			left--
+			return true
+		})
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newBulkRes(cursor.String()))
+	res.SetResponse(claimed)
+	res.SetResponse(deleted)
+	return
+}
+
+// errNoStreamKey : Error of XGROUP on key not exist
+var errNoStreamKey = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+
+// xgroupCreate : XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD entries-read],
+// create consumer group which delivers entries after id
+func (proc *SimpleProc) xgroupCreate(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key, name := req.Params[1], req.Params[2]
+	mkstream, entriesRead := false, int64(store.INVALID_ENTRIES_READ)
+	for i := 4; i < len(req.Params); i++ {
+		option := strings.ToUpper(req.Params[i])
+		if "MKSTREAM" == option {
+			mkstream = true
+		} else if ("ENTRIESREAD" == option) && (i+1 < len(req.Params)) {
+			i++
+			if entriesRead, res = parseEntriesRead(req.Params[i]); nil != res {
+				return
+			}
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+	lastID, useLast := store.StreamID{}, "$" == req.Params[3]
+	if !useLast {
+		if lastID, res = parseStreamID(req.Params[3]); nil != res {
+			return
+		}
+	}
+
+	e := proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			if !mkstream {
+				return nil, errNoStreamKey
+			}
+			stream = store.NewStream()
+		}
+		if useLast {
+			lastID = stream.LastID()
+		}
+		if nil == stream.CreateGroup(name, lastID, entriesRead) {
+			return nil, errors.New("BUSYGROUP Consumer Group name already exists")
+		}
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xgroup-create", key)
+	res = newStatusRes("OK")
+	return
+}
+
+// xgroupSetID : XGROUP SETID key group id|$ [ENTRIESREAD entries-read],
+// set the last delivered ID of consumer group
+func (proc *SimpleProc) xgroupSetID(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key, name := req.Params[1], req.Params[2]
+	entriesRead := int64(store.INVALID_ENTRIES_READ)
+	if len(req.Params) > 4 {
+		if (6 != len(req.Params)) || ("ENTRIESREAD" != strings.ToUpper(req.Params[4])) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		if entriesRead, res = parseEntriesRead(req.Params[5]); nil != res {
+			return
+		}
+	}
+	lastID, useLast := store.StreamID{}, "$" == req.Params[3]
+	if !useLast {
+		if lastID, res = parseStreamID(req.Params[3]); nil != res {
+			return
+		}
+	}
+
+	e := proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			return nil, errNoStreamKey
+		}
+		group := stream.Group(name)
+		if nil == group {
+			return stream, noGroupError(key, name, false)
+		}
+		if useLast {
+			lastID = stream.LastID()
+		}
+		group.LastID, group.EntriesRead = lastID, entriesRead
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xgroup-setid", key)
+	res = newStatusRes("OK")
+	return
+}
+
+// xgroupDestroy : XGROUP DESTROY key group, delete consumer group
+func (proc *SimpleProc) xgroupDestroy(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	destroyed := false
+	e := proc.updateStream(sess, req.Params[1], func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			return nil, errNoStreamKey
+		}
+		destroyed = stream.DestroyGroup(req.Params[2])
+		return stream, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if destroyed {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xgroup-destroy", req.Params[1])
+	}
+	res = newBoolIntRes(destroyed)
+	return
+}
+
+// xgroupCreateConsumer : XGROUP CREATECONSUMER key group consumer, create
+// consumer in group
+func (proc *SimpleProc) xgroupCreateConsumer(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	created := false
+	e := proc.updateGroup(sess, req.Params[1], req.Params[2], func(group *store.ConsumerGroup) {
+		created = nil != group.CreateConsumer(req.Params[3], proc.nowMs())
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if created {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xgroup-createconsumer", req.Params[1])
+	}
+	res = newBoolIntRes(created)
+	return
+}
+
+// xgroupDelConsumer : XGROUP DELCONSUMER key group consumer, delete consumer
+// and its pending entries, reply count of pending entries deleted
+func (proc *SimpleProc) xgroupDelConsumer(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count, deleted := 0, false
+	e := proc.updateGroup(sess, req.Params[1], req.Params[2], func(group *store.ConsumerGroup) {
+		count, deleted = group.DeleteConsumer(req.Params[3])
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if deleted {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STREAM, "xgroup-delconsumer", req.Params[1])
+	}
+	res = newIntRes(count)
+	return
+}
+
+// updateGroup : Call function with consumer group of stream under write lock
+func (proc *SimpleProc) updateGroup(sess *Session, key string, name string, function func(group *store.ConsumerGroup)) error {
+	return proc.updateStream(sess, key, func(stream *store.Stream) (*store.Stream, error) {
+		if nil == stream {
+			return nil, errNoStreamKey
+		}
+		group := stream.Group(name)
+		if nil == group {
+			return stream, noGroupError(key, name, false)
+		}
+		function(group)
+		return stream, nil
+	})
+}
+
+// xinfoStream : XINFO STREAM key [FULL [COUNT count]], get information of
+// stream, FULL replies entries and groups with their pending entries too
+func (proc *SimpleProc) xinfoStream(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	full, count := false, int64(10)
+	if len(req.Params) > 2 {
+		if "FULL" != strings.ToUpper(req.Params[2]) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		full = true
+		if len(req.Params) > 3 {
+			if (5 != len(req.Params)) || ("COUNT" != strings.ToUpper(req.Params[3])) {
+				res = proto.NewErrorRes(ERR_SYNTAX)
+				return
+			}
+			var ok bool
+			if count, ok = parseInt(req.Params[4]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if count < 0 {
+				count = 10
+			}
+		}
+	}
+
+	e := proc.viewStream(sess, req.Params[1], func(stream *store.Stream) {
+		if nil == stream {
+			res = proto.NewErrorRes("ERR no such key")
+			return
+		}
+		res = proto.NewResponse(proto.RES_TYPE_MAP)
+		treeKeys := (stream.Len() + STREAM_NODE_MAX_ENTRIES - 1) / STREAM_NODE_MAX_ENTRIES
+		res.SetPair(newBulkRes("length"), newIntRes(stream.Len()))
+		res.SetPair(newBulkRes("radix-tree-keys"), newIntRes(treeKeys))
+		res.SetPair(newBulkRes("radix-tree-nodes"), newIntRes(treeKeys+1))
+		res.SetPair(newBulkRes("last-generated-id"), newBulkRes(stream.LastID().String()))
+		res.SetPair(newBulkRes("max-deleted-entry-id"), newBulkRes(stream.MaxDeletedID().String()))
+		res.SetPair(newBulkRes("entries-added"), newIntRes(int(stream.EntriesAdded())))
+		res.SetPair(newBulkRes("recorded-first-entry-id"), newBulkRes(stream.FirstID().String()))
+
+		if !full {
+			res.SetPair(newBulkRes("groups"), newIntRes(len(stream.Groups())))
+			for _, field := range []string{"first-entry", "last-entry"} {
+				entry, ok := stream.First()
+				if "last-entry" == field {
+					entry, ok = stream.Last()
+				}
+				if ok {
+					res.SetPair(newBulkRes(field), newStreamEntryRes(entry))
+				} else {
+					res.SetPair(newBulkRes(field), proto.NewNullRes(proto.RES_TYPE_BULK))
+				}
+			}
+			return
+		}
+
+		entries := proto.NewResponse(proto.RES_TYPE_MULTI)
+		stream.Range(store.StreamID{}, store.MaxStreamID, false, func(entry store.StreamEntry) bool {
+			if (count > 0) && (int64(len(entries.Nest)) >= count) {
+				return false
+			}
+			entries.SetResponse(newStreamEntryRes(entry))
+			return true
+		})
+		res.SetPair(newBulkRes("entries"), entries)
+		groups := proto.NewResponse(proto.RES_TYPE_MULTI)
+		for _, group := range stream.Groups() {
+			groups.SetResponse(newGroupFullRes(stream, group, count))
+		}
+		res.SetPair(newBulkRes("groups"), groups)
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// newGroupFullRes : Consumer group replied by XINFO STREAM FULL
+func newGroupFullRes(stream *store.Stream, group *store.ConsumerGroup, count int64) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MAP)
+	res.SetPair(newBulkRes("name"), newBulkRes(group.Name))
+	res.SetPair(newBulkRes("last-delivered-id"), newBulkRes(group.LastID.String()))
+	res.SetPair(newBulkRes("entries-read"), newEntriesReadRes(group.EntriesRead))
+	res.SetPair(newBulkRes("lag"), newLagRes(stream, group))
+	res.SetPair(newBulkRes("pel-count"), newIntRes(group.PendingLen()))
+
+	pending := proto.NewResponse(proto.RES_TYPE_MULTI)
+	group.RangePending(store.StreamID{}, store.MaxStreamID, func(p *store.PendingEntry) bool {
+		if (count > 0) && (int64(len(pending.Nest)) >= count) {
+			return false
+		}
+		item := proto.NewResponse(proto.RES_TYPE_MULTI)
+		item.SetResponse(newBulkRes(p.ID.String()))
+		item.SetResponse(newBulkRes(p.Consumer.Name))
+		item.SetResponse(newIntRes(int(p.DeliveryTime)))
+		item.SetResponse(newIntRes(int(p.DeliveryCount)))
+		pending.SetResponse(item)
+		return true
+	})
+	res.SetPair(newBulkRes("pending"), pending)
+
+	consumers := proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, consumer := range group.Consumers() {
+		item := proto.NewResponse(proto.RES_TYPE_MAP)
+		item.SetPair(newBulkRes("name"), newBulkRes(consumer.Name))
+		item.SetPair(newBulkRes("seen-time"), newIntRes(int(consumer.SeenTime)))
+		item.SetPair(newBulkRes("active-time"), newIntRes(int(consumer.ActiveTime)))
+		item.SetPair(newBulkRes("pel-count"), newIntRes(consumer.PendingLen()))
+		consumerPending := proto.NewResponse(proto.RES_TYPE_MULTI)
+		consumer.RangePending(store.StreamID{}, store.MaxStreamID, func(p *store.PendingEntry) bool {
+			if (count > 0) && (int64(len(consumerPending.Nest)) >= count) {
+				return false
+			}
+			entry := proto.NewResponse(proto.RES_TYPE_MULTI)
+			entry.SetResponse(newBulkRes(p.ID.String()))
+			entry.SetResponse(newIntRes(int(p.DeliveryTime)))
+			entry.SetResponse(newIntRes(int(p.DeliveryCount)))
+			consumerPending.SetResponse(entry)
+			return true
+		})
+		item.SetPair(newBulkRes("pending"), consumerPending)
+		consumers.SetResponse(item)
+	}
+	res.SetPair(newBulkRes("consumers"), consumers)
+	return res
+}
+
+// xinfoGroups : XINFO GROUPS key, get consumer groups of stream
+func (proc *SimpleProc) xinfoGroups(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	e := proc.viewStream(sess, req.Params[1], func(stream *store.Stream) {
+		if nil == stream {
+			res = proto.NewErrorRes("ERR no such key")
+			return
+		}
+		res = proto.NewResponse(proto.RES_TYPE_MULTI)
+		for _, group := range stream.Groups() {
+			item := proto.NewResponse(proto.RES_TYPE_MAP)
+			item.SetPair(newBulkRes("name"), newBulkRes(group.Name))
+			item.SetPair(newBulkRes("consumers"), newIntRes(len(group.Consumers())))
+			item.SetPair(newBulkRes("pending"), newIntRes(group.PendingLen()))
+			item.SetPair(newBulkRes("last-delivered-id"), newBulkRes(group.LastID.String()))
+			item.SetPair(newBulkRes("entries-read"), newEntriesReadRes(group.EntriesRead))
+			item.SetPair(newBulkRes("lag"), newLagRes(stream, group))
+			res.SetResponse(item)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// xinfoConsumers : XINFO CONSUMERS key group, get consumers of group
+func (proc *SimpleProc) xinfoConsumers(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	key, name := req.Params[1], req.Params[2]
+	e := proc.viewStream(sess, key, func(stream *store.Stream) {
+		if nil == stream {
+			res = proto.NewErrorRes("ERR no such key")
+			return
+		}
+		group := stream.Group(name)
+		if nil == group {
+			res = proto.NewErrorRes(noGroupError(key, name, false).Error())
+			return
+		}
+		now := proc.nowMs()
+		res = proto.NewResponse(proto.RES_TYPE_MULTI)
+		for _, consumer := range group.Consumers() {
+			inactive := int64(-1)
+			if consumer.ActiveTime >= 0 {
+				inactive = now - consumer.ActiveTime
+			}
+			item := proto.NewResponse(proto.RES_TYPE_MAP)
+			item.SetPair(newBulkRes("name"), newBulkRes(consumer.Name))
+			item.SetPair(newBulkRes("pending"), newIntRes(consumer.PendingLen()))
+			item.SetPair(newBulkRes("idle"), newIntRes(int(now-consumer.SeenTime)))
+			item.SetPair(newBulkRes("inactive"), newIntRes(int(inactive)))
+			res.SetResponse(item)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// noGroupError : Error of consumer group not exist, XREADGROUP has its own message
+func noGroupError(key string, group string, xreadgroup bool) error {
+	if xreadgroup {
+		return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+	}
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+// parseStreamID : Parse ID of entry, sequence number is 0 if it is omitted
+func parseStreamID(str string) (store.StreamID, *proto.Response) {
+	id, ok := store.ParseStreamID(str, 0)
+	if !ok {
+		return id, proto.NewErrorRes(ERR_INVALID_STREAM_ID)
+	}
+	return id, nil
+}
+
+// parseStreamIDs : Parse IDs of entries
+func parseStreamIDs(strs []string) ([]store.StreamID, *proto.Response) {
+	ids := make([]store.StreamID, 0, len(strs))
+	for _, str := range strs {
+		id, res := parseStreamID(str)
+		if nil != res {
+			return nil, res
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseRangeStart : Parse start of ID range, `-` is the least ID and `(`
+// prefix means exclusive
+func parseRangeStart(str string) (store.StreamID, *proto.Response) {
+	switch {
+	case "-" == str:
+		return store.StreamID{}, nil
+	case "+" == str:
+		return store.MaxStreamID, nil
+	case strings.HasPrefix(str, "("):
+		id, res := parseStreamID(str[1:])
+		if nil != res {
+			return id, res
+		}
+		if id, ok := id.Next(); ok {
+			return id, nil
+		}
+		return id, proto.NewErrorRes("ERR invalid start ID for the interval")
+	}
+	return parseStreamID(str)
+}
+
+// parseRangeEnd : Parse end of ID range, `+` is the greatest ID, `(` prefix
+// means exclusive and sequence number is the greatest if it is omitted
+func parseRangeEnd(str string) (store.StreamID, *proto.Response) {
+	switch {
+	case "-" == str:
+		return store.StreamID{}, nil
+	case "+" == str:
+		return store.MaxStreamID, nil
+	}
+	exclusive := strings.HasPrefix(str, "(")
+	if exclusive {
+		str = str[1:]
+	}
+	id, ok := store.ParseStreamID(str, store.MaxStreamID.Seq)
+	if !ok {
+		return id, proto.NewErrorRes(ERR_INVALID_STREAM_ID)
+	}
+	if exclusive {
+		if id, ok = id.Prev(); !ok {
+			return id, proto.NewErrorRes("ERR invalid end ID for the interval")
+		}
+	}
+	return id, nil
+}
+
+// parseEntriesRead : Parse ENTRIESREAD of XGROUP, -1 means unknown
+func parseEntriesRead(str string) (int64, *proto.Response) {
+	value, ok := parseInt(str)
+	if !ok {
+		return 0, proto.NewErrorRes(ERR_NOT_INTEGER)
+	}
+	if (value < 0) && (store.INVALID_ENTRIES_READ != value) {
+		return 0, proto.NewErrorRes("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return value, nil
+}
+
+// indexOfKey : Index of the first key equal to key
+func indexOfKey(keys []string, key string) int {
+	for i, item := range keys {
+		if item == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// newStreamEntryRes : Entry is replied as [id, [field, value, ...]]
+func newStreamEntryRes(entry store.StreamEntry) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newBulkRes(entry.ID.String()))
+	res.SetResponse(proto.NewBulkListRes(proto.RES_TYPE_MULTI, entry.Fields))
+	return res
+}
+
+// newDeletedEntryRes : Pending entry deleted from stream is replied as [id, nil]
+func newDeletedEntryRes(id store.StreamID) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	res.SetResponse(newBulkRes(id.String()))
+	res.SetResponse(proto.NewNullRes(proto.RES_TYPE_MULTI))
+	return res
+}
+
+// newStreamReadsRes : Entries of streams are replied as a map of key to
+// entries in RESP3 and an array of [key, entries] in RESP2, null if nothing
+// is read
+func newStreamReadsRes(sess *Session, reads []streamRead) *proto.Response {
+	if 0 == len(reads) {
+		return proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	if proto.PROTO_RESP3 == sess.ProtoVer {
+		res := proto.NewResponse(proto.RES_TYPE_MAP)
+		for _, read := range reads {
+			res.SetPair(newBulkRes(read.key), read.entries)
+		}
+		return res
+	}
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, read := range reads {
+		item := proto.NewResponse(proto.RES_TYPE_MULTI)
+		item.SetResponse(newBulkRes(read.key))
+		item.SetResponse(read.entries)
+		res.SetResponse(item)
+	}
+	return res
+}
+
+func newEntriesReadRes(entriesRead int64) *proto.Response {
+	if store.INVALID_ENTRIES_READ == entriesRead {
+		return proto.NewNullRes(proto.RES_TYPE_BULK)
+	}
+	return newIntRes(int(entriesRead))
+}
+
+func newLagRes(stream *store.Stream, group *store.ConsumerGroup) *proto.Response {
+	lag, ok := stream.Lag(group)
+	if !ok {
+		return proto.NewNullRes(proto.RES_TYPE_BULK)
+	}
+	return newIntRes(int(lag))
+}
+
+func registerStreamCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "xadd", Func: simpleCmd((*SimpleProc).XADD), Arity: -5, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Appends a new message to a stream. Creates the key if it doesn't exist."},
+		&Command{Name: "xlen", Func: simpleCmd((*SimpleProc).XLEN), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Return the number of messages in a stream."},
+		&Command{Name: "xrange", Func: simpleCmd((*SimpleProc).XRANGE), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns the messages from a stream within a range of IDs."},
+		&Command{Name: "xrevrange", Func: simpleCmd((*SimpleProc).XREVRANGE), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns the messages from a stream within a range of IDs in reverse order."},
+		&Command{Name: "xdel", Func: simpleCmd((*SimpleProc).XDEL), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns the number of messages after removing them from a stream."},
+		&Command{Name: "xtrim", Func: simpleCmd((*SimpleProc).XTRIM), Arity: -4, Flags: CMD_WRITE, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Deletes messages from the beginning of a stream."},
+		&Command{Name: "xread", Func: simpleCmd((*SimpleProc).XREAD), Arity: -4, Flags: CMD_READONLY | CMD_BLOCKING, KeysFunc: streamsKeys,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise."},
+		&Command{Name: "xreadgroup", Func: simpleCmd((*SimpleProc).XREADGROUP), Arity: -7, Flags: CMD_WRITE | CMD_BLOCKING, KeysFunc: streamsKeys,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise."},
+		&Command{Name: "xack", Func: simpleCmd((*SimpleProc).XACK), Arity: -4, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream."},
+		&Command{Name: "xpending", Func: simpleCmd((*SimpleProc).XPENDING), Arity: -3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns the information and entries from a stream consumer group's pending entries list."},
+		&Command{Name: "xclaim", Func: simpleCmd((*SimpleProc).XCLAIM), Arity: -6, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member."},
+		&Command{Name: "xautoclaim", Func: simpleCmd((*SimpleProc).XAUTOCLAIM), Arity: -6, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "6.2.0", Summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member."},
+		&Command{Name: "xgroup|create", Func: simpleCmd((*SimpleProc).xgroupCreate), Arity: -5, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Creates a consumer group."},
+		&Command{Name: "xgroup|setid", Func: simpleCmd((*SimpleProc).xgroupSetID), Arity: -5, Flags: CMD_WRITE, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Sets the last-delivered ID of a consumer group."},
+		&Command{Name: "xgroup|destroy", Func: simpleCmd((*SimpleProc).xgroupDestroy), Arity: 4, Flags: CMD_WRITE, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Destroys a consumer group."},
+		&Command{Name: "xgroup|createconsumer", Func: simpleCmd((*SimpleProc).xgroupCreateConsumer), Arity: 5, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "6.2.0", Summary: "Creates a consumer in a consumer group."},
+		&Command{Name: "xgroup|delconsumer", Func: simpleCmd((*SimpleProc).xgroupDelConsumer), Arity: 5, Flags: CMD_WRITE, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Deletes a consumer from a consumer group."},
+		&Command{Name: "xinfo|stream", Func: simpleCmd((*SimpleProc).xinfoStream), Arity: -3, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns information about a stream."},
+		&Command{Name: "xinfo|groups", Func: simpleCmd((*SimpleProc).xinfoGroups), Arity: 3, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns a list of the consumer groups of a stream."},
+		&Command{Name: "xinfo|consumers", Func: simpleCmd((*SimpleProc).xinfoConsumers), Arity: 4, Flags: CMD_READONLY, FirstKey: 2, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STREAM, Since: "5.0.0", Summary: "Returns a list of the consumers in a consumer group."},
+	)
+}
@@ -0,0 +1,726 @@
+package processor
+
+import (
+	"errors"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MAX_STRING_SIZE : Max size of string value, proto-max-bulk-len of redis
+const MAX_STRING_SIZE = 512 * 1024 * 1024
+
+// Options of string commands allowed by parseStringOptions, expire options
+// EX, PX, EXAT and PXAT are always allowed
+const (
+	strOptCondition = 1 << iota // NX and XX
+	strOptGet                   // GET
+	strOptKeepTTL               // KEEPTTL
+	strOptPersist               // PERSIST
+)
+
+// setExpireUnits : Milliseconds of unit of expire options, options ending
+// with AT are unix time
+var setExpireUnits = map[string]int64{"EX": 1000, "PX": 1, "EXAT": 1000, "PXAT": 1}
+
+// stringOptions : Options of SET and GETEX
+type stringOptions struct {
+	nx        bool
+	xx        bool
+	get       bool
+	keepTTL   bool
+	persist   bool
+	hasExpire bool
+	expireAt  int64 // Unix time in milliseconds
+}
+
+// parseStringOptions : Parse options of SET and GETEX
+func parseStringOptions(params []string, allowed int, now int64, name string) (opts stringOptions, res *proto.Response) {
+	for i := 0; i < len(params); i++ {
+		option := strings.ToUpper(params[i])
+		unit := setExpireUnits[option]
+		noExpire := !opts.hasExpire && !opts.keepTTL && !opts.persist
+		if ("NX" == option) && (0 != allowed&strOptCondition) && !opts.xx {
+			opts.nx = true
+		} else if ("XX" == option) && (0 != allowed&strOptCondition) && !opts.nx {
+			opts.xx = true
+		} else if ("GET" == option) && (0 != allowed&strOptGet) {
+			opts.get = true
+		} else if ("KEEPTTL" == option) && (0 != allowed&strOptKeepTTL) && noExpire {
+			opts.keepTTL = true
+		} else if ("PERSIST" == option) && (0 != allowed&strOptPersist) && noExpire {
+			opts.persist = true
+		} else if (0 != unit) && noExpire && (i+1 < len(params)) {
+			if value, ok := parseInt(params[i+1]); ok && (value <= 0) {
+				res = proto.NewErrorRes("ERR invalid expire time in '" + name + "' command")
+				return
+			}
+			opts.expireAt, res = parseExpireTime(params[i+1], unit, strings.HasSuffix(option, "AT"), now, name)
+			if nil != res {
+				return
+			}
+			opts.hasExpire = true
+			i++
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+	return
+}
+
+// stringValue : Get value of string object, ErrWrongType if it holds other type
+func stringValue(obj *store.Object) (value string, ok bool, err error) {
+	if nil == obj {
+		return
+	}
+	if store.TYPE_STRING != obj.Type {
+		err = store.ErrWrongType
+		return
+	}
+	return obj.Value.(string), true, nil
+}
+
+// setGeneric : Set value of key according to options, old value is got only
+// if GET option is set
+func (proc *SimpleProc) setGeneric(sess *Session, key string, value string, opts stringOptions) (applied bool, old string, hasOld bool, err error) {
+	err = proc.GetKeyspace(sess).Mutate(key, func(obj *store.Object) (*store.Object, error) {
+		if opts.get {
+			var e error
+			if old, hasOld, e = stringValue(obj); nil != e {
+				return obj, e
+			}
+		}
+		if (opts.nx && (nil != obj)) || (opts.xx && (nil == obj)) {
+			return obj, nil
+		}
+		applied = true
+		newObj := &store.Object{Type: store.TYPE_STRING, Value: value, ExpireAt: opts.expireAt}
+		if opts.keepTTL && (nil != obj) {
+			newObj.ExpireAt = obj.ExpireAt
+		}
+		return newObj, nil
+	})
+	if applied {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STRING, "set", key)
+		if opts.hasExpire {
+			proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "expire", key)
+		}
+	}
+	return
+}
+
+// GET : Get value of string key
+func (proc *SimpleProc) GET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	k := req.Params[0]
+	v, ok, e := proc.GetKeyspace(sess).GetString(k)
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	if ok {
+		res.SetString(v)
+	}
+	return
+}
+
+// SET : Set value of string key, options NX and XX set it conditionally, GET
+// replies old value, EX, PX, EXAT, PXAT and KEEPTTL set expire time of key
+func (proc *SimpleProc) SET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	allowed := strOptCondition | strOptGet | strOptKeepTTL
+	opts, res := parseStringOptions(req.Params[2:], allowed, proc.nowMs(), "set")
+	if nil != res {
+		return
+	}
+
+	applied, old, hasOld, e := proc.setGeneric(sess, req.Params[0], req.Params[1], opts)
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	} else if opts.get {
+		res = newNullableBulkRes(old, hasOld)
+	} else if applied {
+		res = newStatusRes("OK")
+	} else {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	}
+	return
+}
+
+// SETNX : Set value of key only if it does not exist
+func (proc *SimpleProc) SETNX(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	applied, _, _, _ := proc.setGeneric(sess, req.Params[0], req.Params[1], stringOptions{nx: true})
+	res = newBoolIntRes(applied)
+	return
+}
+
+// SETEX : Set value and expire time in seconds of key
+func (proc *SimpleProc) SETEX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setexGeneric(sess, req, "EX", "setex")
+}
+
+// PSETEX : Set value and expire time in milliseconds of key
+func (proc *SimpleProc) PSETEX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.setexGeneric(sess, req, "PX", "psetex")
+}
+
+func (proc *SimpleProc) setexGeneric(sess *Session, req *proto.Request, option string, name string) (res *proto.Response, err error) {
+	params := []string{option, req.Params[1]}
+	opts, res := parseStringOptions(params, 0, proc.nowMs(), name)
+	if nil != res {
+		return
+	}
+	proc.setGeneric(sess, req.Params[0], req.Params[2], opts)
+	res = newStatusRes("OK")
+	return
+}
+
+// GETSET : Set value of key and reply the old one
+func (proc *SimpleProc) GETSET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	_, old, hasOld, e := proc.setGeneric(sess, req.Params[0], req.Params[1], stringOptions{get: true})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newNullableBulkRes(old, hasOld)
+	return
+}
+
+// GETDEL : Get value of key and delete it
+func (proc *SimpleProc) GETDEL(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	var value string
+	var ok bool
+	e := proc.GetKeyspace(sess).Mutate(req.Params[0], func(obj *store.Object) (*store.Object, error) {
+		var e error
+		if value, ok, e = stringValue(obj); nil != e {
+			return obj, e
+		}
+		return nil, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if ok {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "del", req.Params[0])
+	}
+	res = newNullableBulkRes(value, ok)
+	return
+}
+
+// GETEX : Get value of key and optionally set or remove its expire time
+func (proc *SimpleProc) GETEX(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	opts, res := parseStringOptions(req.Params[1:], strOptPersist, proc.nowMs(), "getex")
+	if nil != res {
+		return
+	}
+
+	var value string
+	var ok bool
+	e := proc.GetKeyspace(sess).Mutate(req.Params[0], func(obj *store.Object) (*store.Object, error) {
+		var e error
+		if value, ok, e = stringValue(obj); (nil != e) || !ok {
+			return obj, e
+		}
+		if opts.hasExpire {
+			obj.ExpireAt = opts.expireAt
+		} else if opts.persist {
+			obj.ExpireAt = 0
+		}
+		return obj, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if ok && opts.hasExpire {
+		// Key is deleted at once if expire time has passed
+		event := "expire"
+		if opts.expireAt <= proc.nowMs() {
+			event = "del"
+		}
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, event, req.Params[0])
+	} else if ok && opts.persist {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_GENERIC, "persist", req.Params[0])
+	}
+	res = newNullableBulkRes(value, ok)
+	return
+}
+
+// MGET : Get values of keys, null for key not exist or not holding a string
+func (proc *SimpleProc) MGET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	ks := proc.GetKeyspace(sess)
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, key := range req.Params {
+		value, ok, e := ks.GetString(key)
+		res.SetResponse(newNullableBulkRes(value, ok && (nil == e)))
+	}
+	return
+}
+
+// MSET : Set values of keys atomically
+func (proc *SimpleProc) MSET(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	proc.msetGeneric(sess, req, false)
+	res = newStatusRes("OK")
+	return
+}
+
+// MSETNX : Set values of keys atomically only if none of the keys exists
+func (proc *SimpleProc) MSETNX(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = newBoolIntRes(proc.msetGeneric(sess, req, true))
+	return
+}
+
+func (proc *SimpleProc) msetGeneric(sess *Session, req *proto.Request, nx bool) (applied bool) {
+	keys := make([]string, 0, len(req.Params)/2)
+	for i := 0; i < len(req.Params); i = i + 2 {
+		keys = append(keys, req.Params[i])
+	}
+	proc.GetKeyspace(sess).MutateMulti(keys, func(objs []*store.Object) ([]*store.Object, error) {
+		if nx {
+			for _, obj := range objs {
+				if nil != obj {
+					return objs, nil
+				}
+			}
+		}
+		applied = true
+		for i := range objs {
+			objs[i] = &store.Object{Type: store.TYPE_STRING, Value: req.Params[i*2+1]}
+		}
+		return objs, nil
+	})
+	if applied {
+		for _, key := range keys {
+			proc.notifyKeyspaceEvent(sess, store.NOTIFY_STRING, "set", key)
+		}
+	}
+	return
+}
+
+// APPEND : Append value to string key, reply length of string after append
+func (proc *SimpleProc) APPEND(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.GetKeyspace(sess).Update(req.Params[0], store.TYPE_STRING, func(value interface{}) (interface{}, error) {
+		old, _ := value.(string)
+		if len(old)+len(req.Params[1]) > MAX_STRING_SIZE {
+			return value, errors.New(ERR_STRING_TOO_LONG)
+		}
+		length = len(old) + len(req.Params[1])
+		return old + req.Params[1], nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_STRING, "append", req.Params[0])
+	res = newIntRes(length)
+	return
+}
+
+// STRLEN : Length of string key, 0 if key does not exist
+func (proc *SimpleProc) STRLEN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	value, _, e := proc.GetKeyspace(sess).GetString(req.Params[0])
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(len(value))
+	return
+}
+
+// GETRANGE : Get substring of string key, negative offsets count from the end
+func (proc *SimpleProc) GETRANGE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	start, ok1 := parseInt(req.Params[1])
+	end, ok2 := parseInt(req.Params[2])
+	if !ok1 || !ok2 {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	value, _, e := proc.GetKeyspace(sess).GetString(req.Params[0])
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+
+	length := int64(len(value))
+	if (start < 0) && (end < 0) && (start > end) {
+		res = newBulkRes("")
+		return
+	}
+	if start < 0 {
+		start = length + start
+	}
+	if end < 0 {
+		end = length + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if (start > end) || (0 == length) {
+		res = newBulkRes("")
+		return
+	}
+	res = newBulkRes(value[start : end+1])
+	return
+}
+
+// SETRANGE : Overwrite part of string key from offset, the string is padded
+// with zero bytes if it is shorter than offset
+func (proc *SimpleProc) SETRANGE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	offset, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	if offset < 0 {
+		res = proto.NewErrorRes("ERR offset is out of range")
+		return
+	}
+	part := req.Params[2]
+
+	length := 0
+	e := proc.GetKeyspace(sess).Update(req.Params[0], store.TYPE_STRING, func(value interface{}) (interface{}, error) {
+		old, _ := value.(string)
+		length = len(old)
+		if 0 == len(part) {
+			// Nothing to write, key is not created if it does not exist
+			return value, nil
+		}
+		if offset+int64(len(part)) > MAX_STRING_SIZE {
+			return value, errors.New(ERR_STRING_TOO_LONG)
+		}
+		end := int(offset) + len(part)
+		buffer := []byte(old)
+		if end > len(buffer) {
+			buffer = append(buffer, make([]byte, end-len(buffer))...)
+		}
+		copy(buffer[offset:], part)
+		length = len(buffer)
+		return string(buffer), nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if len(part) > 0 {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_STRING, "setrange", req.Params[0])
+	}
+	res = newIntRes(length)
+	return
+}
+
+// INCR : Increase integer value of key by one
+func (proc *SimpleProc) INCR(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.incrGeneric(sess, req.Params[0], 1)
+}
+
+// DECR : Decrease integer value of key by one
+func (proc *SimpleProc) DECR(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.incrGeneric(sess, req.Params[0], -1)
+}
+
+// INCRBY : Increase integer value of key by increment
+func (proc *SimpleProc) INCRBY(sess *Session, req *proto.Request) (*proto.Response, error) {
+	incr, ok := parseInt(req.Params[1])
+	if !ok {
+		return proto.NewErrorRes(ERR_NOT_INTEGER), nil
+	}
+	return proc.incrGeneric(sess, req.Params[0], incr)
+}
+
+// DECRBY : Decrease integer value of key by decrement
+func (proc *SimpleProc) DECRBY(sess *Session, req *proto.Request) (*proto.Response, error) {
+	decr, ok := parseInt(req.Params[1])
+	if !ok {
+		return proto.NewErrorRes(ERR_NOT_INTEGER), nil
+	}
+	if math.MinInt64 == decr {
+		return proto.NewErrorRes("ERR decrement would overflow"), nil
+	}
+	return proc.incrGeneric(sess, req.Params[0], -decr)
+}
+
+// incrGeneric : Add incr to integer value of key, key not exist is regarded as 0
+func (proc *SimpleProc) incrGeneric(sess *Session, key string, incr int64) (res *proto.Response, err error) {
+	var result int64
+	e := proc.GetKeyspace(sess).Update(key, store.TYPE_STRING, func(value interface{}) (interface{}, error) {
+		var old int64
+		if str, ok := value.(string); ok {
+			if old, ok = parseInt(str); !ok {
+				return value, errors.New(ERR_NOT_INTEGER)
+			}
+		}
+		if ((incr < 0) && (old < 0) && (incr < math.MinInt64-old)) ||
+			((incr > 0) && (old > 0) && (incr > math.MaxInt64-old)) {
+			return value, errors.New("ERR increment or decrement would overflow")
+		}
+		result = old + incr
+		return strconv.FormatInt(result, 10), nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_STRING, "incrby", key)
+	res = proto.NewResponse(proto.RES_TYPE_INT)
+	res.SetString(strconv.FormatInt(result, 10))
+	return
+}
+
+// INCRBYFLOAT : Increase float value of key by increment
+func (proc *SimpleProc) INCRBYFLOAT(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	incr, ok := parseFloat(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_FLOAT)
+		return
+	}
+
+	var result string
+	e := proc.GetKeyspace(sess).Update(req.Params[0], store.TYPE_STRING, func(value interface{}) (interface{}, error) {
+		var old float64
+		if str, ok := value.(string); ok {
+			if old, ok = parseFloat(str); !ok {
+				return value, errors.New(ERR_NOT_FLOAT)
+			}
+		}
+		sum := old + incr
+		if math.IsNaN(sum) || math.IsInf(sum, 0) {
+			return value, errors.New("ERR increment would produce NaN or Infinity")
+		}
+		result = strconv.FormatFloat(sum, 'f', -1, 64)
+		return result, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyKeyspaceEvent(sess, store.NOTIFY_STRING, "incrbyfloat", req.Params[0])
+	res = newBulkRes(result)
+	return
+}
+
+// LCS : Longest common subsequence of two string keys, options LEN and IDX
+// reply its length or positions of matched ranges instead of the string
+func (proc *SimpleProc) LCS(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	getLen := false
+	getIdx := false
+	withMatchLen := false
+	var minMatchLen int64
+	for i := 2; i < len(req.Params); i++ {
+		option := strings.ToUpper(req.Params[i])
+		if "LEN" == option {
+			getLen = true
+		} else if "IDX" == option {
+			getIdx = true
+		} else if "WITHMATCHLEN" == option {
+			withMatchLen = true
+		} else if ("MINMATCHLEN" == option) && (i+1 < len(req.Params)) {
+			var ok bool
+			if minMatchLen, ok = parseInt(req.Params[i+1]); !ok {
+				res = proto.NewErrorRes(ERR_NOT_INTEGER)
+				return
+			}
+			if minMatchLen < 0 {
+				minMatchLen = 0
+			}
+			i++
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+
+	ks := proc.GetKeyspace(sess)
+	a, _, e1 := ks.GetString(req.Params[0])
+	b, _, e2 := ks.GetString(req.Params[1])
+	if (nil != e1) || (nil != e2) {
+		res = proto.NewErrorRes("ERR The specified keys must contain string values")
+		return
+	}
+	if getLen && getIdx {
+		res = proto.NewErrorRes("ERR If you want both the length and indexes, please just use IDX.")
+		return
+	}
+	if uint64(len(a)+1)*uint64(len(b)+1) >= math.MaxUint32/4 {
+		res = proto.NewErrorRes("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+		return
+	}
+
+	// dp[i][j] is the length of LCS of a[:i] and b[:j]
+	width := len(b) + 1
+	dp := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i*width+j] = dp[(i-1)*width+j-1] + 1
+			} else if dp[(i-1)*width+j] > dp[i*width+j-1] {
+				dp[i*width+j] = dp[(i-1)*width+j]
+			} else {
+				dp[i*width+j] = dp[i*width+j-1]
+			}
+		}
+	}
+	total := dp[len(a)*width+len(b)]
+	if getLen {
+		res = newIntRes(int(total))
+		return
+	}
+
+	// Walk back from the end of both strings, matched ranges are emitted
+	// from the last one to the first one
+	result := make([]byte, total)
+	matches := proto.NewResponse(proto.RES_TYPE_MULTI)
+	idx := total
+	i, j := len(a), len(b)
+	aStart, aEnd, bStart, bEnd := len(a), 0, 0, 0
+	for (i > 0) && (j > 0) {
+		emit := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if aStart == len(a) {
+				aStart, aEnd, bStart, bEnd = i-1, i-1, j-1, j-1
+			} else if (aStart == i) && (bStart == j) {
+				aStart--
+				bStart--
+			} else {
+				emit = true
+			}
+			if (0 == aStart) || (0 == bStart) {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if dp[(i-1)*width+j] > dp[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if aStart != len(a) {
+				emit = true
+			}
+		}
+
+		matchLen := aEnd - aStart + 1
+		if emit {
+			if (0 == minMatchLen) || (int64(matchLen) >= minMatchLen) {
+				match := proto.NewResponse(proto.RES_TYPE_MULTI)
+				match.SetResponse(newIntListRes(aStart, aEnd))
+				match.SetResponse(newIntListRes(bStart, bEnd))
+				if withMatchLen {
+					match.SetResponse(newIntRes(matchLen))
+				}
+				matches.SetResponse(match)
+			}
+			aStart = len(a)
+		}
+	}
+
+	if getIdx {
+		res = proto.NewResponse(proto.RES_TYPE_MAP)
+		res.SetPair(newBulkRes("matches"), matches)
+		res.SetPair(newBulkRes("len"), newIntRes(int(total)))
+	} else {
+		res = proto.NewResponse(proto.RES_TYPE_BULK)
+		res.SetBytes(result)
+	}
+	return
+}
+
+// parseInt : Parse integer in the canonical form of redis, leading zeros,
+// plus sign and spaces are not allowed
+func parseInt(str string) (int64, bool) {
+	value, err := strconv.ParseInt(str, 10, 64)
+	if (nil != err) || (strconv.FormatInt(value, 10) != str) {
+		return 0, false
+	}
+	return value, true
+}
+
+// parseFloat : Parse float, NaN is not allowed
+func parseFloat(str string) (float64, bool) {
+	value, err := strconv.ParseFloat(str, 64)
+	if (nil != err) || math.IsNaN(value) {
+		return 0, false
+	}
+	return value, true
+}
+
+func newNullableBulkRes(content string, ok bool) *proto.Response {
+	if !ok {
+		return proto.NewNullRes(proto.RES_TYPE_BULK)
+	}
+	return newBulkRes(content)
+}
+
+func newBoolIntRes(flag bool) *proto.Response {
+	if flag {
+		return newIntRes(1)
+	}
+	return newIntRes(0)
+}
+
+func newIntListRes(values ...int) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	for _, value := range values {
+		res.SetResponse(newIntRes(value))
+	}
+	return res
+}
+
+func registerStringCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "get", Func: simpleCmd((*SimpleProc).GET), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Returns the string value of a key."},
+		&Command{Name: "set", Func: simpleCmd((*SimpleProc).SET), Arity: -3, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist."},
+		&Command{Name: "setnx", Func: simpleCmd((*SimpleProc).SETNX), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Set the string value of a key only when the key doesn't exist."},
+		&Command{Name: "setex", Func: simpleCmd((*SimpleProc).SETEX), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.0.0", Summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist."},
+		&Command{Name: "psetex", Func: simpleCmd((*SimpleProc).PSETEX), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.6.0", Summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist."},
+		&Command{Name: "getset", Func: simpleCmd((*SimpleProc).GETSET), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Returns the previous string value of a key after setting it to a new value."},
+		&Command{Name: "getdel", Func: simpleCmd((*SimpleProc).GETDEL), Arity: 2, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "6.2.0", Summary: "Returns the string value of a key after deleting the key."},
+		&Command{Name: "getex", Func: simpleCmd((*SimpleProc).GETEX), Arity: -2, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "6.2.0", Summary: "Returns the string value of a key after setting its expiration time."},
+		&Command{Name: "mget", Func: simpleCmd((*SimpleProc).MGET), Arity: -2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: -1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Atomically returns the string values of one or more keys."},
+		&Command{Name: "mset", Func: simpleCmd((*SimpleProc).MSET), Arity: -3, CheckArgs: pairsFrom(0), Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: -1, KeyStep: 2,
+			Group: GROUP_STRING, Since: "1.0.1", Summary: "Atomically creates or modifies the string values of one or more keys."},
+		&Command{Name: "msetnx", Func: simpleCmd((*SimpleProc).MSETNX), Arity: -3, CheckArgs: pairsFrom(0), Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: -1, KeyStep: 2,
+			Group: GROUP_STRING, Since: "1.0.1", Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist."},
+		&Command{Name: "append", Func: simpleCmd((*SimpleProc).APPEND), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.0.0", Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist."},
+		&Command{Name: "strlen", Func: simpleCmd((*SimpleProc).STRLEN), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.2.0", Summary: "Returns the length of a string value."},
+		&Command{Name: "getrange", Func: simpleCmd((*SimpleProc).GETRANGE), Arity: 4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.4.0", Summary: "Returns a substring of the string stored at a key."},
+		&Command{Name: "setrange", Func: simpleCmd((*SimpleProc).SETRANGE), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.2.0", Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist."},
+		&Command{Name: "incr", Func: simpleCmd((*SimpleProc).INCR), Arity: 2, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+		&Command{Name: "incrby", Func: simpleCmd((*SimpleProc).INCRBY), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+		&Command{Name: "incrbyfloat", Func: simpleCmd((*SimpleProc).INCRBYFLOAT), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "2.6.0", Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+		&Command{Name: "decr", Func: simpleCmd((*SimpleProc).DECR), Arity: 2, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+		&Command{Name: "decrby", Func: simpleCmd((*SimpleProc).DECRBY), Arity: 3, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_STRING, Since: "1.0.0", Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist."},
+		&Command{Name: "lcs", Func: simpleCmd((*SimpleProc).LCS), Arity: -3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_STRING, Since: "7.0.0", Summary: "Finds the longest common substring."},
+	)
+}
@@ -0,0 +1,1297 @@
+package processor
+
+import (
+	"errors"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kinds of ranges of ZRANGE family
+const (
+	zrangeByRank = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// Aggregate functions of ZUNION family
+const (
+	zaggSum = iota
+	zaggMin
+	zaggMax
+)
+
+// Operations of ZUNION family
+const (
+	zsetOpUnion = iota
+	zsetOpInter
+	zsetOpDiff
+)
+
+// viewZSet : Call function with sorted set of key under read lock, zset is
+// nil if key does not exist
+func (proc *SimpleProc) viewZSet(sess *Session, key string, function func(zset *store.ZSet)) error {
+	return proc.GetKeyspace(sess).View(key, store.TYPE_ZSET, func(value interface{}) error {
+		zset, _ := value.(*store.ZSet)
+		function(zset)
+		return nil
+	})
+}
+
+// updateZSet : Call function with sorted set of key under write lock, zset
+// is nil if key does not exist and key is deleted if zset becomes empty
+func (proc *SimpleProc) updateZSet(sess *Session, key string, function func(zset *store.ZSet) (*store.ZSet, error)) error {
+	return proc.GetKeyspace(sess).Update(key, store.TYPE_ZSET, func(value interface{}) (interface{}, error) {
+		zset, _ := value.(*store.ZSet)
+		zset, err := function(zset)
+		if (nil == zset) || (0 == zset.Len()) {
+			return nil, err
+		}
+		return zset, err
+	})
+}
+
+// zaddOptions : Options of ZADD
+type zaddOptions struct {
+	nx   bool
+	xx   bool
+	gt   bool
+	lt   bool
+	ch   bool
+	incr bool
+}
+
+// ZADD : Add members with scores to sorted set or update their scores,
+// reply count of members added, or new score with option INCR
+func (proc *SimpleProc) ZADD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	var opts zaddOptions
+	i := 1
+	for ; i < len(req.Params); i++ {
+		switch strings.ToUpper(req.Params[i]) {
+		case "NX":
+			opts.nx = true
+		case "XX":
+			opts.xx = true
+		case "GT":
+			opts.gt = true
+		case "LT":
+			opts.lt = true
+		case "CH":
+			opts.ch = true
+		case "INCR":
+			opts.incr = true
+		default:
+			goto pairs
+		}
+	}
+
+pairs:
+	elements := req.Params[i:]
+	if (0 == len(elements)) || (0 != len(elements)%2) {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+	if opts.nx && opts.xx {
+		res = proto.NewErrorRes("ERR XX and NX options at the same time are not compatible")
+		return
+	}
+	if (opts.gt && opts.nx) || (opts.lt && opts.nx) || (opts.gt && opts.lt) {
+		res = proto.NewErrorRes("ERR GT, LT, and/or NX options at the same time are not compatible")
+		return
+	}
+	if opts.incr && (len(elements) > 2) {
+		res = proto.NewErrorRes("ERR INCR option supports a single increment-element pair")
+		return
+	}
+
+	scores := make([]float64, 0, len(elements)/2)
+	members := make([]string, 0, len(elements)/2)
+	for j := 0; j < len(elements); j = j + 2 {
+		score, ok := parseFloat(elements[j])
+		if !ok {
+			res = proto.NewErrorRes("ERR value is not a valid float")
+			return
+		}
+		scores = append(scores, score)
+		members = append(members, elements[j+1])
+	}
+	return proc.zaddGeneric(sess, req.Params[0], opts, scores, members)
+}
+
+// ZINCRBY : Increase score of member of sorted set by increment
+func (proc *SimpleProc) ZINCRBY(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	incr, ok := parseFloat(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes("ERR value is not a valid float")
+		return
+	}
+	return proc.zaddGeneric(sess, req.Params[0], zaddOptions{incr: true}, []float64{incr}, []string{req.Params[2]})
+}
+
+func (proc *SimpleProc) zaddGeneric(sess *Session, key string, opts zaddOptions, scores []float64, members []string) (res *proto.Response, err error) {
+	added, changed := 0, 0
+	result, applied := float64(0), false
+	e := proc.updateZSet(sess, key, func(zset *store.ZSet) (*store.ZSet, error) {
+		if nil == zset {
+			zset = store.NewZSet()
+		}
+		for i, member := range members {
+			score := scores[i]
+			old, exists := zset.Score(member)
+			if !exists {
+				if opts.xx {
+					continue
+				}
+				zset.Add(member, score)
+				added++
+				result, applied = score, true
+				continue
+			}
+
+			if opts.nx {
+				continue
+			}
+			if opts.incr {
+				score = old + score
+				if math.IsNaN(score) {
+					return zset, errors.New("ERR resulting score is not a number (NaN)")
+				}
+			}
+			if (opts.gt && (score <= old)) || (opts.lt && (score >= old)) {
+				continue
+			}
+			if score != old {
+				zset.Add(member, score)
+				changed++
+			}
+			result, applied = score, true
+		}
+		return zset, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if opts.incr && applied {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_ZSET, "zincr", key)
+	} else if !opts.incr && (added+changed > 0) {
+		proc.notifyKeyspaceEvent(sess, store.NOTIFY_ZSET, "zadd", key)
+	}
+	if added > 0 {
+		proc.signalKey(sess, key)
+	}
+
+	if opts.incr {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		if applied {
+			res = newScoreRes(result)
+		}
+	} else if opts.ch {
+		res = newIntRes(added + changed)
+	} else {
+		res = newIntRes(added)
+	}
+	return
+}
+
+// ZREM : Remove members from sorted set, reply count of members removed
+func (proc *SimpleProc) ZREM(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	count := 0
+	deleted := false
+	e := proc.updateZSet(sess, req.Params[0], func(zset *store.ZSet) (*store.ZSet, error) {
+		if nil == zset {
+			return nil, nil
+		}
+		for _, member := range req.Params[1:] {
+			if zset.Delete(member) {
+				count++
+			}
+		}
+		deleted = 0 == zset.Len()
+		return zset, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_ZSET, "zrem", req.Params[0], deleted)
+	}
+	res = newIntRes(count)
+	return
+}
+
+// ZSCORE : Get score of member of sorted set
+func (proc *SimpleProc) ZSCORE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = proto.NewNullRes(proto.RES_TYPE_BULK)
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		if nil == zset {
+			return
+		}
+		if score, ok := zset.Score(req.Params[1]); ok {
+			res = newScoreRes(score)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// ZMSCORE : Get scores of members of sorted set, null for member not exist
+func (proc *SimpleProc) ZMSCORE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	res = proto.NewResponse(proto.RES_TYPE_MULTI)
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		for _, member := range req.Params[1:] {
+			score := proto.NewNullRes(proto.RES_TYPE_BULK)
+			if nil != zset {
+				if s, ok := zset.Score(member); ok {
+					score = newScoreRes(s)
+				}
+			}
+			res.SetResponse(score)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+	}
+	return
+}
+
+// ZCARD : Count of members of sorted set
+func (proc *SimpleProc) ZCARD(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	length := 0
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		if nil != zset {
+			length = zset.Len()
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(length)
+	return
+}
+
+// ZCOUNT : Count of members of sorted set with score in range
+func (proc *SimpleProc) ZCOUNT(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zcountGeneric(sess, req, zrangeByScore)
+}
+
+// ZLEXCOUNT : Count of members of sorted set in lexicographical range
+func (proc *SimpleProc) ZLEXCOUNT(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zcountGeneric(sess, req, zrangeByLex)
+}
+
+func (proc *SimpleProc) zcountGeneric(sess *Session, req *proto.Request, by int) (res *proto.Response, err error) {
+	spec := zrangeSpec{by: by, start: req.Params[1], stop: req.Params[2], count: -1}
+	if res = spec.parseBounds(); nil != res {
+		return
+	}
+
+	count := 0
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		if nil != zset {
+			first, last := spec.ranks(zset)
+			count = last - first + 1
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newIntRes(count)
+	return
+}
+
+// ZRANK : Get rank of member of sorted set ordered by ascending scores
+func (proc *SimpleProc) ZRANK(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrankGeneric(sess, req, false)
+}
+
+// ZREVRANK : Get rank of member of sorted set ordered by descending scores
+func (proc *SimpleProc) ZREVRANK(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrankGeneric(sess, req, true)
+}
+
+func (proc *SimpleProc) zrankGeneric(sess *Session, req *proto.Request, reverse bool) (res *proto.Response, err error) {
+	withScore := false
+	if 3 == len(req.Params) {
+		if "WITHSCORE" != strings.ToUpper(req.Params[2]) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		withScore = true
+	}
+
+	rank, score, found := 0, float64(0), false
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		if nil == zset {
+			return
+		}
+		if rank, found = zset.Rank(req.Params[1]); found {
+			score, _ = zset.Score(req.Params[1])
+			if reverse {
+				rank = zset.Len() - 1 - rank
+			}
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if !found {
+		if withScore {
+			res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+		} else {
+			res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		}
+	} else if withScore {
+		res = proto.NewResponse(proto.RES_TYPE_MULTI)
+		res.SetResponse(newIntRes(rank))
+		res.SetResponse(newScoreRes(score))
+	} else {
+		res = newIntRes(rank)
+	}
+	return
+}
+
+// zrangeSpec : Range and options of ZRANGE family
+type zrangeSpec struct {
+	by         int    // One of zrangeBy*
+	rev        bool   // Members are ordered by descending scores
+	start      string // Start rank, or min if range is by score or lex
+	stop       string // Stop rank, or max if range is by score or lex
+	offset     int64  // Offset of LIMIT
+	count      int64  // Count of LIMIT, negative if there is no limit
+	hasLimit   bool
+	withScores bool
+
+	startRank int64
+	stopRank  int64
+	minScore  store.ScoreBound
+	maxScore  store.ScoreBound
+	minLex    store.LexBound
+	maxLex    store.LexBound
+}
+
+// parseOptions : Parse options of ZRANGE family, BYSCORE, BYLEX and REV are
+// allowed only for ZRANGE and ZRANGESTORE, WITHSCORES is not allowed for
+// ZRANGESTORE
+func (spec *zrangeSpec) parseOptions(options []string, allowBy bool, allowScores bool) *proto.Response {
+	for i := 0; i < len(options); i++ {
+		option := strings.ToUpper(options[i])
+		if ("WITHSCORES" == option) && allowScores {
+			spec.withScores = true
+		} else if ("LIMIT" == option) && (i+2 < len(options)) {
+			var ok1, ok2 bool
+			spec.offset, ok1 = parseInt(options[i+1])
+			spec.count, ok2 = parseInt(options[i+2])
+			if !ok1 || !ok2 {
+				return proto.NewErrorRes(ERR_NOT_INTEGER)
+			}
+			spec.hasLimit = true
+			i = i + 2
+		} else if ("BYSCORE" == option) && allowBy && (zrangeByRank == spec.by) {
+			spec.by = zrangeByScore
+		} else if ("BYLEX" == option) && allowBy && (zrangeByRank == spec.by) {
+			spec.by = zrangeByLex
+		} else if ("REV" == option) && allowBy {
+			spec.rev = true
+		} else {
+			return proto.NewErrorRes(ERR_SYNTAX)
+		}
+	}
+
+	if spec.hasLimit && (zrangeByRank == spec.by) {
+		return proto.NewErrorRes("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && (zrangeByLex == spec.by) {
+		return proto.NewErrorRes("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	if !spec.hasLimit {
+		spec.count = -1
+	}
+	return nil
+}
+
+// parseBounds : Parse start and stop, they are max and min if range is by
+// score or lex in reverse order
+func (spec *zrangeSpec) parseBounds() *proto.Response {
+	min, max := spec.start, spec.stop
+	if spec.rev {
+		min, max = max, min
+	}
+	switch spec.by {
+	case zrangeByScore:
+		var ok1, ok2 bool
+		spec.minScore, ok1 = parseScoreBound(min)
+		spec.maxScore, ok2 = parseScoreBound(max)
+		if !ok1 || !ok2 {
+			return proto.NewErrorRes("ERR min or max is not a float")
+		}
+	case zrangeByLex:
+		var ok1, ok2 bool
+		spec.minLex, ok1 = parseLexBound(min)
+		spec.maxLex, ok2 = parseLexBound(max)
+		if !ok1 || !ok2 {
+			return proto.NewErrorRes("ERR min or max not valid string range item")
+		}
+	default:
+		var ok1, ok2 bool
+		spec.startRank, ok1 = parseInt(spec.start)
+		spec.stopRank, ok2 = parseInt(spec.stop)
+		if !ok1 || !ok2 {
+			return proto.NewErrorRes(ERR_NOT_INTEGER)
+		}
+	}
+	return nil
+}
+
+// ranks : Get ascending ranks of the first and the last member in range
+// before LIMIT is applied, first is greater than last if range is empty
+func (spec *zrangeSpec) ranks(zset *store.ZSet) (int, int) {
+	switch spec.by {
+	case zrangeByScore:
+		return zset.ScoreRange(spec.minScore, spec.maxScore)
+	case zrangeByLex:
+		return zset.LexRange(spec.minLex, spec.maxLex)
+	}
+
+	first, last, ok := normalizeRange(int64(zset.Len()), spec.startRank, spec.stopRank)
+	if !ok {
+		return 0, -1
+	}
+	if spec.rev {
+		first, last = zset.Len()-1-last, zset.Len()-1-first
+	}
+	return first, last
+}
+
+// collect : Call function for members in range in order after LIMIT is applied
+func (spec *zrangeSpec) collect(zset *store.ZSet, function func(member string, score float64)) {
+	first, last := spec.ranks(zset)
+	if first > last {
+		return
+	}
+	if spec.hasLimit {
+		if (spec.offset < 0) || (spec.offset > int64(last-first)) {
+			return
+		}
+		if spec.rev {
+			last = last - int(spec.offset)
+			if (spec.count >= 0) && (int64(last-first+1) > spec.count) {
+				first = last - int(spec.count) + 1
+			}
+		} else {
+			first = first + int(spec.offset)
+			if (spec.count >= 0) && (int64(last-first+1) > spec.count) {
+				last = first + int(spec.count) - 1
+			}
+		}
+	}
+	zset.Range(first, last, spec.rev, function)
+}
+
+// ZRANGE : Get members of sorted set in range of ranks, scores or lex
+func (proc *SimpleProc) ZRANGE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	spec := zrangeSpec{by: zrangeByRank, start: req.Params[1], stop: req.Params[2]}
+	if res = spec.parseOptions(req.Params[3:], true, true); nil != res {
+		return
+	}
+	return proc.zrangeGeneric(sess, req.Params[0], &spec)
+}
+
+// ZREVRANGE : Get members of sorted set in range of ranks in reverse order
+func (proc *SimpleProc) ZREVRANGE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrangeLegacy(sess, req, zrangeByRank, true)
+}
+
+// ZRANGEBYSCORE : Get members of sorted set with score in range
+func (proc *SimpleProc) ZRANGEBYSCORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrangeLegacy(sess, req, zrangeByScore, false)
+}
+
+// ZREVRANGEBYSCORE : Get members of sorted set with score in range in reverse order
+func (proc *SimpleProc) ZREVRANGEBYSCORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrangeLegacy(sess, req, zrangeByScore, true)
+}
+
+// ZRANGEBYLEX : Get members of sorted set in lexicographical range
+func (proc *SimpleProc) ZRANGEBYLEX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrangeLegacy(sess, req, zrangeByLex, false)
+}
+
+// ZREVRANGEBYLEX : Get members of sorted set in lexicographical range in reverse order
+func (proc *SimpleProc) ZREVRANGEBYLEX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zrangeLegacy(sess, req, zrangeByLex, true)
+}
+
+// zrangeLegacy : Commands replaced by options of ZRANGE since 6.2.0
+func (proc *SimpleProc) zrangeLegacy(sess *Session, req *proto.Request, by int, rev bool) (res *proto.Response, err error) {
+	spec := zrangeSpec{by: by, rev: rev, start: req.Params[1], stop: req.Params[2]}
+	if res = spec.parseOptions(req.Params[3:], false, true); nil != res {
+		return
+	}
+	return proc.zrangeGeneric(sess, req.Params[0], &spec)
+}
+
+func (proc *SimpleProc) zrangeGeneric(sess *Session, key string, spec *zrangeSpec) (res *proto.Response, err error) {
+	if res = spec.parseBounds(); nil != res {
+		return
+	}
+
+	members := []string{}
+	scores := []float64{}
+	e := proc.viewZSet(sess, key, func(zset *store.ZSet) {
+		if nil == zset {
+			return
+		}
+		spec.collect(zset, func(member string, score float64) {
+			members = append(members, member)
+			scores = append(scores, score)
+		})
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newScoredListRes(sess, members, scores, spec.withScores)
+	return
+}
+
+// ZRANGESTORE : Store members of sorted set in range to destination, reply
+// count of members stored
+func (proc *SimpleProc) ZRANGESTORE(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	spec := zrangeSpec{by: zrangeByRank, start: req.Params[2], stop: req.Params[3]}
+	if res = spec.parseOptions(req.Params[4:], true, false); nil != res {
+		return
+	}
+	if res = spec.parseBounds(); nil != res {
+		return
+	}
+
+	dst := req.Params[0]
+	length := 0
+	existed := false
+	e := proc.GetKeyspace(sess).MutateMulti([]string{dst, req.Params[1]}, func(objs []*store.Object) ([]*store.Object, error) {
+		existed = nil != objs[0]
+		result := store.NewZSet()
+		if nil != objs[1] {
+			if store.TYPE_ZSET != objs[1].Type {
+				return objs, store.ErrWrongType
+			}
+			spec.collect(objs[1].Value.(*store.ZSet), func(member string, score float64) {
+				result.Add(member, score)
+			})
+		}
+		length = result.Len()
+		objs[0] = newZSetObject(result)
+		if dst == req.Params[1] {
+			objs[1] = objs[0]
+		}
+		return objs, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyStored(sess, store.NOTIFY_ZSET, "zrangestore", dst, length > 0, existed)
+	if length > 0 {
+		proc.signalKey(sess, dst)
+	}
+	res = newIntRes(length)
+	return
+}
+
+// ZREMRANGEBYRANK : Remove members of sorted set in range of ranks
+func (proc *SimpleProc) ZREMRANGEBYRANK(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zremrangeGeneric(sess, req, zrangeByRank)
+}
+
+// ZREMRANGEBYSCORE : Remove members of sorted set with score in range
+func (proc *SimpleProc) ZREMRANGEBYSCORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zremrangeGeneric(sess, req, zrangeByScore)
+}
+
+// ZREMRANGEBYLEX : Remove members of sorted set in lexicographical range
+func (proc *SimpleProc) ZREMRANGEBYLEX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zremrangeGeneric(sess, req, zrangeByLex)
+}
+
+func (proc *SimpleProc) zremrangeGeneric(sess *Session, req *proto.Request, by int) (res *proto.Response, err error) {
+	spec := zrangeSpec{by: by, start: req.Params[1], stop: req.Params[2], count: -1}
+	if res = spec.parseBounds(); nil != res {
+		return
+	}
+
+	count := 0
+	deleted := false
+	e := proc.updateZSet(sess, req.Params[0], func(zset *store.ZSet) (*store.ZSet, error) {
+		if nil == zset {
+			return nil, nil
+		}
+		count = zset.DeleteRange(spec.ranks(zset))
+		deleted = 0 == zset.Len()
+		return zset, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if count > 0 {
+		proc.notifyChanged(sess, store.NOTIFY_ZSET, strings.ToLower(req.Cmd), req.Params[0], deleted)
+	}
+	res = newIntRes(count)
+	return
+}
+
+// ZPOPMIN : Remove and get members with the lowest scores of sorted set
+func (proc *SimpleProc) ZPOPMIN(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zpopGeneric(sess, req, false)
+}
+
+// ZPOPMAX : Remove and get members with the highest scores of sorted set
+func (proc *SimpleProc) ZPOPMAX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zpopGeneric(sess, req, true)
+}
+
+func (proc *SimpleProc) zpopGeneric(sess *Session, req *proto.Request, max bool) (res *proto.Response, err error) {
+	count := int64(1)
+	if 2 == len(req.Params) {
+		var ok bool
+		if count, ok = parseInt(req.Params[1]); !ok || (count < 0) {
+			res = proto.NewErrorRes("ERR value is out of range, must be positive")
+			return
+		}
+	}
+
+	members, scores, e := proc.popZSet(sess, req.Params[0], max, count)
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	if 1 == len(req.Params) {
+		// Member without count is replied as a flat pair even in RESP3
+		res = proto.NewResponse(proto.RES_TYPE_MULTI)
+		if 1 == len(members) {
+			res.SetResponse(newBulkRes(members[0]))
+			res.SetResponse(newScoreRes(scores[0]))
+		}
+		return
+	}
+	res = newScoredListRes(sess, members, scores, true)
+	return
+}
+
+// popZSet : Pop at most count members with the lowest or the highest scores
+func (proc *SimpleProc) popZSet(sess *Session, key string, max bool, count int64) (members []string, scores []float64, err error) {
+	deleted := false
+	err = proc.updateZSet(sess, key, func(zset *store.ZSet) (*store.ZSet, error) {
+		if (nil == zset) || (0 == count) {
+			return zset, nil
+		}
+		first, last := 0, zset.Len()-1
+		if int64(zset.Len()) > count {
+			if max {
+				first = last - int(count) + 1
+			} else {
+				last = int(count) - 1
+			}
+		}
+		zset.Range(first, last, max, func(member string, score float64) {
+			members = append(members, member)
+			scores = append(scores, score)
+		})
+		zset.DeleteRange(first, last)
+		deleted = 0 == zset.Len()
+		return zset, nil
+	})
+	if len(members) > 0 {
+		event := "zpopmin"
+		if max {
+			event = "zpopmax"
+		}
+		proc.notifyChanged(sess, store.NOTIFY_ZSET, event, key, deleted)
+	}
+	return
+}
+
+// BZPOPMIN : Pop member with the lowest score of the first non-empty sorted
+// set of keys, block until a member is added or timeout passes if all
+// sorted sets are empty
+func (proc *SimpleProc) BZPOPMIN(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.bzpopGeneric(sess, req, false)
+}
+
+// BZPOPMAX : Pop member with the highest score of the first non-empty sorted
+// set of keys, block until a member is added or timeout passes if all
+// sorted sets are empty
+func (proc *SimpleProc) BZPOPMAX(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.bzpopGeneric(sess, req, true)
+}
+
+func (proc *SimpleProc) bzpopGeneric(sess *Session, req *proto.Request, max bool) (res *proto.Response, err error) {
+	keys := req.Params[:len(req.Params)-1]
+	timeout, res := parseTimeout(req.Params[len(req.Params)-1])
+	if nil != res {
+		return
+	}
+
+	served := proc.blockOn(sess, keys, timeout, func(key string, blocked bool) (bool, []string) {
+		members, scores, e := proc.popZSet(sess, key, max, 1)
+		if nil != e {
+			return blockError(e, blocked, &res), nil
+		}
+		if 0 == len(members) {
+			return false, nil
+		}
+		res = proto.NewResponse(proto.RES_TYPE_MULTI)
+		res.SetResponse(newBulkRes(key))
+		res.SetResponse(newBulkRes(members[0]))
+		res.SetResponse(newScoreRes(scores[0]))
+		return true, nil
+	})
+	if !served {
+		res = proto.NewNullRes(proto.RES_TYPE_MULTI)
+	}
+	return
+}
+
+// zsetOpSpec : Keys and options of ZUNION family
+type zsetOpSpec struct {
+	op         int // One of zsetOp*
+	keys       []string
+	weights    []float64
+	aggregate  int // One of zagg*
+	withScores bool
+}
+
+// parseZsetOpArgs : Parse numkeys, keys and options of ZUNION family, params
+// start from numkeys
+func parseZsetOpArgs(params []string, op int, name string, allowScores bool) (spec zsetOpSpec, res *proto.Response) {
+	spec.op = op
+	numKeys, ok := parseInt(params[0])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	if numKeys < 1 {
+		res = proto.NewErrorRes("ERR at least 1 input key is needed for '" + name + "' command")
+		return
+	}
+	if numKeys > int64(len(params)-1) {
+		res = proto.NewErrorRes(ERR_SYNTAX)
+		return
+	}
+	spec.keys = params[1 : 1+numKeys]
+	spec.weights = make([]float64, numKeys)
+	for i := range spec.weights {
+		spec.weights[i] = 1
+	}
+
+	options := params[1+numKeys:]
+	for i := 0; i < len(options); i++ {
+		option := strings.ToUpper(options[i])
+		if ("WEIGHTS" == option) && (zsetOpDiff != op) && (i+int(numKeys) < len(options)) {
+			for j := range spec.weights {
+				if spec.weights[j], ok = parseFloat(options[i+1+j]); !ok {
+					res = proto.NewErrorRes("ERR weight value is not a float")
+					return
+				}
+			}
+			i = i + int(numKeys)
+		} else if ("AGGREGATE" == option) && (zsetOpDiff != op) && (i+1 < len(options)) {
+			switch strings.ToUpper(options[i+1]) {
+			case "SUM":
+				spec.aggregate = zaggSum
+			case "MIN":
+				spec.aggregate = zaggMin
+			case "MAX":
+				spec.aggregate = zaggMax
+			default:
+				res = proto.NewErrorRes(ERR_SYNTAX)
+				return
+			}
+			i++
+		} else if ("WITHSCORES" == option) && allowScores {
+			spec.withScores = true
+		} else {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+	}
+	return
+}
+
+// scoredSet : Input of ZUNION family, members of a set have score 1
+type scoredSet struct {
+	zset *store.ZSet
+	set  *store.Dict
+}
+
+func (s scoredSet) len() int {
+	if nil != s.zset {
+		return s.zset.Len()
+	} else if nil != s.set {
+		return s.set.Len()
+	}
+	return 0
+}
+
+func (s scoredSet) score(member string) (float64, bool) {
+	if nil != s.zset {
+		return s.zset.Score(member)
+	} else if nil != s.set {
+		_, ok := s.set.Get(member)
+		return 1, ok
+	}
+	return 0, false
+}
+
+func (s scoredSet) each(function func(member string, score float64)) {
+	if nil != s.zset {
+		s.zset.Dict().Range(func(member string, score interface{}) bool {
+			function(member, score.(float64))
+			return true
+		})
+	} else if nil != s.set {
+		s.set.Range(func(member string, v interface{}) bool {
+			function(member, 1)
+			return true
+		})
+	}
+}
+
+// compute : Compute result of operation on objects of keys, objects may be
+// sorted sets or sets
+func (spec *zsetOpSpec) compute(objs []*store.Object) (*store.ZSet, error) {
+	inputs := make([]scoredSet, len(objs))
+	for i, obj := range objs {
+		if nil == obj {
+			continue
+		}
+		switch obj.Type {
+		case store.TYPE_ZSET:
+			inputs[i].zset = obj.Value.(*store.ZSet)
+		case store.TYPE_SET:
+			inputs[i].set = obj.Value.(*store.Dict)
+		default:
+			return nil, store.ErrWrongType
+		}
+	}
+
+	result := store.NewZSet()
+	switch spec.op {
+	case zsetOpUnion:
+		for i, input := range inputs {
+			input.each(func(member string, score float64) {
+				score = weightScore(score, spec.weights[i])
+				if old, ok := result.Score(member); ok {
+					score = spec.aggregateScore(old, score)
+				}
+				result.Add(member, score)
+			})
+		}
+	case zsetOpInter:
+		smallest := 0
+		for i, input := range inputs {
+			if input.len() < inputs[smallest].len() {
+				smallest = i
+			}
+		}
+		inputs[smallest].each(func(member string, v float64) {
+			var score float64
+			for i, input := range inputs {
+				s, ok := input.score(member)
+				if !ok {
+					return
+				}
+				s = weightScore(s, spec.weights[i])
+				if 0 == i {
+					score = s
+				} else {
+					score = spec.aggregateScore(score, s)
+				}
+			}
+			result.Add(member, score)
+		})
+	default:
+		inputs[0].each(func(member string, score float64) {
+			for _, input := range inputs[1:] {
+				if _, ok := input.score(member); ok {
+					return
+				}
+			}
+			result.Add(member, score)
+		})
+	}
+	return result, nil
+}
+
+func (spec *zsetOpSpec) aggregateScore(a float64, b float64) float64 {
+	switch spec.aggregate {
+	case zaggMin:
+		return math.Min(a, b)
+	case zaggMax:
+		return math.Max(a, b)
+	}
+	// The sum of +inf and -inf is 0
+	if sum := a + b; !math.IsNaN(sum) {
+		return sum
+	}
+	return 0
+}
+
+// weightScore : Multiply score by weight, 0 if the result is NaN
+func weightScore(score float64, weight float64) float64 {
+	if value := score * weight; !math.IsNaN(value) {
+		return value
+	}
+	return 0
+}
+
+// ZUNION : Get union of sorted sets
+func (proc *SimpleProc) ZUNION(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zsetOpGeneric(sess, req, zsetOpUnion)
+}
+
+// ZINTER : Get intersection of sorted sets
+func (proc *SimpleProc) ZINTER(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zsetOpGeneric(sess, req, zsetOpInter)
+}
+
+// ZDIFF : Get members of the first sorted set not in the others
+func (proc *SimpleProc) ZDIFF(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zsetOpGeneric(sess, req, zsetOpDiff)
+}
+
+func (proc *SimpleProc) zsetOpGeneric(sess *Session, req *proto.Request, op int) (res *proto.Response, err error) {
+	spec, res := parseZsetOpArgs(req.Params, op, strings.ToLower(req.Cmd), true)
+	if nil != res {
+		return
+	}
+
+	members := []string{}
+	scores := []float64{}
+	e := proc.GetKeyspace(sess).ViewMulti(spec.keys, func(objs []*store.Object) error {
+		result, err := spec.compute(objs)
+		if nil != err {
+			return err
+		}
+		result.Range(0, result.Len()-1, false, func(member string, score float64) {
+			members = append(members, member)
+			scores = append(scores, score)
+		})
+		return nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newScoredListRes(sess, members, scores, spec.withScores)
+	return
+}
+
+// ZUNIONSTORE : Store union of sorted sets to destination, reply its size
+func (proc *SimpleProc) ZUNIONSTORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zsetOpStoreGeneric(sess, req, zsetOpUnion)
+}
+
+// ZINTERSTORE : Store intersection of sorted sets to destination, reply its size
+func (proc *SimpleProc) ZINTERSTORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zsetOpStoreGeneric(sess, req, zsetOpInter)
+}
+
+// ZDIFFSTORE : Store difference of sorted sets to destination, reply its size
+func (proc *SimpleProc) ZDIFFSTORE(sess *Session, req *proto.Request) (*proto.Response, error) {
+	return proc.zsetOpStoreGeneric(sess, req, zsetOpDiff)
+}
+
+// zsetOpStoreGeneric : Destination is overwritten whatever type it holds,
+// and deleted if result is empty
+func (proc *SimpleProc) zsetOpStoreGeneric(sess *Session, req *proto.Request, op int) (res *proto.Response, err error) {
+	spec, res := parseZsetOpArgs(req.Params[1:], op, strings.ToLower(req.Cmd), false)
+	if nil != res {
+		return
+	}
+
+	dst := req.Params[0]
+	keys := append([]string{dst}, spec.keys...)
+	length := 0
+	existed := false
+	e := proc.GetKeyspace(sess).MutateMulti(keys, func(objs []*store.Object) ([]*store.Object, error) {
+		result, err := spec.compute(objs[1:])
+		if nil != err {
+			return objs, err
+		}
+		existed = nil != objs[0]
+		length = result.Len()
+		objs[0] = newZSetObject(result)
+		// Source keys equal to destination must see the result
+		for i := 1; i < len(keys); i++ {
+			if keys[i] == dst {
+				objs[i] = objs[0]
+			}
+		}
+		return objs, nil
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	proc.notifyStored(sess, store.NOTIFY_ZSET, strings.ToLower(req.Cmd), dst, length > 0, existed)
+	if length > 0 {
+		proc.signalKey(sess, dst)
+	}
+	res = newIntRes(length)
+	return
+}
+
+// ZRANDMEMBER : Get random members of sorted set, count > 0 replies distinct
+// members and count < 0 allows the same member multiple times
+func (proc *SimpleProc) ZRANDMEMBER(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	if 1 == len(req.Params) {
+		res = proto.NewNullRes(proto.RES_TYPE_BULK)
+		e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+			if nil != zset {
+				member, _, _ := zset.Dict().RandomKey()
+				res.SetString(member)
+			}
+		})
+		if nil != e {
+			res = proto.NewErrorRes(e.Error())
+		}
+		return
+	}
+
+	count, ok := parseInt(req.Params[1])
+	if !ok {
+		res = proto.NewErrorRes(ERR_NOT_INTEGER)
+		return
+	}
+	withScores := false
+	if 3 == len(req.Params) {
+		if "WITHSCORES" != strings.ToUpper(req.Params[2]) {
+			res = proto.NewErrorRes(ERR_SYNTAX)
+			return
+		}
+		withScores = true
+	}
+	if (count < -math.MaxInt64/2) || (withScores && (count < -math.MaxInt64/4)) {
+		res = proto.NewErrorRes("ERR value is out of range")
+		return
+	}
+
+	members := []string{}
+	scores := []float64{}
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		if nil == zset {
+			return
+		}
+		members = randomDictKeys(zset.Dict(), count)
+		for _, member := range members {
+			score, _ := zset.Score(member)
+			scores = append(scores, score)
+		}
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newScoredListRes(sess, members, scores, withScores)
+	return
+}
+
+// ZSCAN : Iterate members and scores of sorted set by cursor
+func (proc *SimpleProc) ZSCAN(sess *Session, req *proto.Request) (res *proto.Response, err error) {
+	cursor, opts, res := parseScanArgs(req.Params[1:], false)
+	if nil != res {
+		return
+	}
+
+	items := []string{}
+	e := proc.viewZSet(sess, req.Params[0], func(zset *store.ZSet) {
+		if nil == zset {
+			cursor = 0
+			return
+		}
+		cursor, items = scanDict(zset.Dict(), cursor, opts, func(value interface{}) string {
+			return formatScore(value.(float64))
+		})
+	})
+	if nil != e {
+		res = proto.NewErrorRes(e.Error())
+		return
+	}
+	res = newScanRes(cursor, items)
+	return
+}
+
+// parseScoreBound : Parse min or max of score range, `(` prefix means exclusive
+func parseScoreBound(str string) (bound store.ScoreBound, ok bool) {
+	if strings.HasPrefix(str, "(") {
+		bound.Exclusive = true
+		str = str[1:]
+	}
+	bound.Value, ok = parseFloat(str)
+	return
+}
+
+// parseLexBound : Parse min or max of lexicographical range, `[` prefix means
+// inclusive, `(` prefix means exclusive, `-` and `+` are infinities
+func parseLexBound(str string) (bound store.LexBound, ok bool) {
+	switch {
+	case "-" == str:
+		bound.Inf = -1
+	case "+" == str:
+		bound.Inf = 1
+	case strings.HasPrefix(str, "["):
+		bound.Value = str[1:]
+	case strings.HasPrefix(str, "("):
+		bound.Value = str[1:]
+		bound.Exclusive = true
+	default:
+		return bound, false
+	}
+	return bound, true
+}
+
+// normalizeRange : Convert range of indexes counted from tail if negative to
+// indexes from head clamped to [0, length), false if range is empty
+func normalizeRange(length int64, start int64, stop int64) (int, int, bool) {
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if (start > stop) || (start >= length) {
+		return 0, 0, false
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return int(start), int(stop), true
+}
+
+// formatScore : Format score like redis, integral scores are never written
+// in exponent form unless they are huge
+func formatScore(score float64) string {
+	abs := math.Abs(score)
+	if (0 == abs) || ((abs >= 1e-6) && (abs < 1e17)) {
+		return strconv.FormatFloat(score, 'f', -1, 64)
+	}
+	return proto.FormatFloat(score)
+}
+
+// newScoreRes : Score is a double in RESP3 and a bulk string in RESP2
+func newScoreRes(score float64) *proto.Response {
+	res := proto.NewResponse(proto.RES_TYPE_DOUBLE)
+	res.SetString(formatScore(score))
+	return res
+}
+
+// newScoredListRes : Reply members with scores, each member and its score
+// are nested as a pair in RESP3 and flattened in RESP2
+func newScoredListRes(sess *Session, members []string, scores []float64, withScores bool) *proto.Response {
+	if !withScores {
+		return proto.NewBulkListRes(proto.RES_TYPE_MULTI, members)
+	}
+	res := proto.NewResponse(proto.RES_TYPE_MULTI)
+	for i, member := range members {
+		if proto.PROTO_RESP3 == sess.ProtoVer {
+			pair := proto.NewResponse(proto.RES_TYPE_MULTI)
+			pair.SetResponse(newBulkRes(member))
+			pair.SetResponse(newScoreRes(scores[i]))
+			res.SetResponse(pair)
+		} else {
+			res.SetResponse(newBulkRes(member))
+			res.SetResponse(newScoreRes(scores[i]))
+		}
+	}
+	return res
+}
+
+// newZSetObject : Object of sorted set, nil if it is empty
+func newZSetObject(zset *store.ZSet) *store.Object {
+	if 0 == zset.Len() {
+		return nil
+	}
+	return &store.Object{Type: store.TYPE_ZSET, Value: zset}
+}
+
+func registerZSetCommands(table *CommandTable) {
+	table.Register(
+		&Command{Name: "zadd", Func: simpleCmd((*SimpleProc).ZADD), Arity: -4, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist."},
+		&Command{Name: "zincrby", Func: simpleCmd((*SimpleProc).ZINCRBY), Arity: 4, Flags: CMD_WRITE | CMD_DENYOOM | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Increments the score of a member in a sorted set."},
+		&Command{Name: "zrem", Func: simpleCmd((*SimpleProc).ZREM), Arity: -3, Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed."},
+		&Command{Name: "zscore", Func: simpleCmd((*SimpleProc).ZSCORE), Arity: 3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Returns the score of a member in a sorted set."},
+		&Command{Name: "zmscore", Func: simpleCmd((*SimpleProc).ZMSCORE), Arity: -3, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Returns the score of one or more members in a sorted set."},
+		&Command{Name: "zcard", Func: simpleCmd((*SimpleProc).ZCARD), Arity: 2, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Returns the number of members in a sorted set."},
+		&Command{Name: "zcount", Func: simpleCmd((*SimpleProc).ZCOUNT), Arity: 4, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.0.0", Summary: "Returns the count of members in a sorted set that have scores within a range."},
+		&Command{Name: "zlexcount", Func: simpleCmd((*SimpleProc).ZLEXCOUNT), Arity: 4, Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.8.9", Summary: "Returns the number of members in a sorted set within a lexicographical range."},
+		&Command{Name: "zrank", Func: simpleCmd((*SimpleProc).ZRANK), Arity: -3, CheckArgs: maxArgs(3), Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.0.0", Summary: "Returns the index of a member in a sorted set ordered by ascending scores."},
+		&Command{Name: "zrevrank", Func: simpleCmd((*SimpleProc).ZREVRANK), Arity: -3, CheckArgs: maxArgs(3), Flags: CMD_READONLY | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.0.0", Summary: "Returns the index of a member in a sorted set ordered by descending scores."},
+		&Command{Name: "zrange", Func: simpleCmd((*SimpleProc).ZRANGE), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Returns members in a sorted set within a range of indexes."},
+		&Command{Name: "zrangestore", Func: simpleCmd((*SimpleProc).ZRANGESTORE), Arity: -5, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 2, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Stores a range of members from sorted set in a key."},
+		&Command{Name: "zrevrange", Func: simpleCmd((*SimpleProc).ZREVRANGE), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Returns members in a sorted set within a range of indexes in reverse order."},
+		&Command{Name: "zrangebyscore", Func: simpleCmd((*SimpleProc).ZRANGEBYSCORE), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.0.5", Summary: "Returns members in a sorted set within a range of scores."},
+		&Command{Name: "zrevrangebyscore", Func: simpleCmd((*SimpleProc).ZREVRANGEBYSCORE), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.2.0", Summary: "Returns members in a sorted set within a range of scores in reverse order."},
+		&Command{Name: "zrangebylex", Func: simpleCmd((*SimpleProc).ZRANGEBYLEX), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.8.9", Summary: "Returns members in a sorted set within a lexicographical range."},
+		&Command{Name: "zrevrangebylex", Func: simpleCmd((*SimpleProc).ZREVRANGEBYLEX), Arity: -4, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.8.9", Summary: "Returns members in a sorted set within a lexicographical range in reverse order."},
+		&Command{Name: "zremrangebyrank", Func: simpleCmd((*SimpleProc).ZREMRANGEBYRANK), Arity: 4, Flags: CMD_WRITE, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.0.0", Summary: "Removes members in a sorted set within a range of indexes. Deletes the sorted set if all members were removed."},
+		&Command{Name: "zremrangebyscore", Func: simpleCmd((*SimpleProc).ZREMRANGEBYSCORE), Arity: 4, Flags: CMD_WRITE, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "1.2.0", Summary: "Removes members in a sorted set within a range of scores. Deletes the sorted set if all members were removed."},
+		&Command{Name: "zremrangebylex", Func: simpleCmd((*SimpleProc).ZREMRANGEBYLEX), Arity: 4, Flags: CMD_WRITE, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.8.9", Summary: "Removes members in a sorted set within a lexicographical range. Deletes the sorted set if all members were removed."},
+		&Command{Name: "zpopmin", Func: simpleCmd((*SimpleProc).ZPOPMIN), Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "5.0.0", Summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+		&Command{Name: "zpopmax", Func: simpleCmd((*SimpleProc).ZPOPMAX), Arity: -2, CheckArgs: maxArgs(2), Flags: CMD_WRITE | CMD_FAST, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "5.0.0", Summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+		&Command{Name: "bzpopmin", Func: simpleCmd((*SimpleProc).BZPOPMIN), Arity: -3, Flags: CMD_WRITE | CMD_FAST | CMD_BLOCKING, FirstKey: 1, LastKey: -2, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "5.0.0", Summary: "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped."},
+		&Command{Name: "bzpopmax", Func: simpleCmd((*SimpleProc).BZPOPMAX), Arity: -3, Flags: CMD_WRITE | CMD_FAST | CMD_BLOCKING, FirstKey: 1, LastKey: -2, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "5.0.0", Summary: "Removes and returns the member with the highest score from one or more sorted sets. Blocks until a member available otherwise.  Deletes the sorted set if the last element was popped."},
+		&Command{Name: "zunionstore", Func: simpleCmd((*SimpleProc).ZUNIONSTORE), Arity: -4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1, KeysFunc: destAndNumKeysAt(1),
+			Group: GROUP_SORTED_SET, Since: "2.0.0", Summary: "Stores the union of multiple sorted sets in a key."},
+		&Command{Name: "zinterstore", Func: simpleCmd((*SimpleProc).ZINTERSTORE), Arity: -4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1, KeysFunc: destAndNumKeysAt(1),
+			Group: GROUP_SORTED_SET, Since: "2.0.0", Summary: "Stores the intersect of multiple sorted sets in a key."},
+		&Command{Name: "zdiffstore", Func: simpleCmd((*SimpleProc).ZDIFFSTORE), Arity: -4, Flags: CMD_WRITE | CMD_DENYOOM, FirstKey: 1, LastKey: 1, KeyStep: 1, KeysFunc: destAndNumKeysAt(1),
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Stores the difference of multiple sorted sets in a key."},
+		&Command{Name: "zunion", Func: simpleCmd((*SimpleProc).ZUNION), Arity: -3, Flags: CMD_READONLY, KeysFunc: numKeysAt(0),
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Returns the union of multiple sorted sets."},
+		&Command{Name: "zinter", Func: simpleCmd((*SimpleProc).ZINTER), Arity: -3, Flags: CMD_READONLY, KeysFunc: numKeysAt(0),
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Returns the intersect of multiple sorted sets."},
+		&Command{Name: "zdiff", Func: simpleCmd((*SimpleProc).ZDIFF), Arity: -3, Flags: CMD_READONLY, KeysFunc: numKeysAt(0),
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Returns the difference between multiple sorted sets."},
+		&Command{Name: "zrandmember", Func: simpleCmd((*SimpleProc).ZRANDMEMBER), Arity: -2, CheckArgs: maxArgs(3), Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "6.2.0", Summary: "Returns one or more random members from a sorted set."},
+		&Command{Name: "zscan", Func: simpleCmd((*SimpleProc).ZSCAN), Arity: -3, Flags: CMD_READONLY, FirstKey: 1, LastKey: 1, KeyStep: 1,
+			Group: GROUP_SORTED_SET, Since: "2.8.0", Summary: "Iterates over members and scores of a sorted set."},
+	)
+}
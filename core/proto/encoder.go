@@ -0,0 +1,244 @@
+package proto
+
+import (
+	"io"
+	"strconv"
+)
+
+// StreamFunc : Write elements of a streaming response with encoder
+type StreamFunc func(enc *Encoder) error
+
+// Encoder : Write RESP frames straight to writer, wrap socket with a
+// buffered writer to avoid a syscall per frame
+type Encoder struct {
+	writer   io.Writer
+	protoVer int
+	scratch  []byte
+	err      error
+}
+
+// NewEncoder : Create a new encoder
+//
+// @param writer io.Writer : destination of frames
+// @param protoVer int : protocol version, PROTO_RESP2 or PROTO_RESP3
+func NewEncoder(writer io.Writer, protoVer int) *Encoder {
+	return &Encoder{
+		writer:   writer,
+		protoVer: protoVer,
+		scratch:  make([]byte, 0, 64),
+	}
+}
+
+// SetProtoVer : Update protocol version used by following frames
+func (enc *Encoder) SetProtoVer(protoVer int) {
+	enc.protoVer = protoVer
+}
+
+// GetProtoVer : Get protocol version of encoder
+func (enc *Encoder) GetProtoVer() int {
+	return enc.protoVer
+}
+
+// Err : Get the first error occurred during writing
+func (enc *Encoder) Err() error {
+	return enc.err
+}
+
+// Encode : Write a whole response
+func (enc *Encoder) Encode(res *Response) error {
+	if (PROTO_RESP3 == enc.protoVer) && (nil != res.Attr) {
+		enc.Encode(res.Attr)
+	}
+
+	if res.IsNull {
+		if isAggregate(res.Type) {
+			return enc.writeNull(RES_TYPE_MULTI)
+		}
+		return enc.writeNull(RES_TYPE_BULK)
+	}
+
+	if nil != res.Stream {
+		enc.writeAggregate(res.Type, res.Count)
+		if nil != enc.err {
+			return enc.err
+		}
+		err := res.Stream(enc)
+		if nil == enc.err {
+			enc.err = err
+		}
+		return enc.err
+	}
+
+	switch res.Type {
+	case RES_TYPE_MULTI, RES_TYPE_SET, RES_TYPE_PUSH:
+		enc.writeAggregate(res.Type, len(res.Nest))
+		for _, nest := range res.Nest {
+			enc.Encode(nest)
+		}
+	case RES_TYPE_MAP, RES_TYPE_ATTR:
+		enc.writeAggregate(res.Type, len(res.Nest)/2)
+		for _, nest := range res.Nest {
+			enc.Encode(nest)
+		}
+	case RES_TYPE_BULK:
+		if nil != res.Raw {
+			enc.WriteBulk(res.Raw)
+		} else {
+			enc.WriteBulkString(res.Data)
+		}
+	case RES_TYPE_DOUBLE, RES_TYPE_BIGNUM:
+		if PROTO_RESP3 == enc.protoVer {
+			enc.writeLine(res.Type, res.Data)
+		} else {
+			enc.WriteBulkString(res.Data)
+		}
+	case RES_TYPE_VERBATIM:
+		if PROTO_RESP3 == enc.protoVer {
+			enc.writeBlob(RES_TYPE_VERBATIM, res.Data)
+		} else if len(res.Data) >= 4 {
+			enc.WriteBulkString(res.Data[4:])
+		} else {
+			enc.WriteBulkString(res.Data)
+		}
+	case RES_TYPE_NULL:
+		enc.writeNull(RES_TYPE_BULK)
+	case RES_TYPE_BOOL:
+		if PROTO_RESP3 == enc.protoVer {
+			enc.writeLine(RES_TYPE_BOOL, res.Data)
+		} else if "t" == res.Data {
+			enc.writeLine(RES_TYPE_INT, "1")
+		} else {
+			enc.writeLine(RES_TYPE_INT, "0")
+		}
+	case RES_TYPE_BLOB_ERROR:
+		if PROTO_RESP3 == enc.protoVer {
+			enc.writeBlob(RES_TYPE_BLOB_ERROR, res.Data)
+		} else {
+			enc.writeLine(RES_TYPE_ERROR, res.Data)
+		}
+	default:
+		enc.writeLine(res.Type, res.Data)
+	}
+	return enc.err
+}
+
+// WriteArray : Write header of array, count elements must follow
+func (enc *Encoder) WriteArray(count int) error {
+	return enc.writeAggregate(RES_TYPE_MULTI, count)
+}
+
+// WriteMap : Write header of map, count key value pairs must follow
+func (enc *Encoder) WriteMap(count int) error {
+	return enc.writeAggregate(RES_TYPE_MAP, count)
+}
+
+// WriteSet : Write header of set, count elements must follow
+func (enc *Encoder) WriteSet(count int) error {
+	return enc.writeAggregate(RES_TYPE_SET, count)
+}
+
+// WriteBulk : Write bulk string of raw bytes
+func (enc *Encoder) WriteBulk(data []byte) error {
+	enc.scratch = append(enc.scratch[:0], RES_TYPE_BULK...)
+	enc.scratch = strconv.AppendInt(enc.scratch, int64(len(data)), 10)
+	enc.scratch = append(enc.scratch, MSG_END...)
+	enc.write(enc.scratch)
+	enc.write(data)
+	return enc.write([]byte(MSG_END))
+}
+
+// WriteBulkString : Write bulk string
+func (enc *Encoder) WriteBulkString(data string) error {
+	return enc.writeBlob(RES_TYPE_BULK, data)
+}
+
+// WriteInt : Write integer
+func (enc *Encoder) WriteInt(value int64) error {
+	enc.scratch = append(enc.scratch[:0], RES_TYPE_INT...)
+	enc.scratch = strconv.AppendInt(enc.scratch, value, 10)
+	enc.scratch = append(enc.scratch, MSG_END...)
+	return enc.write(enc.scratch)
+}
+
+// WriteStatus : Write simple string
+func (enc *Encoder) WriteStatus(status string) error {
+	return enc.writeLine(RES_TYPE_STATE, status)
+}
+
+// WriteError : Write error
+func (enc *Encoder) WriteError(err string) error {
+	return enc.writeLine(RES_TYPE_ERROR, err)
+}
+
+// WriteNull : Write null bulk string
+func (enc *Encoder) WriteNull() error {
+	return enc.writeNull(RES_TYPE_BULK)
+}
+
+// WriteDouble : Write double, bulk string in RESP2
+func (enc *Encoder) WriteDouble(value float64) error {
+	if PROTO_RESP3 == enc.protoVer {
+		return enc.writeLine(RES_TYPE_DOUBLE, FormatFloat(value))
+	}
+	return enc.WriteBulkString(FormatFloat(value))
+}
+
+func (enc *Encoder) writeAggregate(t string, count int) error {
+	if PROTO_RESP3 != enc.protoVer {
+		if (RES_TYPE_MAP == t) || (RES_TYPE_ATTR == t) {
+			count = count * 2
+		}
+		t = RES_TYPE_MULTI
+	}
+	enc.scratch = append(enc.scratch[:0], t...)
+	enc.scratch = strconv.AppendInt(enc.scratch, int64(count), 10)
+	enc.scratch = append(enc.scratch, MSG_END...)
+	return enc.write(enc.scratch)
+}
+
+func (enc *Encoder) writeNull(t string) error {
+	if PROTO_RESP3 == enc.protoVer {
+		return enc.writeLine(RES_TYPE_NULL, "")
+	}
+	return enc.writeLine(t, "-1")
+}
+
+func (enc *Encoder) writeBlob(t string, data string) error {
+	enc.scratch = append(enc.scratch[:0], t...)
+	enc.scratch = strconv.AppendInt(enc.scratch, int64(len(data)), 10)
+	enc.scratch = append(enc.scratch, MSG_END...)
+	enc.write(enc.scratch)
+	enc.writeString(data)
+	return enc.write([]byte(MSG_END))
+}
+
+func (enc *Encoder) writeLine(t string, data string) error {
+	enc.scratch = append(enc.scratch[:0], t...)
+	enc.scratch = append(enc.scratch, data...)
+	enc.scratch = append(enc.scratch, MSG_END...)
+	return enc.write(enc.scratch)
+}
+
+func (enc *Encoder) write(data []byte) error {
+	if nil != enc.err {
+		return enc.err
+	}
+	_, enc.err = enc.writer.Write(data)
+	return enc.err
+}
+
+func (enc *Encoder) writeString(data string) error {
+	if nil != enc.err {
+		return enc.err
+	}
+	_, enc.err = io.WriteString(enc.writer, data)
+	return enc.err
+}
+
+func isAggregate(t string) bool {
+	switch t {
+	case RES_TYPE_MULTI, RES_TYPE_MAP, RES_TYPE_SET, RES_TYPE_PUSH, RES_TYPE_ATTR:
+		return true
+	}
+	return false
+}
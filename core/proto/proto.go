@@ -2,6 +2,7 @@ package proto
 
 import (
 	"gredissimulate/logger"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -37,9 +38,31 @@ const RES_TYPE_INT = ":"
 const RES_TYPE_BULK = "$"
 const RES_TYPE_MULTI = "*"
 
-// SocketReader : Read line from socket
+// RESP3 response types, they are downgraded to RESP2 types when the
+// connection does not speak RESP3
+const RES_TYPE_MAP = "%"
+const RES_TYPE_SET = "~"
+const RES_TYPE_DOUBLE = ","
+const RES_TYPE_BOOL = "#"
+const RES_TYPE_NULL = "_"
+const RES_TYPE_BIGNUM = "("
+const RES_TYPE_VERBATIM = "="
+const RES_TYPE_BLOB_ERROR = "!"
+const RES_TYPE_ATTR = "|"
+const RES_TYPE_PUSH = ">"
+
+// PROTO_RESP2 : RESP2 protocol version, the default one of new connection
+const PROTO_RESP2 = 2
+
+// PROTO_RESP3 : RESP3 protocol version, switched by HELLO command
+const PROTO_RESP3 = 3
+
+// SocketReader : Read data from socket
 type SocketReader interface {
+	// ReadLine : Read one line without the trailing line break
 	ReadLine() (string, error)
+	// ReadFull : Read exactly n bytes
+	ReadFull(n int) ([]byte, error)
 }
 
 // ResData : result data struct
@@ -50,9 +73,14 @@ type ResData struct {
 
 // Response : response to client
 type Response struct {
-	Type string
-	Data string
-	Nest []*Response
+	Type   string
+	Data   string
+	Raw    []byte // Raw data of bulk string, used instead of Data if not nil
+	Nest   []*Response
+	Attr   *Response  // Attribute sent ahead of response, RESP3 only
+	IsNull bool       // Null bulk string or null array, differ from empty value
+	Stream StreamFunc // Write elements of aggregate response by encoder
+	Count  int        // Element count of streaming response
 }
 
 // NewResponse : Create a new response
@@ -60,6 +88,35 @@ func NewResponse(t string) *Response {
 	return &Response{Type: t, Data: ""}
 }
 
+// NewNullRes : Create a new null response, RES_TYPE_BULK for null bulk
+// string and RES_TYPE_MULTI for null array
+func NewNullRes(t string) *Response {
+	return &Response{Type: t, Data: "", IsNull: true}
+}
+
+// NewStreamRes : Create a new aggregate response whose elements are written
+// by function when encoding, count is element count of array and set, or
+// pair count of map
+func NewStreamRes(t string, count int, function StreamFunc) *Response {
+	return &Response{Type: t, Stream: function, Count: count}
+}
+
+// NewBulkListRes : Create a new aggregate response of bulk strings, pairs of
+// strings for map. Replies made of items of a locked value collect them by
+// this, items are written straight to the encoder when the reply is sent
+func NewBulkListRes(t string, items []string) *Response {
+	count := len(items)
+	if (RES_TYPE_MAP == t) || (RES_TYPE_ATTR == t) {
+		count = count / 2
+	}
+	return NewStreamRes(t, count, func(enc *Encoder) error {
+		for _, item := range items {
+			enc.WriteBulkString(item)
+		}
+		return enc.Err()
+	})
+}
+
 // NewErrorRes : Create a new error response
 func NewErrorRes(err string) *Response {
 	return &Response{
@@ -71,6 +128,22 @@ func NewErrorRes(err string) *Response {
 // SetString : Set string
 func (res *Response) SetString(content string) {
 	res.Data = content
+	res.IsNull = false
+}
+
+// SetBytes : Set raw bytes without converting to string
+func (res *Response) SetBytes(content []byte) {
+	res.Raw = content
+	res.IsNull = false
+}
+
+// SetNull : Mark response as null
+func (res *Response) SetNull() {
+	res.Data = ""
+	res.Raw = nil
+	res.Nest = nil
+	res.Stream = nil
+	res.IsNull = true
 }
 
 // SetInt : Set int
@@ -78,11 +151,54 @@ func (res *Response) SetInt(value int) {
 	res.Data = strconv.Itoa(value)
 }
 
+// SetFloat : Set float
+func (res *Response) SetFloat(value float64) {
+	res.Data = FormatFloat(value)
+}
+
+// SetBool : Set bool
+func (res *Response) SetBool(value bool) {
+	if value {
+		res.Data = "t"
+	} else {
+		res.Data = "f"
+	}
+}
+
+// SetVerbatim : Set verbatim string with three bytes format, etc: txt, mkd
+func (res *Response) SetVerbatim(format string, content string) {
+	res.Data = format + ":" + content
+}
+
 // SetResponse :
 func (res *Response) SetResponse(content *Response) {
 	res.Nest = append(res.Nest, content)
 }
 
+// SetPair : Append key and value of map or attribute
+func (res *Response) SetPair(key *Response, value *Response) {
+	res.Nest = append(res.Nest, key, value)
+}
+
+// SetAttr : Set attribute of response
+func (res *Response) SetAttr(key *Response, value *Response) {
+	if nil == res.Attr {
+		res.Attr = NewResponse(RES_TYPE_ATTR)
+	}
+	res.Attr.SetPair(key, value)
+}
+
+// FormatFloat : Format float the way redis does
+func FormatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "inf"
+	}
+	if math.IsInf(value, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
 // PARSE_CMD_COUNT : init, parse cmd count
 const PARSE_CMD_COUNT = "parse_cmd_count"
 const PARSE_PARAM_LEN = "parse_param_len"
@@ -90,6 +206,11 @@ const PARSE_PARAM = "parse_param"
 
 const MSG_END = "\r\n"
 
+// MAX_BULK_LEN : Max length of bulk param and max count of params in a
+// request, as proto-max-bulk-len of redis, larger ones are rejected before
+// anything is allocated for them
+const MAX_BULK_LEN = 512 * 1024 * 1024
+
 // ParseError : ParseError
 type ParseError struct {
 	s string
@@ -106,6 +227,13 @@ func (e ParseError) Error() string {
 	return e.s
 }
 
+// Protocol errors of length out of range, rest of input can not be parsed
+// after any ParseError so it is replied and the connection is closed
+var (
+	ErrInvalidBulkLen      = NewParseError("Protocol error: invalid bulk length")
+	ErrInvalidMultibulkLen = NewParseError("Protocol error: invalid multibulk length")
+)
+
 // NetError : NetError
 type NetError struct {
 	s string
@@ -123,8 +251,17 @@ func (e NetError) Error() string {
 // ParseCmd : Parse command
 func (parser *Parser) ParseCmd(reader SocketReader) (*Request, error) {
 	for {
-		content, err := reader.ReadLine()
+		var content string
+		var err error
+		if PARSE_PARAM == parser.pState {
+			content, err = parser.readParam(reader)
+		} else {
+			content, err = reader.ReadLine()
+		}
 		if nil != err {
+			if _, ok := err.(ParseError); ok {
+				return nil, err
+			}
 			return nil, NewNetError(err.Error())
 		}
 
@@ -152,19 +289,30 @@ func (parser *Parser) DoParse(content string) (bool, error) {
 	case PARSE_CMD_COUNT:
 		count, err := parseCmdCount(content)
 		if nil != err {
+			if "" == strings.Trim(content, " ") {
+				// Empty line is ignored as redis does
+				return false, nil
+			}
 			e := parser.parseStrCmd(content)
 			if nil == e {
 				return true, nil
 			}
 			return false, NewParseError(err.Error())
 		}
+		if count > MAX_BULK_LEN {
+			return false, ErrInvalidMultibulkLen
+		}
+		if count <= 0 {
+			// `*0` and `*-1` carry no command, they are ignored as redis does
+			return false, nil
+		}
 		parser.pCountTotal = count
 		parser.pState = PARSE_PARAM_LEN
 
 	case PARSE_PARAM_LEN:
 		pLen, err := parseParamLen(content)
 		if nil != err {
-			return false, NewParseError(err.Error())
+			return false, err
 		}
 		parser.pParamLen = pLen
 		parser.pState = PARSE_PARAM
@@ -185,6 +333,19 @@ func (parser *Parser) DoParse(content string) (bool, error) {
 	return false, nil
 }
 
+// readParam : Read a bulk param with the length declared by `$<len>`, the
+// content is binary safe and may contain line breaks
+func (parser *Parser) readParam(reader SocketReader) (string, error) {
+	buffer, err := reader.ReadFull(parser.pParamLen + len(MSG_END))
+	if nil != err {
+		return "", err
+	}
+	if MSG_END != string(buffer[parser.pParamLen:]) {
+		return "", NewParseError("Protocol error: expected CRLF after bulk")
+	}
+	return string(buffer[:parser.pParamLen]), nil
+}
+
 // GetRequest : Get parse result of request
 func (parser *Parser) GetRequest() *Request {
 	return &Request{
@@ -208,42 +369,41 @@ func (parser *Parser) GetRequest() *Request {
 // 	return []byte(content)
 // }
 
-// BuildResBinary : Convert response to binary result
-func BuildResBinary(response *Response) string {
-	var content string
-	if RES_TYPE_MULTI == response.Type {
-		content = "*" + strconv.Itoa(len(response.Nest)) + MSG_END
-		for _, res := range response.Nest {
-			content = content + BuildResBinary(res)
-		}
-	} else {
-		if RES_TYPE_BULK == response.Type {
-			strLen := len(response.Data)
-			if 0 != strLen {
-				content = response.Type + strconv.Itoa(strLen) + MSG_END + response.Data + MSG_END
-			} else {
-				content = response.Type + "-1" + MSG_END
-			}
-		} else {
-			content = response.Type + response.Data + MSG_END
-		}
-	}
-
-	return content
+// BuildResBinary : Convert response to binary result, prefer Encoder to
+// write large response to socket directly
+//
+// @param response *Response : response to convert
+// @param protoVer int : protocol version of the connection, PROTO_RESP2 or PROTO_RESP3
+func BuildResBinary(response *Response, protoVer int) string {
+	var builder strings.Builder
+	NewEncoder(&builder, protoVer).Encode(response)
+	return builder.String()
 }
 
 func parseCmdCount(content string) (int, error) {
 	if (len(content) <= 0) || ("*" != content[0:1]) {
 		return 0, NewParseError("Cmd count proto error")
 	}
-	return strconv.Atoi(content[1:])
+	count, err := strconv.Atoi(content[1:])
+	if numErr, ok := err.(*strconv.NumError); ok && (strconv.ErrRange == numErr.Err) {
+		// Too large to be an int is out of range as well, not an inline command
+		return MAX_BULK_LEN + 1, nil
+	}
+	return count, err
 }
 
 func parseParamLen(content string) (int, error) {
+	if len(content) <= 0 {
+		return 0, NewParseError("Protocol error: expected '$', got empty line")
+	}
 	if "$" != content[0:1] {
-		return 0, NewParseError("Cmd param length proto error")
+		return 0, NewParseError("Protocol error: expected '$', got '" + content[0:1] + "'")
+	}
+	pLen, err := strconv.Atoi(content[1:])
+	if (nil != err) || (pLen < 0) || (pLen > MAX_BULK_LEN) {
+		return 0, ErrInvalidBulkLen
 	}
-	return strconv.Atoi(content[1:])
+	return pLen, nil
 }
 
 func (parser *Parser) parseStrCmd(content string) error {
@@ -0,0 +1,58 @@
+package proto
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stringReader : Socket reader over a string
+type stringReader struct {
+	reader *bufio.Reader
+}
+
+func (r *stringReader) ReadLine() (string, error) {
+	line, err := r.reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (r *stringReader) ReadFull(n int) ([]byte, error) {
+	buffer := make([]byte, n)
+	_, err := io.ReadFull(r.reader, buffer)
+	return buffer, err
+}
+
+func TestParseCmdSkipsEmptyRequests(t *testing.T) {
+	input := "*1\r\n$4\r\nPING\r\n*0\r\n*-1\r\n\r\n*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n"
+	reader := &stringReader{bufio.NewReader(strings.NewReader(input))}
+
+	wants := []string{"PING", "ECHO hi"}
+	for _, want := range wants {
+		req, err := NewParser().ParseCmd(reader)
+		if nil != err {
+			t.Fatalf("parse %q: got error %v", want, err)
+		}
+		got := strings.Join(append([]string{req.Cmd}, req.Params...), " ")
+		if want != got {
+			t.Fatalf("got request %q, want %q", got, want)
+		}
+	}
+}
+
+func TestParseCmdProtocolErrors(t *testing.T) {
+	inputs := map[string]ParseError{
+		"*1\r\n$4\r\nPINGxx":   NewParseError("Protocol error: expected CRLF after bulk"),
+		"*1\r\n+PING\r\n":      NewParseError("Protocol error: expected '$', got '+'"),
+		"*1\r\n$-2\r\n":        ErrInvalidBulkLen,
+		"*1\r\n$536870913\r\n": ErrInvalidBulkLen,
+		"*536870913\r\n":       ErrInvalidMultibulkLen,
+	}
+	for input, want := range inputs {
+		reader := &stringReader{bufio.NewReader(strings.NewReader(input))}
+		_, err := NewParser().ParseCmd(reader)
+		if want != err {
+			t.Fatalf("parse %q: got error %v, want %v", input, err, want)
+		}
+	}
+}
@@ -0,0 +1,886 @@
+// Package rdb implements parsing of the Redis RDB file format.
+//
+// It is forked from github.com/MagicYH/rdb, itself a fork of
+// github.com/cupcake/rdb, see LICENCE. Besides the types of the fork it
+// decodes streams, binary scores of sorted sets and the listpack encodings
+// of RDB version 10 and later.
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A Decoder must be implemented to parse a RDB file.
+type Decoder interface {
+	// StartRDB is called when parsing of a valid RDB file starts.
+	StartRDB()
+	// StartDatabase is called when database n starts.
+	// Once a database starts, another database will not start until EndDatabase is called.
+	StartDatabase(n int)
+	// AUX field
+	Aux(key, value []byte)
+	// ResizeDB hint
+	ResizeDatabase(dbSize, expiresSize uint64)
+	// Set is called once for each string key.
+	Set(key, value []byte, expiry int64)
+	// StartHash is called at the beginning of a hash.
+	// Hset will be called exactly length times before EndHash.
+	StartHash(key []byte, length, expiry int64)
+	// Hset is called once for each field=value pair in a hash.
+	Hset(key, field, value []byte)
+	// EndHash is called when there are no more fields in a hash.
+	EndHash(key []byte)
+	// StartSet is called at the beginning of a set.
+	// Sadd will be called exactly cardinality times before EndSet.
+	StartSet(key []byte, cardinality, expiry int64)
+	// Sadd is called once for each member of a set.
+	Sadd(key, member []byte)
+	// EndSet is called when there are no more fields in a set.
+	EndSet(key []byte)
+	// StartList is called at the beginning of a list.
+	// Rpush will be called exactly length times before EndList.
+	// If length of the list is not known, then length is -1
+	StartList(key []byte, length, expiry int64)
+	// Rpush is called once for each value in a list.
+	Rpush(key, value []byte)
+	// EndList is called when there are no more values in a list.
+	EndList(key []byte)
+	// StartZSet is called at the beginning of a sorted set.
+	// Zadd will be called exactly cardinality times before EndZSet.
+	StartZSet(key []byte, cardinality, expiry int64)
+	// Zadd is called once for each member of a sorted set.
+	Zadd(key []byte, score float64, member []byte)
+	// EndZSet is called when there are no more members in a sorted set.
+	EndZSet(key []byte)
+	// Stream is called once for each stream with all of its entries and
+	// consumer groups.
+	Stream(key []byte, stream *Stream, expiry int64)
+	// EndDatabase is called at the end of a database.
+	EndDatabase(n int)
+	// EndRDB is called when parsing of the RDB file is complete.
+	EndRDB()
+}
+
+// Decode parses a RDB file from r and calls the decode hooks on d.
+func Decode(r io.Reader, d Decoder) error {
+	decoder := &decode{d, make([]byte, 8), bufio.NewReader(r)}
+	return decoder.decode()
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+type decode struct {
+	event  Decoder
+	intBuf []byte
+	r      byteReader
+}
+
+type ValueType byte
+
+const (
+	TypeString ValueType = 0
+	TypeList   ValueType = 1
+	TypeSet    ValueType = 2
+	TypeZSet   ValueType = 3
+	TypeHash   ValueType = 4
+	TypeZSet2  ValueType = 5
+
+	TypeHashZipmap       ValueType = 9
+	TypeListZiplist      ValueType = 10
+	TypeSetIntset        ValueType = 11
+	TypeZSetZiplist      ValueType = 12
+	TypeHashZiplist      ValueType = 13
+	TypeListQuicklist    ValueType = 14
+	TypeStreamListpacks  ValueType = 15
+	TypeHashListpack     ValueType = 16
+	TypeZSetListpack     ValueType = 17
+	TypeListQuicklist2   ValueType = 18
+	TypeStreamListpacks2 ValueType = 19
+	TypeSetListpack      ValueType = 20
+	TypeStreamListpacks3 ValueType = 21
+)
+
+// MaxVersion is the latest RDB version the decoder understands.
+const MaxVersion = 12
+
+var errLZFCorrupt = errors.New("rdb: corrupt LZF compressed string")
+
+const (
+	rdb6bitLen  = 0
+	rdb14bitLen = 1
+	rdb32bitLen = 0x80
+	rdb64bitLen = 0x81
+	rdbEncVal   = 3
+
+	rdbFlagSlotInfo    = 0xf4
+	rdbFlagFunction2   = 0xf5
+	rdbFlagFunctionPre = 0xf6
+	rdbFlagModuleAux   = 0xf7
+	rdbFlagIdle        = 0xf8
+	rdbFlagFreq        = 0xf9
+	rdbFlagAux         = 0xfa
+	rdbFlagResizeDB    = 0xfb
+	rdbFlagExpiryMS    = 0xfc
+	rdbFlagExpiry      = 0xfd
+	rdbFlagSelectDB    = 0xfe
+	rdbFlagEOF         = 0xff
+
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLZF   = 3
+
+	rdbZiplist6bitlenString  = 0
+	rdbZiplist14bitlenString = 1
+	rdbZiplist32bitlenString = 2
+
+	rdbZiplistInt16 = 0xc0
+	rdbZiplistInt32 = 0xd0
+	rdbZiplistInt64 = 0xe0
+	rdbZiplistInt24 = 0xf0
+	rdbZiplistInt8  = 0xfe
+	rdbZiplistInt4  = 15
+
+	rdbQuicklistNodePlain  = 1
+	rdbQuicklistNodePacked = 2
+)
+
+func (d *decode) decode() error {
+	err := d.checkHeader()
+	if err != nil {
+		return err
+	}
+
+	d.event.StartRDB()
+
+	var db uint64
+	var expiry int64
+	firstDB := true
+	for {
+		objType, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch objType {
+		case rdbFlagSlotInfo:
+			// Slot id, size of slot and size of its expires in cluster mode
+			for i := 0; i < 3; i++ {
+				if _, _, err := d.readLength(); err != nil {
+					return err
+				}
+			}
+		case rdbFlagFunction2:
+			// Code of function library, functions are not supported
+			if _, err := d.readString(); err != nil {
+				return err
+			}
+		case rdbFlagFunctionPre:
+			return fmt.Errorf("rdb: pre-release function format is not supported")
+		case rdbFlagModuleAux:
+			return fmt.Errorf("rdb: module auxiliary data is not supported")
+		case rdbFlagIdle:
+			if _, _, err := d.readLength(); err != nil {
+				return err
+			}
+		case rdbFlagFreq:
+			if _, err := d.r.ReadByte(); err != nil {
+				return err
+			}
+		case rdbFlagAux:
+			auxKey, err := d.readString()
+			if err != nil {
+				return err
+			}
+			auxVal, err := d.readString()
+			if err != nil {
+				return err
+			}
+			d.event.Aux(auxKey, auxVal)
+		case rdbFlagResizeDB:
+			dbSize, _, err := d.readLength()
+			if err != nil {
+				return err
+			}
+			expiresSize, _, err := d.readLength()
+			if err != nil {
+				return err
+			}
+			d.event.ResizeDatabase(dbSize, expiresSize)
+		case rdbFlagExpiryMS:
+			_, err := io.ReadFull(d.r, d.intBuf)
+			if err != nil {
+				return err
+			}
+			expiry = int64(binary.LittleEndian.Uint64(d.intBuf))
+		case rdbFlagExpiry:
+			_, err := io.ReadFull(d.r, d.intBuf[:4])
+			if err != nil {
+				return err
+			}
+			expiry = int64(binary.LittleEndian.Uint32(d.intBuf)) * 1000
+		case rdbFlagSelectDB:
+			if !firstDB {
+				d.event.EndDatabase(int(db))
+			}
+			db, _, err = d.readLength()
+			if err != nil {
+				return err
+			}
+			d.event.StartDatabase(int(db))
+		case rdbFlagEOF:
+			d.event.EndDatabase(int(db))
+			d.event.EndRDB()
+			return nil
+		default:
+			key, err := d.readString()
+			if err != nil {
+				return err
+			}
+			err = d.readObject(key, ValueType(objType), expiry)
+			if err != nil {
+				return err
+			}
+			expiry = 0
+		}
+	}
+}
+
+func (d *decode) readObject(key []byte, typ ValueType, expiry int64) error {
+	switch typ {
+	case TypeString:
+		value, err := d.readString()
+		if err != nil {
+			return err
+		}
+		d.event.Set(key, value, expiry)
+	case TypeList:
+		length, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.event.StartList(key, int64(length), expiry)
+		for i := uint64(0); i < length; i++ {
+			value, err := d.readString()
+			if err != nil {
+				return err
+			}
+			d.event.Rpush(key, value)
+		}
+		d.event.EndList(key)
+	case TypeListQuicklist:
+		length, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.event.StartList(key, int64(-1), expiry)
+		for i := uint64(0); i < length; i++ {
+			if err := d.readZiplist(key, 0, false); err != nil {
+				return err
+			}
+		}
+		d.event.EndList(key)
+	case TypeListQuicklist2:
+		return d.readQuicklist2(key, expiry)
+	case TypeSet:
+		cardinality, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.event.StartSet(key, int64(cardinality), expiry)
+		for i := uint64(0); i < cardinality; i++ {
+			member, err := d.readString()
+			if err != nil {
+				return err
+			}
+			d.event.Sadd(key, member)
+		}
+		d.event.EndSet(key)
+	case TypeZSet, TypeZSet2:
+		cardinality, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.event.StartZSet(key, int64(cardinality), expiry)
+		for i := uint64(0); i < cardinality; i++ {
+			member, err := d.readString()
+			if err != nil {
+				return err
+			}
+			var score float64
+			if typ == TypeZSet2 {
+				score, err = d.readBinaryFloat64()
+			} else {
+				score, err = d.readFloat64()
+			}
+			if err != nil {
+				return err
+			}
+			d.event.Zadd(key, score, member)
+		}
+		d.event.EndZSet(key)
+	case TypeHash:
+		length, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		d.event.StartHash(key, int64(length), expiry)
+		for i := uint64(0); i < length; i++ {
+			field, err := d.readString()
+			if err != nil {
+				return err
+			}
+			value, err := d.readString()
+			if err != nil {
+				return err
+			}
+			d.event.Hset(key, field, value)
+		}
+		d.event.EndHash(key)
+	case TypeHashZipmap:
+		return d.readZipmap(key, expiry)
+	case TypeListZiplist:
+		return d.readZiplist(key, expiry, true)
+	case TypeSetIntset:
+		return d.readIntset(key, expiry)
+	case TypeZSetZiplist:
+		return d.readZiplistZset(key, expiry)
+	case TypeHashZiplist:
+		return d.readZiplistHash(key, expiry)
+	case TypeHashListpack:
+		return d.readListpackHash(key, expiry)
+	case TypeZSetListpack:
+		return d.readListpackZset(key, expiry)
+	case TypeSetListpack:
+		return d.readListpackSet(key, expiry)
+	case TypeStreamListpacks, TypeStreamListpacks2, TypeStreamListpacks3:
+		return d.readStream(key, typ, expiry)
+	default:
+		return fmt.Errorf("rdb: unknown object type %d for key %s", typ, key)
+	}
+	return nil
+}
+
+func (d *decode) readZipmap(key []byte, expiry int64) error {
+	var length int
+	zipmap, err := d.readString()
+	if err != nil {
+		return err
+	}
+	buf := newSliceBuffer(zipmap)
+	lenByte, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	if lenByte >= 254 { // we need to count the items manually
+		length, err = countZipmapItems(buf)
+		length /= 2
+		if err != nil {
+			return err
+		}
+	} else {
+		length = int(lenByte)
+	}
+	d.event.StartHash(key, int64(length), expiry)
+	for i := 0; i < length; i++ {
+		field, err := readZipmapItem(buf, false)
+		if err != nil {
+			return err
+		}
+		value, err := readZipmapItem(buf, true)
+		if err != nil {
+			return err
+		}
+		d.event.Hset(key, field, value)
+	}
+	d.event.EndHash(key)
+	return nil
+}
+
+func readZipmapItem(buf *sliceBuffer, readFree bool) ([]byte, error) {
+	length, free, err := readZipmapItemLength(buf, readFree)
+	if err != nil {
+		return nil, err
+	}
+	if length == -1 {
+		return nil, nil
+	}
+	value, err := buf.Slice(length)
+	if err != nil {
+		return nil, err
+	}
+	_, err = buf.Seek(int64(free), 1)
+	return value, err
+}
+
+func countZipmapItems(buf *sliceBuffer) (int, error) {
+	n := 0
+	for {
+		strLen, free, err := readZipmapItemLength(buf, n%2 != 0)
+		if err != nil {
+			return 0, err
+		}
+		if strLen == -1 {
+			break
+		}
+		_, err = buf.Seek(int64(strLen)+int64(free), 1)
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+	_, err := buf.Seek(0, 0)
+	return n, err
+}
+
+func readZipmapItemLength(buf *sliceBuffer, readFree bool) (int, int, error) {
+	b, err := buf.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch b {
+	case 253:
+		s, err := buf.Slice(5)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(binary.BigEndian.Uint32(s)), int(s[4]), nil
+	case 254:
+		return 0, 0, fmt.Errorf("rdb: invalid zipmap item length")
+	case 255:
+		return -1, 0, nil
+	}
+	var free byte
+	if readFree {
+		free, err = buf.ReadByte()
+	}
+	return int(b), int(free), err
+}
+
+func (d *decode) readZiplist(key []byte, expiry int64, addListEvents bool) error {
+	ziplist, err := d.readString()
+	if err != nil {
+		return err
+	}
+	buf := newSliceBuffer(ziplist)
+	length, err := readZiplistLength(buf)
+	if err != nil {
+		return err
+	}
+	if addListEvents {
+		d.event.StartList(key, length, expiry)
+	}
+	for i := int64(0); i < length; i++ {
+		entry, err := readZiplistEntry(buf)
+		if err != nil {
+			return err
+		}
+		d.event.Rpush(key, entry)
+	}
+	if addListEvents {
+		d.event.EndList(key)
+	}
+	return nil
+}
+
+func (d *decode) readZiplistZset(key []byte, expiry int64) error {
+	ziplist, err := d.readString()
+	if err != nil {
+		return err
+	}
+	buf := newSliceBuffer(ziplist)
+	cardinality, err := readZiplistLength(buf)
+	if err != nil {
+		return err
+	}
+	cardinality /= 2
+	d.event.StartZSet(key, cardinality, expiry)
+	for i := int64(0); i < cardinality; i++ {
+		member, err := readZiplistEntry(buf)
+		if err != nil {
+			return err
+		}
+		scoreBytes, err := readZiplistEntry(buf)
+		if err != nil {
+			return err
+		}
+		score, err := strconv.ParseFloat(string(scoreBytes), 64)
+		if err != nil {
+			return err
+		}
+		d.event.Zadd(key, score, member)
+	}
+	d.event.EndZSet(key)
+	return nil
+}
+
+func (d *decode) readZiplistHash(key []byte, expiry int64) error {
+	ziplist, err := d.readString()
+	if err != nil {
+		return err
+	}
+	buf := newSliceBuffer(ziplist)
+	length, err := readZiplistLength(buf)
+	if err != nil {
+		return err
+	}
+	length /= 2
+	d.event.StartHash(key, length, expiry)
+	for i := int64(0); i < length; i++ {
+		field, err := readZiplistEntry(buf)
+		if err != nil {
+			return err
+		}
+		value, err := readZiplistEntry(buf)
+		if err != nil {
+			return err
+		}
+		d.event.Hset(key, field, value)
+	}
+	d.event.EndHash(key)
+	return nil
+}
+
+func readZiplistLength(buf *sliceBuffer) (int64, error) {
+	buf.Seek(8, 0) // skip the zlbytes and zltail
+	lenBytes, err := buf.Slice(2)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint16(lenBytes)), nil
+}
+
+func readZiplistEntry(buf *sliceBuffer) ([]byte, error) {
+	prevLen, err := buf.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if prevLen == 254 {
+		buf.Seek(4, 1) // skip the 4-byte prevlen
+	}
+
+	header, err := buf.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case header>>6 == rdbZiplist6bitlenString:
+		return buf.Slice(int(header & 0x3f))
+	case header>>6 == rdbZiplist14bitlenString:
+		b, err := buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Slice((int(header&0x3f) << 8) | int(b))
+	case header>>6 == rdbZiplist32bitlenString:
+		lenBytes, err := buf.Slice(4)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Slice(int(binary.BigEndian.Uint32(lenBytes)))
+	case header == rdbZiplistInt16:
+		intBytes, err := buf.Slice(2)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(intBytes))), 10)), nil
+	case header == rdbZiplistInt32:
+		intBytes, err := buf.Slice(4)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(intBytes))), 10)), nil
+	case header == rdbZiplistInt64:
+		intBytes, err := buf.Slice(8)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(binary.LittleEndian.Uint64(intBytes)), 10)), nil
+	case header == rdbZiplistInt24:
+		intBytes := make([]byte, 4)
+		_, err := buf.Read(intBytes[1:])
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(intBytes))>>8), 10)), nil
+	case header == rdbZiplistInt8:
+		b, err := buf.ReadByte()
+		return []byte(strconv.FormatInt(int64(int8(b)), 10)), err
+	case header>>4 == rdbZiplistInt4:
+		return []byte(strconv.FormatInt(int64(header&0x0f)-1, 10)), nil
+	}
+
+	return nil, fmt.Errorf("rdb: unknown ziplist header byte: %d", header)
+}
+
+func (d *decode) readIntset(key []byte, expiry int64) error {
+	intset, err := d.readString()
+	if err != nil {
+		return err
+	}
+	buf := newSliceBuffer(intset)
+	intSizeBytes, err := buf.Slice(4)
+	if err != nil {
+		return err
+	}
+	intSize := binary.LittleEndian.Uint32(intSizeBytes)
+
+	if intSize != 2 && intSize != 4 && intSize != 8 {
+		return fmt.Errorf("rdb: unknown intset encoding: %d", intSize)
+	}
+
+	lenBytes, err := buf.Slice(4)
+	if err != nil {
+		return err
+	}
+	cardinality := binary.LittleEndian.Uint32(lenBytes)
+
+	d.event.StartSet(key, int64(cardinality), expiry)
+	for i := uint32(0); i < cardinality; i++ {
+		intBytes, err := buf.Slice(int(intSize))
+		if err != nil {
+			return err
+		}
+		var intString string
+		switch intSize {
+		case 2:
+			intString = strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(intBytes))), 10)
+		case 4:
+			intString = strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(intBytes))), 10)
+		case 8:
+			intString = strconv.FormatInt(int64(int64(binary.LittleEndian.Uint64(intBytes))), 10)
+		}
+		d.event.Sadd(key, []byte(intString))
+	}
+	d.event.EndSet(key)
+	return nil
+}
+
+func (d *decode) checkHeader() error {
+	header := make([]byte, 9)
+	_, err := io.ReadFull(d.r, header)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(header[:5], []byte("REDIS")) {
+		return fmt.Errorf("rdb: invalid file format")
+	}
+
+	version, _ := strconv.ParseInt(string(header[5:]), 10, 64)
+	if version < 1 || version > MaxVersion {
+		return fmt.Errorf("rdb: invalid RDB version number %d", version)
+	}
+
+	return nil
+}
+
+func (d *decode) readString() ([]byte, error) {
+	length, encoded, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if encoded {
+		switch length {
+		case rdbEncInt8:
+			i, err := d.readUint8()
+			return []byte(strconv.FormatInt(int64(int8(i)), 10)), err
+		case rdbEncInt16:
+			i, err := d.readUint16()
+			return []byte(strconv.FormatInt(int64(int16(i)), 10)), err
+		case rdbEncInt32:
+			i, err := d.readUint32()
+			return []byte(strconv.FormatInt(int64(int32(i)), 10)), err
+		case rdbEncLZF:
+			clen, _, err := d.readLength()
+			if err != nil {
+				return nil, err
+			}
+			ulen, _, err := d.readLength()
+			if err != nil {
+				return nil, err
+			}
+			compressed := make([]byte, clen)
+			_, err = io.ReadFull(d.r, compressed)
+			if err != nil {
+				return nil, err
+			}
+			return lzfDecompress(compressed, int(ulen))
+		}
+	}
+
+	str := make([]byte, length)
+	_, err = io.ReadFull(d.r, str)
+	return str, err
+}
+
+func (d *decode) readUint8() (uint8, error) {
+	b, err := d.r.ReadByte()
+	return uint8(b), err
+}
+
+func (d *decode) readUint16() (uint16, error) {
+	_, err := io.ReadFull(d.r, d.intBuf[:2])
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(d.intBuf), nil
+}
+
+func (d *decode) readUint32() (uint32, error) {
+	_, err := io.ReadFull(d.r, d.intBuf[:4])
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(d.intBuf), nil
+}
+
+func (d *decode) readUint64() (uint64, error) {
+	_, err := io.ReadFull(d.r, d.intBuf)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(d.intBuf), nil
+}
+
+func (d *decode) readUint32Big() (uint32, error) {
+	_, err := io.ReadFull(d.r, d.intBuf[:4])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(d.intBuf), nil
+}
+
+func (d *decode) readUint64Big() (uint64, error) {
+	_, err := io.ReadFull(d.r, d.intBuf)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(d.intBuf), nil
+}
+
+// Scores of RDB_TYPE_ZSET_2 are saved as little endian IEEE 754 doubles.
+func (d *decode) readBinaryFloat64() (float64, error) {
+	bits, err := d.readUint64()
+	return math.Float64frombits(bits), err
+}
+
+// Doubles are saved as strings prefixed by an unsigned
+// 8 bit integer specifying the length of the representation.
+// This 8 bit integer has special values in order to specify the following
+// conditions:
+// 253: not a number
+// 254: + inf
+// 255: - inf
+func (d *decode) readFloat64() (float64, error) {
+	length, err := d.readUint8()
+	if err != nil {
+		return 0, err
+	}
+	switch length {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(0), nil
+	case 255:
+		return math.Inf(-1), nil
+	default:
+		floatBytes := make([]byte, length)
+		_, err := io.ReadFull(d.r, floatBytes)
+		if err != nil {
+			return 0, err
+		}
+		f, err := strconv.ParseFloat(string(floatBytes), 64)
+		return f, err
+	}
+}
+
+func (d *decode) readLength() (uint64, bool, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	// The first two bits of the first byte are used to indicate the length encoding type
+	switch (b & 0xc0) >> 6 {
+	case rdb6bitLen:
+		// When the first two bits are 00, the next 6 bits are the length.
+		return uint64(b & 0x3f), false, nil
+	case rdb14bitLen:
+		// When the first two bits are 01, the next 14 bits are the length.
+		bb, err := d.r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return (uint64(b&0x3f) << 8) | uint64(bb), false, nil
+	case rdbEncVal:
+		// When the first two bits are 11, the next object is encoded.
+		// The next 6 bits indicate the encoding type.
+		return uint64(b & 0x3f), true, nil
+	}
+
+	// When the first two bits are 10, the whole byte tells whether the
+	// next 4 or 8 bytes are the length.
+	switch b {
+	case rdb32bitLen:
+		length, err := d.readUint32Big()
+		return uint64(length), false, err
+	case rdb64bitLen:
+		length, err := d.readUint64Big()
+		return length, false, err
+	}
+	return 0, false, fmt.Errorf("rdb: unknown length encoding byte: %d", b)
+}
+
+func lzfDecompress(in []byte, outlen int) ([]byte, error) {
+	out := make([]byte, outlen)
+	i, o := 0, 0
+	for i < len(in) {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 32 {
+			// Literal run of ctrl+1 bytes
+			if i+ctrl+1 > len(in) || o+ctrl+1 > outlen {
+				return nil, errLZFCorrupt
+			}
+			o += copy(out[o:], in[i:i+ctrl+1])
+			i += ctrl + 1
+			continue
+		}
+
+		// Back reference of length+2 bytes
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(in) {
+				return nil, errLZFCorrupt
+			}
+			length = length + int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errLZFCorrupt
+		}
+		ref := o - ((ctrl & 0x1f) << 8) - int(in[i]) - 1
+		i++
+		if ref < 0 || o+length+2 > outlen {
+			return nil, errLZFCorrupt
+		}
+		for x := 0; x <= length+1; x++ {
+			out[o] = out[ref]
+			ref++
+			o++
+		}
+	}
+	if o != outlen {
+		return nil, fmt.Errorf("rdb: decompressed string length %d didn't match expected length %d", o, outlen)
+	}
+	return out, nil
+}
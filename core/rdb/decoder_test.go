@@ -0,0 +1,371 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rdbBuilder writes an RDB file the way Redis saves it.
+type rdbBuilder struct {
+	bytes.Buffer
+}
+
+func newRDB(version int) *rdbBuilder {
+	b := &rdbBuilder{}
+	fmt.Fprintf(b, "REDIS%04d", version)
+	b.WriteByte(rdbFlagSelectDB)
+	b.length(0)
+	return b
+}
+
+func (b *rdbBuilder) length(n uint64) {
+	switch {
+	case n < 1<<6:
+		b.WriteByte(byte(n))
+	case n < 1<<14:
+		b.WriteByte(byte(n>>8) | 0x40)
+		b.WriteByte(byte(n))
+	case n <= math.MaxUint32:
+		b.WriteByte(rdb32bitLen)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	default:
+		b.WriteByte(rdb64bitLen)
+		binary.Write(b, binary.BigEndian, n)
+	}
+}
+
+func (b *rdbBuilder) str(s []byte) {
+	b.length(uint64(len(s)))
+	b.Write(s)
+}
+
+func (b *rdbBuilder) key(typ ValueType, key string) {
+	b.WriteByte(byte(typ))
+	b.str([]byte(key))
+}
+
+func (b *rdbBuilder) millis(ms int64) {
+	binary.Write(b, binary.LittleEndian, ms)
+}
+
+func (b *rdbBuilder) streamID(id StreamID) {
+	b.length(id.Ms)
+	b.length(id.Seq)
+}
+
+func (b *rdbBuilder) rawStreamID(id StreamID) {
+	b.Write(streamNodeKey(id))
+}
+
+func streamNodeKey(id StreamID) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], id.Ms)
+	binary.BigEndian.PutUint64(key[8:], id.Seq)
+	return key
+}
+
+func (b *rdbBuilder) end() []byte {
+	b.WriteByte(rdbFlagEOF)
+	b.Write(make([]byte, 8)) // Checksum is not verified
+	return b.Bytes()
+}
+
+// listpackBuilder writes a listpack with the smallest encoding of each
+// element, as Redis does.
+type listpackBuilder struct {
+	body  bytes.Buffer
+	count int
+}
+
+func (lp *listpackBuilder) int(v int64) *listpackBuilder {
+	start := lp.body.Len()
+	switch {
+	case v >= 0 && v <= 127:
+		lp.body.WriteByte(byte(v))
+	case v >= -4096 && v <= 4095:
+		u := uint16(v) & 0x1fff
+		lp.body.WriteByte(byte(u>>8) | listpack13bitInt)
+		lp.body.WriteByte(byte(u))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		lp.body.WriteByte(listpack16bitInt)
+		binary.Write(&lp.body, binary.LittleEndian, int16(v))
+	case v >= -1<<23 && v < 1<<23:
+		lp.body.WriteByte(listpack24bitInt)
+		u := uint32(v)
+		lp.body.Write([]byte{byte(u), byte(u >> 8), byte(u >> 16)})
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		lp.body.WriteByte(listpack32bitInt)
+		binary.Write(&lp.body, binary.LittleEndian, int32(v))
+	default:
+		lp.body.WriteByte(listpack64bitInt)
+		binary.Write(&lp.body, binary.LittleEndian, v)
+	}
+	lp.backlen(lp.body.Len() - start)
+	return lp
+}
+
+func (lp *listpackBuilder) str(s string) *listpackBuilder {
+	// Redis saves strings which look like integers as integers
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(v, 10) == s {
+		return lp.int(v)
+	}
+	start := lp.body.Len()
+	switch {
+	case len(s) < 1<<6:
+		lp.body.WriteByte(byte(len(s)) | listpack6bitStr)
+	case len(s) < 1<<12:
+		lp.body.WriteByte(byte(len(s)>>8) | listpack12bitStr)
+		lp.body.WriteByte(byte(len(s)))
+	default:
+		lp.body.WriteByte(listpack32bitStr)
+		binary.Write(&lp.body, binary.LittleEndian, uint32(len(s)))
+	}
+	lp.body.WriteString(s)
+	lp.backlen(lp.body.Len() - start)
+	return lp
+}
+
+func (lp *listpackBuilder) backlen(size int) {
+	n := listpackBacklenSize(size)
+	for i := n - 1; i >= 0; i-- {
+		b := byte(size>>(7*uint(i))) & 0x7f
+		if i != n-1 {
+			b |= 0x80
+		}
+		lp.body.WriteByte(b)
+	}
+	lp.count++
+}
+
+func (lp *listpackBuilder) bytes() []byte {
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, uint32(listpackHeaderSize+lp.body.Len()+1))
+	binary.Write(&out, binary.LittleEndian, uint16(lp.count))
+	out.Write(lp.body.Bytes())
+	out.WriteByte(listpackEnd)
+	return out.Bytes()
+}
+
+// streamNodeEntry is an entry written to a stream node, fields is nil if
+// the entry has the fields of the master entry.
+type streamNodeEntry struct {
+	id      StreamID
+	deleted bool
+	fields  []string
+	values  []string
+}
+
+func streamNode(master StreamID, masterFields []string, entries []streamNodeEntry) []byte {
+	lp := &listpackBuilder{}
+	live := 0
+	for _, entry := range entries {
+		if !entry.deleted {
+			live++
+		}
+	}
+	lp.int(int64(live)).int(int64(len(entries) - live)).int(int64(len(masterFields)))
+	for _, field := range masterFields {
+		lp.str(field)
+	}
+	lp.int(0)
+	for _, entry := range entries {
+		flags := int64(0)
+		if entry.deleted {
+			flags |= streamItemFlagDeleted
+		}
+		if nil == entry.fields {
+			flags |= streamItemFlagSameFields
+		}
+		lp.int(flags).int(int64(entry.id.Ms - master.Ms)).int(int64(entry.id.Seq - master.Seq))
+		count := 3 + len(entry.values)
+		if nil == entry.fields {
+			for _, value := range entry.values {
+				lp.str(value)
+			}
+		} else {
+			lp.int(int64(len(entry.fields)))
+			for i, field := range entry.fields {
+				lp.str(field).str(entry.values[i])
+			}
+			count += len(entry.fields) + 1
+		}
+		lp.int(int64(count))
+	}
+	return lp.bytes()
+}
+
+// recorder records decoded keys as strings.
+type recorder struct {
+	NopDecoder
+	values  map[string][]string
+	streams map[string]*Stream
+}
+
+func newRecorder() *recorder {
+	return &recorder{values: map[string][]string{}, streams: map[string]*Stream{}}
+}
+
+func (r *recorder) add(key []byte, items ...string) {
+	r.values[string(key)] = append(r.values[string(key)], items...)
+}
+
+func (r *recorder) Set(key, value []byte, expiry int64) { r.add(key, string(value)) }
+func (r *recorder) Hset(key, field, value []byte)       { r.add(key, string(field), string(value)) }
+func (r *recorder) Sadd(key, member []byte)             { r.add(key, string(member)) }
+func (r *recorder) Rpush(key, value []byte)             { r.add(key, string(value)) }
+func (r *recorder) Zadd(key []byte, score float64, member []byte) {
+	r.add(key, string(member), strconv.FormatFloat(score, 'g', -1, 64))
+}
+func (r *recorder) Stream(key []byte, stream *Stream, expiry int64) {
+	r.streams[string(key)] = stream
+}
+
+func TestDecodeStream(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	nodes := [][]byte{
+		streamNode(StreamID{1000, 7}, []string{"a", "b"}, []streamNodeEntry{
+			{id: StreamID{1000, 7}, values: []string{"1", "2"}},
+			{id: StreamID{1000, 8}, deleted: true, values: []string{"3", "4"}},
+			{id: StreamID{1002, 5}, fields: []string{"c"}, values: []string{long}},
+		}),
+		streamNode(StreamID{1700000000000, 0}, []string{"d"}, []streamNodeEntry{
+			{id: StreamID{1700000000000, 0}, values: []string{"-70000"}},
+		}),
+	}
+	entries := []StreamEntry{
+		{StreamID{1000, 7}, [][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}},
+		{StreamID{1002, 5}, [][]byte{[]byte("c"), []byte(long)}},
+		{StreamID{1700000000000, 0}, [][]byte{[]byte("d"), []byte("-70000")}},
+	}
+
+	for _, typ := range []ValueType{TypeStreamListpacks, TypeStreamListpacks2, TypeStreamListpacks3} {
+		b := newRDB(11)
+		b.key(typ, "s")
+		b.length(uint64(len(nodes)))
+		b.str(streamNodeKey(StreamID{1000, 7}))
+		b.str(nodes[0])
+		b.str(streamNodeKey(StreamID{1700000000000, 0}))
+		b.str(nodes[1])
+		b.length(3)
+		b.streamID(StreamID{1700000000000, 0})
+		if typ >= TypeStreamListpacks2 {
+			b.streamID(StreamID{1000, 7})
+			b.streamID(StreamID{1000, 8})
+			b.length(4)
+		}
+		b.length(1)
+		b.str([]byte("g"))
+		b.streamID(StreamID{1002, 5})
+		if typ >= TypeStreamListpacks2 {
+			b.length(3)
+		}
+		b.length(2)
+		b.rawStreamID(StreamID{1000, 7})
+		b.millis(5000)
+		b.length(2)
+		b.rawStreamID(StreamID{1002, 5})
+		b.millis(6000)
+		b.length(1)
+		b.length(2)
+		for _, consumer := range []string{"alice", "bob"} {
+			b.str([]byte(consumer))
+			b.millis(7000)
+			if typ >= TypeStreamListpacks3 {
+				b.millis(6500)
+			}
+			b.length(1)
+			if "alice" == consumer {
+				b.rawStreamID(StreamID{1002, 5})
+			} else {
+				b.rawStreamID(StreamID{1000, 7})
+			}
+		}
+
+		r := newRecorder()
+		if err := Decode(bytes.NewReader(b.end()), r); err != nil {
+			t.Fatalf("type %d: %v", typ, err)
+		}
+		want := &Stream{
+			Entries:      entries,
+			LastID:       StreamID{1700000000000, 0},
+			FirstID:      StreamID{1000, 7},
+			MaxDeletedID: StreamID{1000, 8},
+			EntriesAdded: 4,
+			Groups: []*StreamGroup{{
+				Name:        []byte("g"),
+				LastID:      StreamID{1002, 5},
+				EntriesRead: 3,
+				Pending: []*StreamPending{
+					{ID: StreamID{1000, 7}, Consumer: []byte("bob"), DeliveryTime: 5000, DeliveryCount: 2},
+					{ID: StreamID{1002, 5}, Consumer: []byte("alice"), DeliveryTime: 6000, DeliveryCount: 1},
+				},
+				Consumers: []*StreamConsumer{
+					{Name: []byte("alice"), SeenTime: 7000, ActiveTime: 6500},
+					{Name: []byte("bob"), SeenTime: 7000, ActiveTime: 6500},
+				},
+			}},
+		}
+		if typ < TypeStreamListpacks3 {
+			for _, consumer := range want.Groups[0].Consumers {
+				consumer.ActiveTime = consumer.SeenTime
+			}
+		}
+		if typ < TypeStreamListpacks2 {
+			want.MaxDeletedID = StreamID{}
+			want.EntriesAdded = 3
+			want.Groups[0].EntriesRead = -1
+		}
+		if got := r.streams["s"]; !reflect.DeepEqual(want, got) {
+			t.Fatalf("type %d: got %+v, want %+v", typ, got, want)
+		}
+	}
+}
+
+func TestDecodeListpackTypes(t *testing.T) {
+	long := strings.Repeat("y", 5000)
+	b := newRDB(11)
+	b.key(TypeHashListpack, "hash")
+	b.str((&listpackBuilder{}).str("f1").str("v1").str("f2").str("12345678").bytes())
+	b.key(TypeZSetListpack, "zset")
+	b.str((&listpackBuilder{}).str("a").str("1.5").str("b").str("-3").str("c").str("inf").bytes())
+	b.key(TypeSetListpack, "set")
+	b.str((&listpackBuilder{}).str("m").str(long).str("-5000").bytes())
+	b.key(TypeListQuicklist2, "list")
+	b.length(2)
+	b.length(rdbQuicklistNodePacked)
+	b.str((&listpackBuilder{}).str("x").str("2147483648").bytes())
+	b.length(rdbQuicklistNodePlain)
+	b.str([]byte(long))
+	b.key(TypeZSet2, "zset2")
+	b.length(1)
+	b.str([]byte("z"))
+	binary.Write(b, binary.LittleEndian, math.Float64bits(0.25))
+	b.WriteByte(rdbFlagIdle)
+	b.length(100)
+	b.WriteByte(rdbFlagFreq)
+	b.WriteByte(5)
+	b.key(TypeString, "string")
+	b.str([]byte("value"))
+
+	r := newRecorder()
+	if err := Decode(bytes.NewReader(b.end()), r); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"hash":   {"f1", "v1", "f2", "12345678"},
+		"zset":   {"a", "1.5", "b", "-3", "c", "+Inf"},
+		"set":    {"m", long, "-5000"},
+		"list":   {"x", "2147483648", long},
+		"zset2":  {"z", "0.25"},
+		"string": {"value"},
+	}
+	if !reflect.DeepEqual(want, r.values) {
+		t.Fatalf("got %v, want %v", r.values, want)
+	}
+}
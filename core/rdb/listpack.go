@@ -0,0 +1,258 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+const (
+	listpackHeaderSize = 6 // Total bytes and element count
+	listpackEnd        = 0xff
+
+	listpack7bitUint   = 0x00
+	listpack6bitStr    = 0x80
+	listpack13bitInt   = 0xc0
+	listpack12bitStr   = 0xe0
+	listpack32bitStr   = 0xf0
+	listpack16bitInt   = 0xf1
+	listpack24bitInt   = 0xf2
+	listpack32bitInt   = 0xf3
+	listpack64bitInt   = 0xf4
+	listpackStrMask6   = 0xc0
+	listpackIntMask13  = 0xe0
+	listpackStrMask12  = 0xf0
+	listpackUintMask7  = 0x80
+	listpackIntSign13  = 1 << 12
+	listpackIntRange13 = 1 << 13
+)
+
+// listpackEntry is an element of a listpack, which holds either a string or
+// an integer.
+type listpackEntry struct {
+	str   []byte
+	value int64
+	isInt bool
+}
+
+// Bytes returns the string of the entry, integers are formatted in decimal.
+func (e listpackEntry) Bytes() []byte {
+	if e.isInt {
+		return strconv.AppendInt(nil, e.value, 10)
+	}
+	return e.str
+}
+
+// Int returns the integer of the entry, strings are parsed as decimal.
+func (e listpackEntry) Int() (int64, error) {
+	if e.isInt {
+		return e.value, nil
+	}
+	return strconv.ParseInt(string(e.str), 10, 64)
+}
+
+// Float returns the number of the entry, as scores of sorted sets are saved.
+func (e listpackEntry) Float() (float64, error) {
+	if e.isInt {
+		return float64(e.value), nil
+	}
+	return strconv.ParseFloat(string(e.str), 64)
+}
+
+// readListpack parses all elements of a listpack.
+func readListpack(lp []byte) ([]listpackEntry, error) {
+	buf := newSliceBuffer(lp)
+	if _, err := buf.Slice(listpackHeaderSize); err != nil {
+		return nil, err
+	}
+	entries := []listpackEntry{}
+	for {
+		header, err := buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == listpackEnd {
+			return entries, nil
+		}
+		entry, size, err := readListpackEntry(buf, header)
+		if err != nil {
+			return nil, err
+		}
+		// Skip the backlen, which is only used to iterate backwards
+		if _, err = buf.Slice(listpackBacklenSize(size)); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// readListpackEntry reads the element after its first byte, and returns the
+// size of its encoding and data.
+func readListpackEntry(buf *sliceBuffer, header byte) (listpackEntry, int, error) {
+	switch {
+	case header&listpackUintMask7 == listpack7bitUint:
+		return listpackEntry{value: int64(header), isInt: true}, 1, nil
+	case header&listpackStrMask6 == listpack6bitStr:
+		length := int(header & 0x3f)
+		str, err := buf.Slice(length)
+		return listpackEntry{str: str}, 1 + length, err
+	case header&listpackIntMask13 == listpack13bitInt:
+		b, err := buf.ReadByte()
+		value := int64(header&0x1f)<<8 | int64(b)
+		if value >= listpackIntSign13 {
+			value -= listpackIntRange13
+		}
+		return listpackEntry{value: value, isInt: true}, 2, err
+	case header&listpackStrMask12 == listpack12bitStr:
+		b, err := buf.ReadByte()
+		if err != nil {
+			return listpackEntry{}, 0, err
+		}
+		length := int(header&0x0f)<<8 | int(b)
+		str, err := buf.Slice(length)
+		return listpackEntry{str: str}, 2 + length, err
+	case header == listpack32bitStr:
+		lenBytes, err := buf.Slice(4)
+		if err != nil {
+			return listpackEntry{}, 0, err
+		}
+		length := int(binary.LittleEndian.Uint32(lenBytes))
+		str, err := buf.Slice(length)
+		return listpackEntry{str: str}, 5 + length, err
+	case header == listpack16bitInt:
+		intBytes, err := buf.Slice(2)
+		if err != nil {
+			return listpackEntry{}, 0, err
+		}
+		return listpackEntry{value: int64(int16(binary.LittleEndian.Uint16(intBytes))), isInt: true}, 3, nil
+	case header == listpack24bitInt:
+		intBytes, err := buf.Slice(3)
+		if err != nil {
+			return listpackEntry{}, 0, err
+		}
+		value := int32(uint32(intBytes[0])<<8|uint32(intBytes[1])<<16|uint32(intBytes[2])<<24) >> 8
+		return listpackEntry{value: int64(value), isInt: true}, 4, nil
+	case header == listpack32bitInt:
+		intBytes, err := buf.Slice(4)
+		if err != nil {
+			return listpackEntry{}, 0, err
+		}
+		return listpackEntry{value: int64(int32(binary.LittleEndian.Uint32(intBytes))), isInt: true}, 5, nil
+	case header == listpack64bitInt:
+		intBytes, err := buf.Slice(8)
+		if err != nil {
+			return listpackEntry{}, 0, err
+		}
+		return listpackEntry{value: int64(binary.LittleEndian.Uint64(intBytes)), isInt: true}, 9, nil
+	}
+	return listpackEntry{}, 0, fmt.Errorf("rdb: unknown listpack encoding byte: %d", header)
+}
+
+// listpackBacklenSize is the size of the backlen following an element whose
+// encoding and data take size bytes.
+func listpackBacklenSize(size int) int {
+	switch {
+	case size <= 127:
+		return 1
+	case size < 16383:
+		return 2
+	case size < 2097151:
+		return 3
+	case size < 268435455:
+		return 4
+	}
+	return 5
+}
+
+func (d *decode) readListpack() ([]listpackEntry, error) {
+	lp, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	return readListpack(lp)
+}
+
+func (d *decode) readListpackHash(key []byte, expiry int64) error {
+	entries, err := d.readListpack()
+	if err != nil {
+		return err
+	}
+	if len(entries)%2 != 0 {
+		return fmt.Errorf("rdb: odd element count %d in listpack of hash %s", len(entries), key)
+	}
+	d.event.StartHash(key, int64(len(entries)/2), expiry)
+	for i := 0; i < len(entries); i += 2 {
+		d.event.Hset(key, entries[i].Bytes(), entries[i+1].Bytes())
+	}
+	d.event.EndHash(key)
+	return nil
+}
+
+func (d *decode) readListpackZset(key []byte, expiry int64) error {
+	entries, err := d.readListpack()
+	if err != nil {
+		return err
+	}
+	if len(entries)%2 != 0 {
+		return fmt.Errorf("rdb: odd element count %d in listpack of sorted set %s", len(entries), key)
+	}
+	d.event.StartZSet(key, int64(len(entries)/2), expiry)
+	for i := 0; i < len(entries); i += 2 {
+		score, err := entries[i+1].Float()
+		if err != nil {
+			return err
+		}
+		d.event.Zadd(key, score, entries[i].Bytes())
+	}
+	d.event.EndZSet(key)
+	return nil
+}
+
+func (d *decode) readListpackSet(key []byte, expiry int64) error {
+	entries, err := d.readListpack()
+	if err != nil {
+		return err
+	}
+	d.event.StartSet(key, int64(len(entries)), expiry)
+	for _, entry := range entries {
+		d.event.Sadd(key, entry.Bytes())
+	}
+	d.event.EndSet(key)
+	return nil
+}
+
+// Nodes of RDB_TYPE_LIST_QUICKLIST_2 are either a listpack of elements or
+// a single large element saved as it is.
+func (d *decode) readQuicklist2(key []byte, expiry int64) error {
+	length, _, err := d.readLength()
+	if err != nil {
+		return err
+	}
+	d.event.StartList(key, int64(-1), expiry)
+	for i := uint64(0); i < length; i++ {
+		container, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		switch container {
+		case rdbQuicklistNodePlain:
+			value, err := d.readString()
+			if err != nil {
+				return err
+			}
+			d.event.Rpush(key, value)
+		case rdbQuicklistNodePacked:
+			entries, err := d.readListpack()
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				d.event.Rpush(key, entry.Bytes())
+			}
+		default:
+			return fmt.Errorf("rdb: unknown quicklist node container %d for key %s", container, key)
+		}
+	}
+	d.event.EndList(key)
+	return nil
+}
@@ -0,0 +1,345 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	streamItemFlagDeleted    = 1 // Entry is deleted, it is kept until its node is rewritten
+	streamItemFlagSameFields = 2 // Entry has the fields of the master entry of its node
+)
+
+// StreamID is the ID of a stream entry.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// StreamEntry is an entry of a stream, Fields are pairs of field and value.
+type StreamEntry struct {
+	ID     StreamID
+	Fields [][]byte
+}
+
+// Stream is a stream with its entries in order of ID and consumer groups.
+type Stream struct {
+	Entries      []StreamEntry
+	LastID       StreamID
+	FirstID      StreamID
+	MaxDeletedID StreamID
+	// EntriesAdded is the count of entries ever added, RDB before version
+	// 10 does not save it and it is the count of entries then.
+	EntriesAdded int64
+	Groups       []*StreamGroup
+}
+
+// StreamGroup is a consumer group of a stream.
+type StreamGroup struct {
+	Name   []byte
+	LastID StreamID
+	// EntriesRead is the count of entries delivered to the group, -1 if it
+	// is unknown or RDB before version 10 does not save it.
+	EntriesRead int64
+	Pending     []*StreamPending // Entries delivered but not acknowledged, in order of ID
+	Consumers   []*StreamConsumer
+}
+
+// StreamPending is an entry delivered to a consumer but not acknowledged.
+type StreamPending struct {
+	ID            StreamID
+	Consumer      []byte // Name of the consumer owning the entry
+	DeliveryTime  int64  // Unix time in milliseconds
+	DeliveryCount uint64
+}
+
+// StreamConsumer is a consumer of a consumer group.
+type StreamConsumer struct {
+	Name     []byte
+	SeenTime int64 // Unix time in milliseconds
+	// ActiveTime is the Unix time in milliseconds of the last successful
+	// interaction, RDB before version 11 does not save it and it is the seen
+	// time then.
+	ActiveTime int64
+}
+
+// Streams are saved as the listpacks of their radix tree nodes, each keyed
+// by the ID of its master entry, followed by metadata and consumer groups.
+func (d *decode) readStream(key []byte, typ ValueType, expiry int64) error {
+	stream := &Stream{}
+	nodes, _, err := d.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < nodes; i++ {
+		nodeKey, err := d.readString()
+		if err != nil {
+			return err
+		}
+		if len(nodeKey) != 16 {
+			return fmt.Errorf("rdb: invalid stream node key length %d for key %s", len(nodeKey), key)
+		}
+		entries, err := d.readListpack()
+		if err != nil {
+			return err
+		}
+		stream.Entries, err = appendStreamEntries(stream.Entries, decodeStreamID(nodeKey), entries)
+		if err != nil {
+			return fmt.Errorf("rdb: %s in stream %s", err.Error(), key)
+		}
+	}
+
+	length, _, err := d.readLength()
+	if err != nil {
+		return err
+	}
+	if stream.LastID, err = d.readStreamID(); err != nil {
+		return err
+	}
+	if typ >= TypeStreamListpacks2 {
+		if stream.FirstID, err = d.readStreamID(); err != nil {
+			return err
+		}
+		if stream.MaxDeletedID, err = d.readStreamID(); err != nil {
+			return err
+		}
+		entriesAdded, _, err := d.readLength()
+		if err != nil {
+			return err
+		}
+		stream.EntriesAdded = int64(entriesAdded)
+	} else {
+		stream.EntriesAdded = int64(length)
+		if len(stream.Entries) > 0 {
+			stream.FirstID = stream.Entries[0].ID
+		}
+	}
+
+	groups, _, err := d.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < groups; i++ {
+		group, err := d.readStreamGroup(typ)
+		if err != nil {
+			return err
+		}
+		stream.Groups = append(stream.Groups, group)
+	}
+
+	d.event.Stream(key, stream, expiry)
+	return nil
+}
+
+func (d *decode) readStreamGroup(typ ValueType) (*StreamGroup, error) {
+	name, err := d.readString()
+	if err != nil {
+		return nil, err
+	}
+	group := &StreamGroup{Name: name, EntriesRead: -1}
+	if group.LastID, err = d.readStreamID(); err != nil {
+		return nil, err
+	}
+	if typ >= TypeStreamListpacks2 {
+		entriesRead, _, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		group.EntriesRead = int64(entriesRead)
+	}
+
+	// Global pending entries list has delivery time and count, owners are
+	// known from pending entries lists of consumers which follow it
+	pendingCount, _, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[StreamID]*StreamPending)
+	for i := uint64(0); i < pendingCount; i++ {
+		id, err := d.readRawStreamID()
+		if err != nil {
+			return nil, err
+		}
+		deliveryTime, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		deliveryCount, _, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		p := &StreamPending{ID: id, DeliveryTime: int64(deliveryTime), DeliveryCount: deliveryCount}
+		group.Pending = append(group.Pending, p)
+		pending[id] = p
+	}
+
+	consumers, _, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < consumers; i++ {
+		consumer := &StreamConsumer{}
+		if consumer.Name, err = d.readString(); err != nil {
+			return nil, err
+		}
+		seenTime, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		consumer.SeenTime, consumer.ActiveTime = int64(seenTime), int64(seenTime)
+		if typ >= TypeStreamListpacks3 {
+			activeTime, err := d.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			consumer.ActiveTime = int64(activeTime)
+		}
+
+		owned, _, err := d.readLength()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint64(0); j < owned; j++ {
+			id, err := d.readRawStreamID()
+			if err != nil {
+				return nil, err
+			}
+			p, ok := pending[id]
+			if !ok {
+				return nil, fmt.Errorf("rdb: pending entry %d-%d of consumer %s is not in group %s", id.Ms, id.Seq, consumer.Name, name)
+			}
+			p.Consumer = consumer.Name
+		}
+		group.Consumers = append(group.Consumers, consumer)
+	}
+	return group, nil
+}
+
+func (d *decode) readStreamID() (StreamID, error) {
+	ms, _, err := d.readLength()
+	if err != nil {
+		return StreamID{}, err
+	}
+	seq, _, err := d.readLength()
+	return StreamID{ms, seq}, err
+}
+
+func (d *decode) readRawStreamID() (StreamID, error) {
+	raw := make([]byte, 16)
+	if _, err := io.ReadFull(d.r, raw); err != nil {
+		return StreamID{}, err
+	}
+	return decodeStreamID(raw), nil
+}
+
+// decodeStreamID decodes the 128 bit big endian form of ID used as keys of
+// radix trees.
+func decodeStreamID(raw []byte) StreamID {
+	return StreamID{binary.BigEndian.Uint64(raw[:8]), binary.BigEndian.Uint64(raw[8:16])}
+}
+
+// appendStreamEntries appends the live entries of a stream node. The node
+// starts with a master entry, whose fields are shared by entries flagged
+// with the same fields, and IDs of entries are saved as differences to the
+// ID of the node:
+//
+//	count | deleted | master field count | master fields... | 0
+//	flags | ms diff | seq diff | [field count] | fields and values... | element count
+func appendStreamEntries(entries []StreamEntry, master StreamID, elements []listpackEntry) ([]StreamEntry, error) {
+	cursor := &listpackCursor{elements: elements}
+	count, err := cursor.nextInt()
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := cursor.nextInt()
+	if err != nil {
+		return nil, err
+	}
+	masterCount, err := cursor.nextInt()
+	if err != nil {
+		return nil, err
+	}
+	masterFields := make([][]byte, masterCount)
+	for i := range masterFields {
+		if masterFields[i], err = cursor.nextBytes(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = cursor.nextInt(); err != nil { // Terminator of master entry
+		return nil, err
+	}
+
+	for i := int64(0); i < count+deleted; i++ {
+		flags, err := cursor.nextInt()
+		if err != nil {
+			return nil, err
+		}
+		msDiff, err := cursor.nextInt()
+		if err != nil {
+			return nil, err
+		}
+		seqDiff, err := cursor.nextInt()
+		if err != nil {
+			return nil, err
+		}
+		entry := StreamEntry{ID: StreamID{master.Ms + uint64(msDiff), master.Seq + uint64(seqDiff)}}
+
+		if flags&streamItemFlagSameFields != 0 {
+			entry.Fields = make([][]byte, 0, 2*len(masterFields))
+			for _, field := range masterFields {
+				value, err := cursor.nextBytes()
+				if err != nil {
+					return nil, err
+				}
+				entry.Fields = append(entry.Fields, field, value)
+			}
+		} else {
+			fieldCount, err := cursor.nextInt()
+			if err != nil {
+				return nil, err
+			}
+			entry.Fields = make([][]byte, 2*fieldCount)
+			for j := range entry.Fields {
+				if entry.Fields[j], err = cursor.nextBytes(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if _, err = cursor.nextInt(); err != nil { // Element count of entry
+			return nil, err
+		}
+
+		if flags&streamItemFlagDeleted == 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// listpackCursor reads elements of a listpack one by one.
+type listpackCursor struct {
+	elements []listpackEntry
+	pos      int
+}
+
+func (c *listpackCursor) next() (listpackEntry, error) {
+	if c.pos >= len(c.elements) {
+		return listpackEntry{}, fmt.Errorf("unexpected end of listpack")
+	}
+	c.pos++
+	return c.elements[c.pos-1], nil
+}
+
+func (c *listpackCursor) nextInt() (int64, error) {
+	entry, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+	return entry.Int()
+}
+
+func (c *listpackCursor) nextBytes() ([]byte, error) {
+	entry, err := c.next()
+	return entry.Bytes(), err
+}
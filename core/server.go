@@ -4,24 +4,38 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"gredissimulate/core/clock"
 	"gredissimulate/core/processor"
 	"gredissimulate/core/proto"
+	"gredissimulate/core/rdb"
+	"gredissimulate/core/store"
 	"gredissimulate/logger"
 	"net"
 	"os"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/MagicYH/rdb"
-	"github.com/MagicYH/rdb/nopdecoder"
 )
 
+// ACTIVE_EXPIRE_INTERVAL : Interval of active expire cycle, keys with expire
+// time are sampled periodically so expired keys never accessed again are
+// deleted too
+const ACTIVE_EXPIRE_INTERVAL = 100 * time.Millisecond
+
+// SYNC_RETRY_INTERVAL : Interval before connecting to master again after
+// sync fails
+const SYNC_RETRY_INTERVAL = time.Second
+
 // ServerConf : Configure of server
 type ServerConf struct {
-	Port    int
-	Passwd  string
-	SlaveOf string
+	Port        int
+	Passwd      string
+	SlaveOf     string
+	Databases   int         // Count of logical databases, store.DEFAULT_DATABASES if not set
+	Clock       clock.Clock // Clock of server, real time is used if nil
+	EnableDebug bool        // Whether DEBUG command is allowed
+	// Classes of keyspace events published, letters of notify-keyspace-events
+	NotifyKeyspaceEvents string
 }
 
 // Server : server
@@ -29,6 +43,7 @@ type Server struct {
 	ctx         context.Context
 	conf        ServerConf
 	listener    net.Listener
+	databases   *store.Databases
 	newProcFunc processor.Create
 	runid       string
 	offset      int
@@ -37,8 +52,16 @@ type Server struct {
 // NewServer : Create new server
 //
 // @param conf ServerConf : Server config, etc: Listen port
-// @param function func() processor.Processor : Function that create a new processor instance
+// @param function processor.Create : Function that create a new processor instance
 func NewServer(conf ServerConf, function processor.Create) (*Server, error) {
+	if nil == conf.Clock {
+		conf.Clock = clock.NewSystemClock()
+	}
+
+	notifyFlags, err := store.ParseNotifyFlags(conf.NotifyKeyspaceEvents)
+	if nil != err {
+		return nil, errors.New("Invalid notify-keyspace-events: " + err.Error())
+	}
 
 	listener, err := net.Listen("tcp", ":"+strconv.Itoa(conf.Port))
 	if nil != err {
@@ -48,18 +71,26 @@ func NewServer(conf ServerConf, function processor.Create) (*Server, error) {
 	server := &Server{
 		conf:        conf,
 		listener:    listener,
+		databases:   store.NewDatabases(conf.Databases, conf.Clock),
 		newProcFunc: function,
 	}
+	server.databases.SetNotifyFlags(notifyFlags)
+	if advancer, ok := conf.Clock.(clock.Advancer); ok {
+		// Time passes only when clock is advanced, expire keys at that moment.
+		// DEBUG ADVANCE-TIME holds the lock already and runs cron by itself
+		advancer.OnAdvance(server.cron)
+	}
 	return server, nil
 }
 
 // Start : Start server
 func (server *Server) Start(ctx context.Context) error {
+	server.ctx = ctx
 	if "" != server.conf.SlaveOf {
 		go server.doSync()
 	}
+	go server.activeExpire()
 
-	server.ctx = ctx
 	for {
 		select {
 		case <-server.ctx.Done():
@@ -76,35 +107,77 @@ func (server *Server) Start(ctx context.Context) error {
 	}
 }
 
+// activeExpire : Run cron periodically until server is stopped, the ticker
+// holds no lock so cron takes the lock of databases itself
+func (server *Server) activeExpire() {
+	ticker := time.NewTicker(ACTIVE_EXPIRE_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.ctx.Done():
+			return
+		case <-ticker.C:
+			server.cron()
+		}
+	}
+}
+
+// cron : Run cron of databases for callers not holding the lock of
+// databases, the ticker and clock advanced through Go API, it waits while a
+// transaction is running
+func (server *Server) cron() {
+	server.databases.RLock()
+	defer server.databases.RUnlock()
+	server.databases.Cron()
+}
+
+// GetClock : Get clock of server
+func (server *Server) GetClock() clock.Clock {
+	return server.conf.Clock
+}
+
+// GetDatabases : Get logical databases of server
+func (server *Server) GetDatabases() *store.Databases {
+	return server.databases
+}
+
 // Close : Close server
 func (server *Server) Close() error {
 	return server.listener.Close()
 }
 
 func (server *Server) handle(conn net.Conn) {
-	ctx, _ := context.WithCancel(server.ctx)
+	ctx, cancel := context.WithCancel(server.ctx)
 	conf := WorkerConf{
 		Passwd:      server.conf.Passwd,
 		NewProcFunc: server.newProcFunc,
 		ReadOnly:    false,
+		Databases:   server.databases,
+		Clock:       server.conf.Clock,
+		EnableDebug: server.conf.EnableDebug,
 	}
 	worker, err := NewWorker(ctx, conn, conf)
 	if nil != err {
 		logger.LogError("Create new worker fail: " + err.Error())
+		cancel()
 		return
 	}
 
-	go worker.DoServe()
+	go func() {
+		defer cancel()
+		worker.DoServe()
+	}()
 }
 
 func (server *Server) doSync() {
-	ctx, _ := context.WithCancel(server.ctx)
+	ctx, cancel := context.WithCancel(server.ctx)
+	defer cancel()
 	for {
 		select {
 		case <-ctx.Done():
-			break
+			return
 		default:
-			func() {
+			err := func() error {
 				conn, err := net.DialTimeout("tcp", server.conf.SlaveOf, 3*time.Second)
 				defer conn.Close()
 				if nil != err {
@@ -118,23 +191,32 @@ func (server *Server) doSync() {
 				_, err = conn.Write([]byte(psyncCmd))
 				if nil != err {
 					logger.LogError("Send full sync message fail", err)
-					return
+					return err
 				}
 				reader := bufio.NewReader(conn)
 				isFull, err := server.getSyncBaseInfo(reader)
 				if nil != err {
 					logger.LogError("Get full sync base info error:", err)
-					return
+					return err
 				}
 
 				if isFull {
 					err = server.fullSync(conn)
 				}
 				if nil != err {
-					return
+					return err
 				}
 				server.continueSync(conn)
+				return nil
 			}()
+			if nil != err {
+				// Sync again after a while instead of flooding master
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(SYNC_RETRY_INTERVAL):
+				}
+			}
 		}
 	}
 
@@ -177,11 +259,14 @@ func (server *Server) fullSync(conn net.Conn) error {
 
 func (server *Server) continueSync(conn net.Conn) {
 	logger.LogInfo("Begin continue sync servce")
-	ctx, _ := context.WithCancel(server.ctx)
+	ctx, cancel := context.WithCancel(server.ctx)
+	defer cancel()
 	conf := WorkerConf{
 		Passwd:      "",
 		NewProcFunc: server.newProcFunc,
 		ReadOnly:    true,
+		Databases:   server.databases,
+		Clock:       server.conf.Clock,
 	}
 	worker, err := NewWorker(ctx, conn, conf)
 	if nil != err {
@@ -280,35 +365,177 @@ func writeToFile(fd *os.File, buffer []byte) error {
 }
 
 type decoder struct {
-	nopdecoder.NopDecoder
-	proc processor.Processor
+	rdb.NopDecoder
+	proc      processor.Processor
+	sess      *processor.Session
+	databases *store.Databases
+	db        int   // Index of database being decoded
+	expiry    int64 // Expire time of aggregate key being decoded, set when it ends
+}
+
+// StartDatabase : Following keys belong to database n
+func (p *decoder) StartDatabase(n int) {
+	p.db = n
+	req := &proto.Request{Cmd: "SELECT", Params: []string{strconv.Itoa(n)}}
+	res, _ := processor.ProcessReq(p.proc, p.sess, req)
+	if proto.RES_TYPE_ERROR == res.Type {
+		logger.LogError("Select database from rdb fail:", res.Data)
+	}
 }
 
 func (p *decoder) Set(key, value []byte, expiry int64) {
 	req := &proto.Request{Cmd: "SET", Params: []string{string(key), string(value)}}
-	_, err := processor.ProcessReq(p.proc, req)
+	if expiry > 0 {
+		req.Params = append(req.Params, "PXAT", strconv.FormatInt(expiry, 10))
+	}
+	_, err := processor.ProcessReq(p.proc, p.sess, req)
 	if nil != err {
 		logger.LogError("Set data from rdb fail:", err)
 	}
 }
 
+func (p *decoder) StartHash(key []byte, length, expiry int64) {
+	p.expiry = expiry
+}
+
 func (p *decoder) Hset(key, field, value []byte) {
 	req := &proto.Request{Cmd: "HSET", Params: []string{string(key), string(field), string(value)}}
-	_, err := processor.ProcessReq(p.proc, req)
+	_, err := processor.ProcessReq(p.proc, p.sess, req)
+	if nil != err {
+		logger.LogError("Set data from rdb fail:", err)
+	}
+}
+
+func (p *decoder) EndHash(key []byte) {
+	p.endAggregate(key)
+}
+
+func (p *decoder) StartList(key []byte, length, expiry int64) {
+	p.expiry = expiry
+}
+
+func (p *decoder) Rpush(key, value []byte) {
+	req := &proto.Request{Cmd: "RPUSH", Params: []string{string(key), string(value)}}
+	_, err := processor.ProcessReq(p.proc, p.sess, req)
 	if nil != err {
 		logger.LogError("Set data from rdb fail:", err)
 	}
 }
 
+func (p *decoder) EndList(key []byte) {
+	p.endAggregate(key)
+}
+
+func (p *decoder) StartSet(key []byte, cardinality, expiry int64) {
+	p.expiry = expiry
+}
+
+func (p *decoder) Sadd(key, member []byte) {
+	req := &proto.Request{Cmd: "SADD", Params: []string{string(key), string(member)}}
+	_, err := processor.ProcessReq(p.proc, p.sess, req)
+	if nil != err {
+		logger.LogError("Set data from rdb fail:", err)
+	}
+}
+
+func (p *decoder) EndSet(key []byte) {
+	p.endAggregate(key)
+}
+
+func (p *decoder) StartZSet(key []byte, cardinality, expiry int64) {
+	p.expiry = expiry
+}
+
+func (p *decoder) Zadd(key []byte, score float64, member []byte) {
+	req := &proto.Request{Cmd: "ZADD", Params: []string{string(key), strconv.FormatFloat(score, 'g', -1, 64), string(member)}}
+	_, err := processor.ProcessReq(p.proc, p.sess, req)
+	if nil != err {
+		logger.LogError("Set data from rdb fail:", err)
+	}
+}
+
+func (p *decoder) EndZSet(key []byte) {
+	p.endAggregate(key)
+}
+
+// Stream : Store stream as it is, commands can not restore its IDs and
+// counters kept after entries are deleted, nor its pending entries
+func (p *decoder) Stream(key []byte, s *rdb.Stream, expiry int64) {
+	stream := store.NewStream()
+	for _, entry := range s.Entries {
+		fields := make([]string, len(entry.Fields))
+		for i, field := range entry.Fields {
+			fields[i] = string(field)
+		}
+		stream.Add(store.StreamID(entry.ID), fields)
+	}
+	stream.Restore(store.StreamID(s.LastID), store.StreamID(s.MaxDeletedID), s.EntriesAdded)
+
+	for _, g := range s.Groups {
+		entriesRead := g.EntriesRead
+		if entriesRead < 0 {
+			entriesRead = stream.EstimateEntriesRead(store.StreamID(g.LastID))
+		}
+		group := stream.CreateGroup(string(g.Name), store.StreamID(g.LastID), entriesRead)
+		if nil == group {
+			logger.LogError("Duplicate consumer group from rdb:", string(key), string(g.Name))
+			continue
+		}
+		for _, c := range g.Consumers {
+			if consumer := group.CreateConsumer(string(c.Name), c.SeenTime); nil != consumer {
+				consumer.ActiveTime = c.ActiveTime
+			}
+		}
+		for _, pending := range g.Pending {
+			consumer := group.Consumer(string(pending.Consumer))
+			if nil == consumer {
+				logger.LogError("Pending entry without consumer from rdb:", string(key), string(g.Name), pending.ID)
+				continue
+			}
+			entry := group.Claim(store.StreamID(pending.ID), consumer)
+			entry.DeliveryTime, entry.DeliveryCount = pending.DeliveryTime, int64(pending.DeliveryCount)
+		}
+	}
+
+	p.databases.RLock()
+	defer p.databases.RUnlock()
+	if !p.databases.IsValid(p.db) {
+		logger.LogError("Load stream from rdb fail, invalid database:", p.db)
+		return
+	}
+	p.databases.Get(p.db).Put(string(key), &store.Object{Type: store.TYPE_STREAM, Value: stream, ExpireAt: expiry}, false)
+}
+
+// endAggregate : Set expire time of aggregate key after all its elements are loaded
+func (p *decoder) endAggregate(key []byte) {
+	if p.expiry <= 0 {
+		return
+	}
+	req := &proto.Request{Cmd: "PEXPIREAT", Params: []string{string(key), strconv.FormatInt(p.expiry, 10)}}
+	p.expiry = 0
+	_, err := processor.ProcessReq(p.proc, p.sess, req)
+	if nil != err {
+		logger.LogError("Set expire time from rdb fail:", err)
+	}
+}
+
 func (server *Server) loadRdbFile(rdbPath string) error {
 	f, err := os.Open(rdbPath)
-	defer f.Close()
 	if nil != err {
 		return err
 	}
-	proc := server.newProcFunc(server.conf.Passwd)
-	err = rdb.Decode(f, &decoder{proc: proc})
-	return nil
+	defer f.Close()
+	proc := server.newProcFunc(processor.ProcConf{
+		Passwd:    server.conf.Passwd,
+		Databases: server.databases,
+		Clock:     server.conf.Clock,
+	})
+	sess := processor.NewSession(server.conf.SlaveOf, "")
+	// Keys loaded by a previous sync may be gone from master
+	server.databases.RLock()
+	server.databases.FlushAll()
+	server.databases.RUnlock()
+	return rdb.Decode(f, &decoder{proc: proc, sess: sess, databases: server.databases})
 }
 
 func (server *Server) getPsyncCmd() string {
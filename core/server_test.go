@@ -0,0 +1,164 @@
+package core
+
+import (
+	"gredissimulate/core/clock"
+	"gredissimulate/core/processor"
+	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// holdProc : Processor whose HOLD command waits until released, so a
+// transaction running it stays in the middle of EXEC
+type holdProc struct {
+	processor.BaseProc
+	entered chan struct{}
+	release chan struct{}
+	keys    int // Count of keys when HOLD is released
+}
+
+func (proc *holdProc) HOLD(sess *processor.Session, req *proto.Request) (*proto.Response, error) {
+	close(proc.entered)
+	<-proc.release
+	proc.keys = proc.GetKeyspace(sess).Len()
+	res := proto.NewResponse(proto.RES_TYPE_STATE)
+	res.SetString("OK")
+	return res, nil
+}
+
+func TestAdvanceClockDuringExec(t *testing.T) {
+	fake := clock.NewFakeClock(time.Unix(1000, 0))
+	server, err := NewServer(ServerConf{Clock: fake}, processor.NewSimpleProc)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	databases := server.GetDatabases()
+	proc := &holdProc{
+		BaseProc: processor.NewBaseProc(processor.ProcConf{Databases: databases, Clock: fake}),
+		entered:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	ks := databases.Get(0)
+	ks.SetString("key", "value")
+	ks.Expire("key", clock.UnixMs(fake)+100)
+
+	sess := processor.NewSession("127.0.0.1:6379", "")
+	processor.ProcessReq(proc, sess, &proto.Request{Cmd: "MULTI"})
+	processor.ProcessReq(proc, sess, &proto.Request{Cmd: "HOLD"})
+	executed := make(chan *proto.Response)
+	go func() {
+		res, _ := processor.ProcessReq(proc, sess, &proto.Request{Cmd: "EXEC"})
+		executed <- res
+	}()
+	<-proc.entered
+
+	advanced := make(chan struct{})
+	go func() {
+		fake.Advance(200 * time.Millisecond)
+		close(advanced)
+	}()
+	select {
+	case <-advanced:
+		t.Fatal("clock advanced through Go API did not wait for EXEC")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(proc.release)
+	if res := <-executed; proto.RES_TYPE_MULTI != res.Type {
+		t.Fatalf("EXEC: got %s%s", res.Type, res.Data)
+	}
+	if 1 != proc.keys {
+		t.Fatalf("key expired in the middle of EXEC, %d keys left", proc.keys)
+	}
+	select {
+	case <-advanced:
+	case <-time.After(time.Second):
+		t.Fatal("clock is not advanced after EXEC")
+	}
+	if 0 != ks.Len() {
+		t.Fatalf("expired key is not deleted after EXEC, %d keys left", ks.Len())
+	}
+}
+
+// listpack : Listpack of small integers and short strings, encoded as Redis
+// saves them
+func listpack(elements ...interface{}) string {
+	body := ""
+	for _, element := range elements {
+		switch v := element.(type) {
+		case int:
+			body += string([]byte{byte(v), 1})
+		case string:
+			body += string([]byte{0x80 | byte(len(v))}) + v + string([]byte{byte(1 + len(v))})
+		}
+	}
+	total := 6 + len(body) + 1
+	return string([]byte{byte(total), 0, 0, 0, byte(len(elements)), 0}) + body + "\xff"
+}
+
+func TestLoadRdbWithStream(t *testing.T) {
+	server, err := NewServer(ServerConf{}, processor.NewSimpleProc)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	ks := server.GetDatabases().Get(0)
+	ks.SetString("old", "value")
+
+	// Stream node of entries 1-1 {f: v} and 1-2 {f: w}, which have the
+	// fields of master entry 1-1
+	node := listpack(2, 0, 1, "f", 0, 2, 0, 0, "v", 4, 2, 0, 1, "w", 4)
+	id := "\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x01"
+	rdb := "REDIS0009\xfe\x00" +
+		"\x00\x01a\x011" + // String a
+		"\x0f\x01s\x01\x10" + id + string([]byte{byte(len(node))}) + node + // Stream s with one node
+		"\x02\x01\x02" + // Length and last ID
+		"\x01\x01g\x01\x01" + // Group g with last ID 1-1
+		"\x01" + id + "\xe8\x03\x00\x00\x00\x00\x00\x00\x02" + // 1-1 delivered twice at 1000
+		"\x01\x01c\xdc\x05\x00\x00\x00\x00\x00\x00\x01" + id + // Consumer c seen at 1500 owns 1-1
+		"\xff\x00\x00\x00\x00\x00\x00\x00\x00"
+
+	f, err := ioutil.TempFile("", "stream*.rdb")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(rdb)
+	f.Close()
+
+	if err = server.loadRdbFile(f.Name()); nil != err {
+		t.Fatalf("load rdb with stream: %v", err)
+	}
+	if 2 != ks.Len() {
+		t.Fatalf("got %d keys after load, want 2", ks.Len())
+	}
+	err = ks.View("s", store.TYPE_STREAM, func(value interface{}) error {
+		stream := value.(*store.Stream)
+		if 2 != stream.Len() || (store.StreamID{Ms: 1, Seq: 2}) != stream.LastID() {
+			t.Fatalf("got stream of %d entries to %s", stream.Len(), stream.LastID())
+		}
+		if entry, _ := stream.Get(store.StreamID{Ms: 1, Seq: 2}); "f" != entry.Fields[0] || "w" != entry.Fields[1] {
+			t.Fatalf("got fields %v of entry 1-2", entry.Fields)
+		}
+		group := stream.Group("g")
+		if nil == group || 1 != group.EntriesRead {
+			t.Fatalf("got group %+v", group)
+		}
+		pending := group.Pending(store.StreamID{Ms: 1, Seq: 1})
+		if nil == pending || "c" != pending.Consumer.Name || 2 != pending.DeliveryCount || 1000 != pending.DeliveryTime {
+			t.Fatalf("got pending entry %+v", pending)
+		}
+		if 1500 != pending.Consumer.SeenTime || 1 != pending.Consumer.PendingLen() {
+			t.Fatalf("got consumer %+v", pending.Consumer)
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// TryFunc : Try to serve a blocked client with key that may be ready, return
+// false if there is still nothing to serve, keys pushed to while serving are
+// returned so clients blocked on them are served too
+type TryFunc func(key string) (served bool, touched []string)
+
+// Waiter : A client blocked on keys of a database
+type Waiter struct {
+	db       int
+	keys     []string
+	deadline int64 // Unix time in milliseconds, 0 if it waits forever
+	try      TryFunc
+	done     chan struct{}
+	served   bool
+}
+
+// Done : Closed when waiter is served or its timeout passes
+func (w *Waiter) Done() <-chan struct{} {
+	return w.done
+}
+
+// Served : Whether waiter is served, valid after Done is closed
+func (w *Waiter) Served() bool {
+	return w.served
+}
+
+type blockKey struct {
+	db  int
+	key string
+}
+
+// Blocking : Clients blocked on keys by commands like BLPOP, clients blocked
+// on the same key are served in the order they were blocked
+type Blocking struct {
+	mutex   sync.Mutex
+	waiters map[blockKey][]*Waiter
+	count   int32
+}
+
+// NewBlocking : Create an empty registry of blocked clients
+func NewBlocking() *Blocking {
+	return &Blocking{waiters: make(map[blockKey][]*Waiter)}
+}
+
+// Try : Try keys in order without blocking, return false if none is ready
+func (b *Blocking) Try(db int, keys []string, try TryFunc) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.tryKeys(db, keys, try)
+}
+
+// Block : Try keys in order and block client on them if none is ready, the
+// returned waiter is done already if client is served at once
+//
+// @param deadline int64 : Unix time in milliseconds of timeout, 0 if client waits forever
+func (b *Blocking) Block(db int, keys []string, deadline int64, try TryFunc) *Waiter {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Count is increased before trying, so Signal of a push that is missed
+	// by the try does not skip the lock and serves waiter after it is added
+	atomic.AddInt32(&b.count, 1)
+	w := &Waiter{db: db, keys: keys, deadline: deadline, try: try, done: make(chan struct{})}
+	if b.tryKeys(db, keys, try) {
+		atomic.AddInt32(&b.count, -1)
+		w.served = true
+		close(w.done)
+		return w
+	}
+	for _, key := range keys {
+		bk := blockKey{db, key}
+		b.waiters[bk] = append(b.waiters[bk], w)
+	}
+	return w
+}
+
+// Signal : Key of database may be ready, serve clients blocked on it
+func (b *Blocking) Signal(db int, key string) {
+	if 0 == atomic.LoadInt32(&b.count) {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.serve([]blockKey{{db, key}})
+}
+
+// Cancel : Unblock waiter without serving it, false if it is done already
+func (b *Blocking) Cancel(w *Waiter) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.remove(w)
+}
+
+// Timeout : Unblock waiters whose deadline has passed
+func (b *Blocking) Timeout(now int64) {
+	if 0 == atomic.LoadInt32(&b.count) {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	expired := []*Waiter{}
+	for _, waiters := range b.waiters {
+		for _, w := range waiters {
+			if (0 != w.deadline) && (w.deadline <= now) {
+				expired = append(expired, w)
+			}
+		}
+	}
+	for _, w := range expired {
+		b.remove(w)
+	}
+}
+
+// tryKeys : Try keys in order, must be called under lock
+func (b *Blocking) tryKeys(db int, keys []string, try TryFunc) bool {
+	for _, key := range keys {
+		served, touched := try(key)
+		if served {
+			b.serve(toBlockKeys(db, touched))
+			return true
+		}
+	}
+	return false
+}
+
+// serve : Serve clients blocked on ready keys in order they were blocked,
+// must be called under lock
+func (b *Blocking) serve(ready []blockKey) {
+	for len(ready) > 0 {
+		bk := ready[0]
+		ready = ready[1:]
+		for len(b.waiters[bk]) > 0 {
+			w := b.waiters[bk][0]
+			served, touched := w.try(bk.key)
+			if !served {
+				break
+			}
+			w.served = true
+			b.remove(w)
+			ready = append(ready, toBlockKeys(bk.db, touched)...)
+		}
+	}
+}
+
+// remove : Remove waiter from all its keys and close it, must be called
+// under lock
+func (b *Blocking) remove(w *Waiter) bool {
+	select {
+	case <-w.done:
+		return false
+	default:
+	}
+	for _, key := range w.keys {
+		bk := blockKey{w.db, key}
+		waiters := b.waiters[bk]
+		for i, item := range waiters {
+			if item == w {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if 0 == len(waiters) {
+			delete(b.waiters, bk)
+		} else {
+			b.waiters[bk] = waiters
+		}
+	}
+	atomic.AddInt32(&b.count, -1)
+	close(w.done)
+	return true
+}
+
+func toBlockKeys(db int, keys []string) []blockKey {
+	bks := make([]blockKey, 0, len(keys))
+	for _, key := range keys {
+		bks = append(bks, blockKey{db, key})
+	}
+	return bks
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"gredissimulate/core/clock"
+	"sync"
+	"sync/atomic"
+)
+
+// DEFAULT_DATABASES : Default count of logical databases
+const DEFAULT_DATABASES = 16
+
+// ACTIVE_EXPIRE_SAMPLES : Keys with expire time sampled in each database by
+// a run of Cron
+const ACTIVE_EXPIRE_SAMPLES = 20
+
+// Databases : Logical databases of server, each one is a separate keyspace
+type Databases struct {
+	mutex    sync.RWMutex
+	exec     sync.RWMutex // Held exclusively by EXEC and shared by other commands
+	dbs      []Keyspace
+	blocking *Blocking
+	broker   *Broker
+	notify   int32 // Classes of keyspace events published, accessed atomically
+	clock    clock.Clock
+}
+
+// NewDatabases : Create count empty databases using time of clk
+func NewDatabases(count int, clk clock.Clock) *Databases {
+	if count <= 0 {
+		count = DEFAULT_DATABASES
+	}
+	databases := &Databases{dbs: make([]Keyspace, count), blocking: NewBlocking(), broker: NewBroker(), clock: clk}
+	for i := range databases.dbs {
+		ks := NewKeyspace(clk)
+		ks.OnExpired(func(key string) {
+			databases.notifyKeyspace(ks, NOTIFY_EXPIRED, "expired", key)
+		})
+		ks.OnAdded(func(key string) {
+			databases.notifyKeyspace(ks, NOTIFY_NEW, "new", key)
+		})
+		ks.OnMissed(func(key string) {
+			databases.notifyKeyspace(ks, NOTIFY_KEY_MISS, "keymiss", key)
+		})
+		databases.dbs[i] = ks
+	}
+	return databases
+}
+
+// Count : Count of databases
+func (databases *Databases) Count() int {
+	return len(databases.dbs)
+}
+
+// GetBlocking : Get registry of clients blocked on keys of databases
+func (databases *Databases) GetBlocking() *Blocking {
+	return databases.blocking
+}
+
+// Lock : Lock databases exclusively to run a transaction atomically,
+// commands of other connections wait until Unlock
+func (databases *Databases) Lock() {
+	databases.exec.Lock()
+}
+
+// Unlock : Unlock databases locked by Lock
+func (databases *Databases) Unlock() {
+	databases.exec.Unlock()
+}
+
+// RLock : Lock databases shared to run a single command, it waits while a
+// transaction is running, must not be called again before RUnlock
+func (databases *Databases) RLock() {
+	databases.exec.RLock()
+}
+
+// RUnlock : Unlock databases locked by RLock
+func (databases *Databases) RUnlock() {
+	databases.exec.RUnlock()
+}
+
+// GetBroker : Get pub/sub broker of server
+func (databases *Databases) GetBroker() *Broker {
+	return databases.broker
+}
+
+// SetNotifyFlags : Set classes of keyspace events published, combination
+// of NOTIFY_*, 0 disables notifications
+func (databases *Databases) SetNotifyFlags(flags int) {
+	atomic.StoreInt32(&databases.notify, int32(flags))
+}
+
+// GetNotifyFlags : Get classes of keyspace events published
+func (databases *Databases) GetNotifyFlags() int {
+	return int(atomic.LoadInt32(&databases.notify))
+}
+
+// Notify : Publish event of class on key of database index, to keyspace
+// channel of key if K is enabled and to keyevent channel of event if E is
+// enabled, nothing is published unless class is enabled
+func (databases *Databases) Notify(class int, event string, index int, key string) {
+	flags := databases.GetNotifyFlags()
+	if 0 == (flags & class) {
+		return
+	}
+	if 0 != (flags & NOTIFY_KEYSPACE) {
+		databases.broker.Publish(keyspaceChannel(index, key), event)
+	}
+	if 0 != (flags & NOTIFY_KEYEVENT) {
+		databases.broker.Publish(keyeventChannel(index, event), key)
+	}
+}
+
+// notifyKeyspace : Publish event of class on key notified by keyspace, such
+// as expired, index of keyspace is looked up at the moment since it changes
+// by SWAPDB
+func (databases *Databases) notifyKeyspace(ks Keyspace, class int, event string, key string) {
+	if 0 == (databases.GetNotifyFlags() & class) {
+		return
+	}
+	index := -1
+	databases.mutex.RLock()
+	for i, db := range databases.dbs {
+		if db == ks {
+			index = i
+			break
+		}
+	}
+	databases.mutex.RUnlock()
+	if index >= 0 {
+		databases.Notify(class, event, index, key)
+	}
+}
+
+// IsValid : Whether index is in range of databases
+func (databases *Databases) IsValid(index int) bool {
+	return (index >= 0) && (index < len(databases.dbs))
+}
+
+// Get : Get keyspace of database index, index must be valid
+func (databases *Databases) Get(index int) Keyspace {
+	databases.mutex.RLock()
+	defer databases.mutex.RUnlock()
+	return databases.dbs[index]
+}
+
+// Swap : Swap keyspaces of two databases, connections selecting one of them
+// see the data of the other one immediately
+func (databases *Databases) Swap(index1 int, index2 int) {
+	databases.mutex.Lock()
+	defer databases.mutex.Unlock()
+	databases.dbs[index1], databases.dbs[index2] = databases.dbs[index2], databases.dbs[index1]
+}
+
+// FlushAll : Delete keys of all databases
+func (databases *Databases) FlushAll() {
+	databases.mutex.RLock()
+	defer databases.mutex.RUnlock()
+	for _, db := range databases.dbs {
+		db.Flush()
+	}
+}
+
+// Cron : Periodical jobs driven by time of clock, expired keys are deleted
+// and blocked clients whose timeout has passed are unblocked. Caller must
+// hold the lock of databases, by RLock or by Lock of the running EXEC, so
+// keys never expire in the middle of a transaction
+func (databases *Databases) Cron() {
+	databases.ActiveExpire(ACTIVE_EXPIRE_SAMPLES)
+	databases.blocking.Timeout(clock.UnixMs(databases.clock))
+}
+
+// ActiveExpire : Delete expired keys of all databases by sampling, return
+// count of keys deleted
+func (databases *Databases) ActiveExpire(samples int) int {
+	// Keyspaces are expired out of lock, since expired events look up
+	// index of keyspace under it
+	databases.mutex.RLock()
+	dbs := append([]Keyspace{}, databases.dbs...)
+	databases.mutex.RUnlock()
+	count := 0
+	for _, db := range dbs {
+		count = count + db.ActiveExpire(samples)
+	}
+	return count
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"math/rand"
+)
+
+// Load factor of dict, buckets are doubled when average size of buckets
+// exceeds DICT_GROW_LOAD and halved when it falls below DICT_SHRINK_LOAD
+const (
+	DICT_INIT_SIZE   = 4
+	DICT_GROW_LOAD   = 4
+	DICT_SHRINK_LOAD = 0.125
+)
+
+// Dict : Hash table that can be iterated by cursor like dict of redis, keys
+// are spread to 2^n buckets by hash and the cursor counts buckets in reverse
+// binary order, so keys present during the whole iteration are returned at
+// least once even if the table is resized between calls of Scan
+type Dict struct {
+	items   map[string]*dictEntry
+	buckets [][]*dictEntry
+}
+
+type dictEntry struct {
+	key   string
+	value interface{}
+	hash  uint64
+	index int // Position in bucket
+}
+
+// NewDict : Create an empty dict
+func NewDict() *Dict {
+	return &Dict{
+		items:   make(map[string]*dictEntry),
+		buckets: make([][]*dictEntry, DICT_INIT_SIZE),
+	}
+}
+
+// Len : Count of keys
+func (d *Dict) Len() int {
+	return len(d.items)
+}
+
+// Get : Get value of key
+func (d *Dict) Get(key string) (interface{}, bool) {
+	entry, ok := d.items[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set : Set value of key, return true if key is created
+func (d *Dict) Set(key string, value interface{}) bool {
+	if entry, ok := d.items[key]; ok {
+		entry.value = value
+		return false
+	}
+
+	entry := &dictEntry{key: key, value: value, hash: hashKey(key)}
+	d.items[key] = entry
+	d.addToBucket(entry)
+	if len(d.items) > len(d.buckets)*DICT_GROW_LOAD {
+		d.resize(len(d.buckets) * 2)
+	}
+	return true
+}
+
+// Delete : Delete key, return true if key exists
+func (d *Dict) Delete(key string) bool {
+	entry, ok := d.items[key]
+	if !ok {
+		return false
+	}
+	delete(d.items, key)
+
+	mask := uint64(len(d.buckets) - 1)
+	bucket := d.buckets[entry.hash&mask]
+	last := bucket[len(bucket)-1]
+	bucket[entry.index] = last
+	last.index = entry.index
+	bucket[len(bucket)-1] = nil
+	d.buckets[entry.hash&mask] = bucket[:len(bucket)-1]
+
+	if (len(d.buckets) > DICT_INIT_SIZE) && (float64(len(d.items)) < float64(len(d.buckets))*DICT_SHRINK_LOAD) {
+		d.resize(len(d.buckets) / 2)
+	}
+	return true
+}
+
+// Copy : Copy of dict, values are copied shallowly
+func (d *Dict) Copy() *Dict {
+	dict := NewDict()
+	for key, entry := range d.items {
+		dict.Set(key, entry.value)
+	}
+	return dict
+}
+
+// Range : Call function for each key until it returns false, dict must not
+// be modified by function
+func (d *Dict) Range(function func(key string, value interface{}) bool) {
+	for key, entry := range d.items {
+		if !function(key, entry.value) {
+			return
+		}
+	}
+}
+
+// Scan : Call function for keys of the bucket pointed by cursor, return the
+// cursor of next bucket, 0 if iteration is finished
+func (d *Dict) Scan(cursor uint64, function func(key string, value interface{})) uint64 {
+	mask := uint64(len(d.buckets) - 1)
+	for _, entry := range d.buckets[cursor&mask] {
+		function(entry.key, entry.value)
+	}
+
+	// Increase the reversed cursor, high bits are set so the carry goes
+	// through bits not used by mask
+	cursor = cursor | ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// RandomKey : Get a random key, false if dict is empty
+func (d *Dict) RandomKey() (string, interface{}, bool) {
+	if 0 == len(d.items) {
+		return "", nil, false
+	}
+	for {
+		bucket := d.buckets[rand.Intn(len(d.buckets))]
+		if len(bucket) > 0 {
+			entry := bucket[rand.Intn(len(bucket))]
+			return entry.key, entry.value, true
+		}
+	}
+}
+
+func (d *Dict) addToBucket(entry *dictEntry) {
+	index := entry.hash & uint64(len(d.buckets)-1)
+	entry.index = len(d.buckets[index])
+	d.buckets[index] = append(d.buckets[index], entry)
+}
+
+// resize : Rebuild buckets with new size, size must be power of 2
+func (d *Dict) resize(size int) {
+	old := d.buckets
+	d.buckets = make([][]*dictEntry, size)
+	for _, bucket := range old {
+		for _, entry := range bucket {
+			d.addToBucket(entry)
+		}
+	}
+}
+
+func hashKey(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
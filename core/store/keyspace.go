@@ -0,0 +1,103 @@
+package store
+
+import "errors"
+
+// Types of value, replied by TYPE command
+const (
+	TYPE_NONE   = "none"
+	TYPE_STRING = "string"
+	TYPE_HASH   = "hash"
+	TYPE_LIST   = "list"
+	TYPE_SET    = "set"
+	TYPE_ZSET   = "zset"
+	TYPE_STREAM = "stream"
+)
+
+// ErrWrongType : Key holds a value of other type
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// Object : Value stored in keyspace
+type Object struct {
+	Type     string      // One of TYPE_*
+	Value    interface{} // Value, its go type depends on Type
+	ExpireAt int64       // Unix time in milliseconds, 0 if key never expires
+
+	accessedAt int64 // Unix time in milliseconds of the last access
+	counter    int32 // LFU counter of access frequency
+}
+
+// Keyspace : Storage of keys shared by all connections, implementation must
+// be safe for concurrent use
+type Keyspace interface {
+	// Type : Get type of key, TYPE_NONE if key does not exist
+	Type(key string) string
+	// Exists : Whether key exists
+	Exists(key string) bool
+	// Delete : Delete keys, return count of keys deleted
+	Delete(keys ...string) int
+	// Len : Count of keys
+	Len() int
+	// Flush : Delete all keys
+	Flush()
+	// Range : Call function for each key until it returns false
+	Range(function func(key string, t string) bool)
+	// Scan : Call function for keys from cursor until about count keys
+	// are found, return cursor of next call, 0 if iteration is finished.
+	// Keys present during the whole iteration are found at least once
+	Scan(cursor uint64, count int64, function func(key string, t string)) uint64
+	// Watch : Start tracking version of key for WATCH, return its current
+	// version, which changes whenever key is modified, deleted or expired
+	Watch(key string) uint64
+	// Unwatch : Stop tracking version of key, called once for each Watch
+	Unwatch(key string)
+	// Version : Current version of key being watched
+	Version(key string) uint64
+	// RandomKey : Get a random key, false if keyspace is empty
+	RandomKey() (string, bool)
+	// Touch : Update access time of key, false if key does not exist
+	Touch(key string) bool
+	// Peek : Call function with object of key under read lock without
+	// updating its access time, obj is nil if key does not exist
+	Peek(key string, function func(obj *Object))
+
+	// GetString : Get value of string key, ErrWrongType if key holds other type
+	GetString(key string) (value string, ok bool, err error)
+	// SetString : Set value of string key, override value of any type and clear expiry
+	SetString(key string, value string)
+
+	// View : Call function with value of key under read lock, value is nil
+	// if key does not exist, ErrWrongType if key holds a type other than t
+	View(key string, t string, function func(value interface{}) error) error
+	// Update : Call function with value of key under write lock, value is
+	// nil if key does not exist, the returned value is stored as type t and
+	// the key is deleted if it returns nil, ErrWrongType if key holds a type
+	// other than t
+	Update(key string, t string, function func(value interface{}) (interface{}, error)) error
+
+	// Expire : Set expire time of key in unix milliseconds, false if key does not exist
+	Expire(key string, at int64) bool
+	// GetExpire : Get expire time of key in unix milliseconds, 0 if key never
+	// expires, false if key does not exist
+	GetExpire(key string) (int64, bool)
+	// Persist : Remove expire time of key, false if key does not exist or has no expiry
+	Persist(key string) bool
+
+	// Take : Remove key and return its object, nil if key does not exist
+	Take(key string) *Object
+	// Put : Store object to key, false if nx is true and key exists
+	Put(key string, obj *Object, nx bool) bool
+	// Mutate : Call function with object of key under write lock, obj is nil
+	// if key does not exist, the returned object replaces it and the key is
+	// deleted if it returns nil or an object already expired
+	Mutate(key string, function func(obj *Object) (*Object, error)) error
+	// MutateMulti : Same as Mutate but for several keys atomically, objs are
+	// in order of keys and the returned objects replace them in the same order
+	MutateMulti(keys []string, function func(objs []*Object) ([]*Object, error)) error
+	// ViewMulti : Call function with objects of keys under read locks
+	// atomically, objs are in order of keys and nil if key does not exist
+	ViewMulti(keys []string, function func(objs []*Object) error) error
+
+	// ActiveExpire : Delete expired keys by sampling keys with expire time,
+	// return count of keys deleted
+	ActiveExpire(samples int) int
+}
@@ -0,0 +1,120 @@
+package store
+
+// LIST_INIT_CAPACITY : Initial capacity of ring buffer of list
+const LIST_INIT_CAPACITY = 8
+
+// List : Double-ended queue of strings on a ring buffer, both ends are
+// pushed and popped in O(1) and elements are accessed by index in O(1)
+type List struct {
+	items []string
+	head  int
+	size  int
+}
+
+// NewList : Create an empty list
+func NewList() *List {
+	return &List{items: make([]string, LIST_INIT_CAPACITY)}
+}
+
+// NewListFrom : Create list of values
+func NewListFrom(values []string) *List {
+	list := NewList()
+	list.Reset(values)
+	return list
+}
+
+// Len : Count of elements
+func (list *List) Len() int {
+	return list.size
+}
+
+// PushFront : Insert value at head
+func (list *List) PushFront(value string) {
+	list.grow()
+	list.head = (list.head - 1 + len(list.items)) % len(list.items)
+	list.items[list.head] = value
+	list.size++
+}
+
+// PushBack : Insert value at tail
+func (list *List) PushBack(value string) {
+	list.grow()
+	list.items[(list.head+list.size)%len(list.items)] = value
+	list.size++
+}
+
+// PopFront : Remove and return value at head, false if list is empty
+func (list *List) PopFront() (string, bool) {
+	if 0 == list.size {
+		return "", false
+	}
+	value := list.items[list.head]
+	list.items[list.head] = ""
+	list.head = (list.head + 1) % len(list.items)
+	list.size--
+	return value, true
+}
+
+// PopBack : Remove and return value at tail, false if list is empty
+func (list *List) PopBack() (string, bool) {
+	if 0 == list.size {
+		return "", false
+	}
+	index := (list.head + list.size - 1) % len(list.items)
+	value := list.items[index]
+	list.items[index] = ""
+	list.size--
+	return value, true
+}
+
+// Index : Get value at index, index must be in range [0, Len())
+func (list *List) Index(index int) string {
+	return list.items[(list.head+index)%len(list.items)]
+}
+
+// Set : Set value at index, index must be in range [0, Len())
+func (list *List) Set(index int, value string) {
+	list.items[(list.head+index)%len(list.items)] = value
+}
+
+// Values : Copy of all values from head to tail
+func (list *List) Values() []string {
+	return list.Range(0, list.size-1)
+}
+
+// Range : Copy of values from start to stop inclusive, indexes must be in range
+func (list *List) Range(start int, stop int) []string {
+	if start > stop {
+		return []string{}
+	}
+	values := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		values = append(values, list.Index(i))
+	}
+	return values
+}
+
+// Reset : Replace all values of list
+func (list *List) Reset(values []string) {
+	capacity := LIST_INIT_CAPACITY
+	for capacity < len(values) {
+		capacity = capacity * 2
+	}
+	list.items = make([]string, capacity)
+	copy(list.items, values)
+	list.head = 0
+	list.size = len(values)
+}
+
+// grow : Double capacity if ring buffer is full
+func (list *List) grow() {
+	if list.size < len(list.items) {
+		return
+	}
+	items := make([]string, len(list.items)*2)
+	for i := 0; i < list.size; i++ {
+		items[i] = list.Index(i)
+	}
+	list.items = items
+	list.head = 0
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Classes of keyspace events, enabled by letters of notify-keyspace-events
+const (
+	NOTIFY_KEYSPACE = 1 << iota // K, published to __keyspace@<db>__:<key>
+	NOTIFY_KEYEVENT             // E, published to __keyevent@<db>__:<event>
+	NOTIFY_GENERIC              // g, commands not specific to a type, such as DEL and EXPIRE
+	NOTIFY_STRING               // $
+	NOTIFY_LIST                 // l
+	NOTIFY_SET                  // s
+	NOTIFY_HASH                 // h
+	NOTIFY_ZSET                 // z
+	NOTIFY_EXPIRED              // x, key is deleted because it is expired
+	NOTIFY_EVICTED              // e, key is deleted by maxmemory policy, never published as keys are never evicted
+	NOTIFY_STREAM               // t
+	NOTIFY_KEY_MISS             // m, read of a key which does not exist
+	NOTIFY_MODULE               // d
+	NOTIFY_NEW                  // n, key is added to database
+)
+
+// NOTIFY_ALL : Classes enabled by letter A, excluding m and n
+const NOTIFY_ALL = NOTIFY_GENERIC | NOTIFY_STRING | NOTIFY_LIST | NOTIFY_SET | NOTIFY_HASH |
+	NOTIFY_ZSET | NOTIFY_EXPIRED | NOTIFY_EVICTED | NOTIFY_STREAM | NOTIFY_MODULE
+
+// ErrNotifyFlags : Letter of notify-keyspace-events is unknown
+var ErrNotifyFlags = errors.New("Invalid event class character. Use 'Ag$lshzxeKEtmdn'.")
+
+// notifyLetters : Letters of classes in order of FormatNotifyFlags
+var notifyLetters = []struct {
+	letter byte
+	flag   int
+}{
+	{'g', NOTIFY_GENERIC}, {'$', NOTIFY_STRING}, {'l', NOTIFY_LIST}, {'s', NOTIFY_SET},
+	{'h', NOTIFY_HASH}, {'z', NOTIFY_ZSET}, {'x', NOTIFY_EXPIRED}, {'e', NOTIFY_EVICTED},
+	{'t', NOTIFY_STREAM}, {'d', NOTIFY_MODULE},
+	{'K', NOTIFY_KEYSPACE}, {'E', NOTIFY_KEYEVENT}, {'m', NOTIFY_KEY_MISS}, {'n', NOTIFY_NEW},
+}
+
+// ParseNotifyFlags : Classes of notify-keyspace-events string, empty string
+// disables notifications
+func ParseNotifyFlags(s string) (int, error) {
+	flags := 0
+	for i := 0; i < len(s); i++ {
+		if 'A' == s[i] {
+			flags = flags | NOTIFY_ALL
+			continue
+		}
+		found := false
+		for _, l := range notifyLetters {
+			if l.letter == s[i] {
+				flags = flags | l.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, ErrNotifyFlags
+		}
+	}
+	return flags, nil
+}
+
+// FormatNotifyFlags : notify-keyspace-events string of classes, A replaces
+// letters of all classes it contains
+func FormatNotifyFlags(flags int) string {
+	s := []byte{}
+	if NOTIFY_ALL == (flags & NOTIFY_ALL) {
+		s = append(s, 'A')
+		flags = flags &^ NOTIFY_ALL
+	}
+	for _, l := range notifyLetters {
+		if 0 != (flags & l.flag) {
+			s = append(s, l.letter)
+		}
+	}
+	return string(s)
+}
+
+// keyspaceChannel : Channel of events of key in database db
+func keyspaceChannel(db int, key string) string {
+	return "__keyspace@" + strconv.Itoa(db) + "__:" + key
+}
+
+// keyeventChannel : Channel of keys of event in database db
+func keyeventChannel(db int, event string) string {
+	return "__keyevent@" + strconv.Itoa(db) + "__:" + event
+}
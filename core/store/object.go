@@ -0,0 +1,70 @@
+package store
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// LFU parameters of access frequency, same as defaults of redis
+const (
+	LFU_INIT_VAL   = 5
+	LFU_LOG_FACTOR = 10
+	LFU_DECAY_TIME = 60 * 1000 // Milliseconds for counter to decay by 1
+)
+
+// IdleTime : Milliseconds since object was accessed last time
+func (obj *Object) IdleTime(now int64) int64 {
+	idle := now - atomic.LoadInt64(&obj.accessedAt)
+	if idle < 0 {
+		return 0
+	}
+	return idle
+}
+
+// Freq : Logarithmic access frequency counter of object, it decays as time
+// passes without access like LFU of redis
+func (obj *Object) Freq(now int64) int {
+	counter := int64(atomic.LoadInt32(&obj.counter))
+	if decay := obj.IdleTime(now) / LFU_DECAY_TIME; decay < counter {
+		return int(counter - decay)
+	}
+	return 0
+}
+
+// touch : Update access time and frequency, may be called under read lock
+func (obj *Object) touch(now int64) {
+	if 0 == atomic.LoadInt64(&obj.accessedAt) {
+		atomic.StoreInt32(&obj.counter, LFU_INIT_VAL)
+		atomic.StoreInt64(&obj.accessedAt, now)
+		return
+	}
+
+	counter := obj.Freq(now)
+	if counter < 255 {
+		base := counter - LFU_INIT_VAL
+		if base < 0 {
+			base = 0
+		}
+		if rand.Float64() < 1.0/float64(base*LFU_LOG_FACTOR+1) {
+			counter++
+		}
+	}
+	atomic.StoreInt32(&obj.counter, int32(counter))
+	atomic.StoreInt64(&obj.accessedAt, now)
+}
+
+// Copy : Deep copy of object, expire time is kept and access is reset
+func (obj *Object) Copy() *Object {
+	value := obj.Value
+	switch v := obj.Value.(type) {
+	case *Dict:
+		value = v.Copy()
+	case *List:
+		value = NewListFrom(v.Values())
+	case *ZSet:
+		value = v.Copy()
+	case *Stream:
+		value = v.Copy()
+	}
+	return &Object{Type: obj.Type, Value: value, ExpireAt: obj.ExpireAt}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"gredissimulate/helper"
+	"sort"
+	"sync"
+)
+
+// Kinds of subscription
+const (
+	SUB_CHANNEL = iota // Channel subscribed by SUBSCRIBE
+	SUB_PATTERN        // Glob-style pattern of channels subscribed by PSUBSCRIBE
+	SUB_SHARD          // Shard channel subscribed by SSUBSCRIBE
+	SUB_KINDS
+)
+
+// Subscriber : Connection receiving messages of its subscriptions, Deliver
+// is called by goroutine of publisher out of lock of broker
+type Subscriber interface {
+	// Deliver : Message published to channel, pattern is the subscribed one
+	// matching channel for SUB_PATTERN and empty for other kinds
+	Deliver(kind int, pattern string, channel string, message string)
+}
+
+// Broker : Subscriptions of all connections of server, messages published
+// to a channel are delivered to subscribers of it and of patterns matching it
+type Broker struct {
+	mutex sync.RWMutex
+	subs  [SUB_KINDS]map[string]map[Subscriber]bool
+}
+
+// NewBroker : Create a broker without subscriptions
+func NewBroker() *Broker {
+	b := &Broker{}
+	for i := range b.subs {
+		b.subs[i] = make(map[string]map[Subscriber]bool)
+	}
+	return b
+}
+
+// Subscribe : Subscribe name of kind, false if it is subscribed already
+func (b *Broker) Subscribe(kind int, sub Subscriber, name string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subs, ok := b.subs[kind][name]
+	if !ok {
+		subs = make(map[Subscriber]bool)
+		b.subs[kind][name] = subs
+	}
+	if subs[sub] {
+		return false
+	}
+	subs[sub] = true
+	return true
+}
+
+// Unsubscribe : Unsubscribe name of kind, false if it is not subscribed
+func (b *Broker) Unsubscribe(kind int, sub Subscriber, name string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subs := b.subs[kind][name]
+	if !subs[sub] {
+		return false
+	}
+	delete(subs, sub)
+	if 0 == len(subs) {
+		delete(b.subs[kind], name)
+	}
+	return true
+}
+
+// Publish : Deliver message to subscribers of channel and patterns matching
+// it, return count of deliveries
+func (b *Broker) Publish(channel string, message string) int {
+	type delivery struct {
+		sub     Subscriber
+		pattern string
+	}
+	deliveries := []delivery{}
+	b.mutex.RLock()
+	for sub := range b.subs[SUB_CHANNEL][channel] {
+		deliveries = append(deliveries, delivery{sub: sub})
+	}
+	for pattern, subs := range b.subs[SUB_PATTERN] {
+		if !helper.GlobMatch(pattern, channel, false) {
+			continue
+		}
+		for sub := range subs {
+			deliveries = append(deliveries, delivery{sub: sub, pattern: pattern})
+		}
+	}
+	b.mutex.RUnlock()
+
+	// Subscribers are called out of lock, so they can subscribe while
+	// messages are being delivered to them
+	for _, d := range deliveries {
+		if "" == d.pattern {
+			d.sub.Deliver(SUB_CHANNEL, "", channel, message)
+		} else {
+			d.sub.Deliver(SUB_PATTERN, d.pattern, channel, message)
+		}
+	}
+	return len(deliveries)
+}
+
+// SPublish : Deliver message to subscribers of shard channel, return count
+// of deliveries
+func (b *Broker) SPublish(channel string, message string) int {
+	subs := []Subscriber{}
+	b.mutex.RLock()
+	for sub := range b.subs[SUB_SHARD][channel] {
+		subs = append(subs, sub)
+	}
+	b.mutex.RUnlock()
+
+	for _, sub := range subs {
+		sub.Deliver(SUB_SHARD, "", channel, message)
+	}
+	return len(subs)
+}
+
+// Names : Sorted names of kind having subscribers and matching pattern,
+// all of them if pattern is empty
+func (b *Broker) Names(kind int, pattern string) []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	names := []string{}
+	for name := range b.subs[kind] {
+		if ("" == pattern) || helper.GlobMatch(pattern, name, false) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NumSub : Count of subscribers of name of kind
+func (b *Broker) NumSub(kind int, name string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.subs[kind][name])
+}
+
+// NumPat : Count of patterns having subscribers
+func (b *Broker) NumPat() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.subs[SUB_PATTERN])
+}
@@ -0,0 +1,712 @@
+package store
+
+import (
+	"gredissimulate/core/clock"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Count of shards, keys are spread to shards by hash
+const (
+	SHARD_BITS  = 6
+	SHARD_COUNT = 1 << SHARD_BITS
+)
+
+// RANDOM_KEY_TRIES : Count of random keys picked from a shard before moving
+// to next shard, when the picked keys are expired
+const RANDOM_KEY_TRIES = 10
+
+type shard struct {
+	mutex   sync.RWMutex
+	items   *Dict              // Objects of keys, dict can be scanned by cursor
+	expires map[string]*Object // Keys with expire time, sampled by active expire
+	watched map[string]*keyVersion
+	expired []string // Keys expired under lock, notified once it is released
+	added   []string // Keys created under lock, notified once it is released
+}
+
+// keyVersion : Version of key watched by connections, it is increased
+// whenever key is modified, deleted or expired
+type keyVersion struct {
+	version uint64
+	refs    int // Count of connections watching key
+}
+
+// ShardedKeyspace : Default keyspace, each shard is guarded by its own lock
+type ShardedKeyspace struct {
+	shards    [SHARD_COUNT]*shard
+	clock     clock.Clock
+	onExpired func(key string)
+	onAdded   func(key string)
+	onMissed  func(key string)
+}
+
+// NewKeyspace : Create a new empty keyspace, expiration is checked against
+// time of clk, real time is used if clk is nil
+func NewKeyspace(clk clock.Clock) *ShardedKeyspace {
+	if nil == clk {
+		clk = clock.NewSystemClock()
+	}
+	ks := &ShardedKeyspace{clock: clk}
+	for i := range ks.shards {
+		ks.shards[i] = newShard()
+	}
+	return ks
+}
+
+// OnExpired : Set function called with each key deleted because it is
+// expired, it is called out of lock of shard and must be set before use
+func (ks *ShardedKeyspace) OnExpired(function func(key string)) {
+	ks.onExpired = function
+}
+
+// OnAdded : Set function called with each key created, it is called out of
+// lock of shard and must be set before use
+func (ks *ShardedKeyspace) OnAdded(function func(key string)) {
+	ks.onAdded = function
+}
+
+// OnMissed : Set function called with each key read but not found by Type,
+// Exists, Touch, View, GetString, GetExpire and ViewMulti, it is called out
+// of lock of shard and must be set before use
+func (ks *ShardedKeyspace) OnMissed(function func(key string)) {
+	ks.onMissed = function
+}
+
+// Type : Get type of key, TYPE_NONE if key does not exist
+func (ks *ShardedKeyspace) Type(key string) string {
+	t := TYPE_NONE
+	ks.read(key, false, true, func(obj *Object) {
+		if nil != obj {
+			t = obj.Type
+		}
+	})
+	return t
+}
+
+// Exists : Whether key exists
+func (ks *ShardedKeyspace) Exists(key string) bool {
+	return TYPE_NONE != ks.Type(key)
+}
+
+// Delete : Delete keys, return count of keys deleted
+func (ks *ShardedKeyspace) Delete(keys ...string) int {
+	count := 0
+	for _, key := range keys {
+		sh := ks.getShard(key)
+		sh.mutex.Lock()
+		if nil != sh.lookup(key, ks.now()) {
+			count++
+		}
+		sh.remove(key)
+		ks.unlock(sh)
+	}
+	return count
+}
+
+// Len : Count of keys, keys expired but not deleted yet are counted
+func (ks *ShardedKeyspace) Len() int {
+	count := 0
+	for _, sh := range ks.shards {
+		sh.mutex.RLock()
+		count = count + sh.items.Len()
+		sh.mutex.RUnlock()
+	}
+	return count
+}
+
+// Flush : Delete all keys
+func (ks *ShardedKeyspace) Flush() {
+	for _, sh := range ks.shards {
+		sh.mutex.Lock()
+		for key := range sh.watched {
+			if _, ok := sh.items.Get(key); ok {
+				sh.modified(key)
+			}
+		}
+		sh.items = NewDict()
+		sh.expires = make(map[string]*Object)
+		ks.unlock(sh)
+	}
+}
+
+// Range : Call function for each key until it returns false, function is
+// called under read lock of shard so it must not access keyspace, lock is
+// released even if function panics
+func (ks *ShardedKeyspace) Range(function func(key string, t string) bool) {
+	now := ks.now()
+	for _, sh := range ks.shards {
+		stopped := false
+		func() {
+			sh.mutex.RLock()
+			defer sh.mutex.RUnlock()
+			sh.items.Range(func(key string, v interface{}) bool {
+				obj := v.(*Object)
+				if obj.isExpired(now) {
+					return true
+				}
+				stopped = !function(key, obj.Type)
+				return !stopped
+			})
+		}()
+		if stopped {
+			return
+		}
+	}
+}
+
+// Scan : Call function for keys of buckets from cursor until count keys are
+// found or about 10 times of count buckets are visited, return cursor of the
+// next call, 0 if iteration is finished. Index of shard is kept in the low
+// bits of cursor and cursor of its dict in the high bits, shards are scanned
+// one by one so keys present during the whole iteration are found at least
+// once. Function is called under read lock of shard
+func (ks *ShardedKeyspace) Scan(cursor uint64, count int64, function func(key string, t string)) uint64 {
+	now := ks.now()
+	index := int(cursor & (SHARD_COUNT - 1))
+	cursor = cursor >> SHARD_BITS
+	found := int64(0)
+	maxIterations := count * 10
+	for index < SHARD_COUNT {
+		sh := ks.shards[index]
+		func() {
+			sh.mutex.RLock()
+			defer sh.mutex.RUnlock()
+			for {
+				cursor = sh.items.Scan(cursor, func(key string, v interface{}) {
+					if obj := v.(*Object); !obj.isExpired(now) {
+						function(key, obj.Type)
+						found++
+					}
+				})
+				maxIterations--
+				if (0 == cursor) || (maxIterations <= 0) || (found >= count) {
+					break
+				}
+			}
+		}()
+
+		if 0 != cursor {
+			return (cursor << SHARD_BITS) | uint64(index)
+		}
+		index++
+		if (maxIterations <= 0) || (found >= count) {
+			break
+		}
+	}
+	if index >= SHARD_COUNT {
+		return 0
+	}
+	// Next call starts from the beginning of the next shard
+	return uint64(index)
+}
+
+// RandomKey : Get a random key, shards are visited from a random one and
+// a random key of shard is picked by its dict
+func (ks *ShardedKeyspace) RandomKey() (string, bool) {
+	now := ks.now()
+	start := rand.Intn(SHARD_COUNT)
+	for i := 0; i < SHARD_COUNT; i++ {
+		sh := ks.shards[(start+i)%SHARD_COUNT]
+		sh.mutex.RLock()
+		for try := 0; try < RANDOM_KEY_TRIES; try++ {
+			key, v, ok := sh.items.RandomKey()
+			if !ok {
+				break
+			}
+			if !v.(*Object).isExpired(now) {
+				sh.mutex.RUnlock()
+				return key, true
+			}
+		}
+		sh.mutex.RUnlock()
+	}
+	return "", false
+}
+
+// Touch : Update access time of key
+func (ks *ShardedKeyspace) Touch(key string) bool {
+	found := false
+	ks.read(key, true, true, func(obj *Object) {
+		found = nil != obj
+	})
+	return found
+}
+
+// Peek : Call function with object of key without updating its access time,
+// key not found is not notified
+func (ks *ShardedKeyspace) Peek(key string, function func(obj *Object)) {
+	ks.read(key, false, false, function)
+}
+
+// GetString : Get value of string key, ErrWrongType if key holds other type
+func (ks *ShardedKeyspace) GetString(key string) (value string, ok bool, err error) {
+	err = ks.View(key, TYPE_STRING, func(v interface{}) error {
+		if nil != v {
+			value = v.(string)
+			ok = true
+		}
+		return nil
+	})
+	return
+}
+
+// SetString : Set value of string key, override value of any type and clear expiry
+func (ks *ShardedKeyspace) SetString(key string, value string) {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	sh.set(key, &Object{Type: TYPE_STRING, Value: value}, ks.now())
+	ks.unlock(sh)
+}
+
+// View : Call function with value of key under read lock
+func (ks *ShardedKeyspace) View(key string, t string, function func(value interface{}) error) (err error) {
+	ks.read(key, true, true, func(obj *Object) {
+		if nil == obj {
+			err = function(nil)
+		} else if obj.Type != t {
+			err = ErrWrongType
+		} else {
+			err = function(obj.Value)
+		}
+	})
+	return
+}
+
+// Update : Call function with value of key under write lock, expiry of
+// existing key is kept
+func (ks *ShardedKeyspace) Update(key string, t string, function func(value interface{}) (interface{}, error)) error {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	now := ks.now()
+	obj := sh.lookup(key, now)
+	var value interface{}
+	if nil != obj {
+		if obj.Type != t {
+			return ErrWrongType
+		}
+		value = obj.Value
+	}
+
+	value, err := function(value)
+	if nil != err {
+		return err
+	}
+	if nil == value {
+		sh.remove(key)
+	} else if nil == obj {
+		sh.set(key, &Object{Type: t, Value: value}, now)
+	} else {
+		obj.Value = value
+		sh.modified(key)
+	}
+	return nil
+}
+
+// Expire : Set expire time of key in unix milliseconds, key is deleted at
+// once if the time has passed
+func (ks *ShardedKeyspace) Expire(key string, at int64) bool {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	now := ks.now()
+	obj := sh.lookup(key, now)
+	if nil == obj {
+		return false
+	}
+	obj.ExpireAt = at
+	if obj.isExpired(now) {
+		sh.remove(key)
+	} else {
+		sh.set(key, obj, now)
+	}
+	return true
+}
+
+// GetExpire : Get expire time of key in unix milliseconds
+func (ks *ShardedKeyspace) GetExpire(key string) (at int64, ok bool) {
+	ks.read(key, false, true, func(obj *Object) {
+		if nil != obj {
+			at = obj.ExpireAt
+			ok = true
+		}
+	})
+	return
+}
+
+// Persist : Remove expire time of key
+func (ks *ShardedKeyspace) Persist(key string) bool {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	now := ks.now()
+	obj := sh.lookup(key, now)
+	if (nil == obj) || (0 == obj.ExpireAt) {
+		return false
+	}
+	obj.ExpireAt = 0
+	sh.set(key, obj, now)
+	return true
+}
+
+// Watch : Start tracking version of key, return its current version
+func (ks *ShardedKeyspace) Watch(key string) uint64 {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	// Key expired before it is watched is not a modification
+	sh.expireIfNeeded(key, ks.now())
+	v, ok := sh.watched[key]
+	if !ok {
+		v = &keyVersion{}
+		sh.watched[key] = v
+	}
+	v.refs++
+	return v.version
+}
+
+// Unwatch : Stop tracking version of key watched by Watch
+func (ks *ShardedKeyspace) Unwatch(key string) {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	v, ok := sh.watched[key]
+	if !ok {
+		return
+	}
+	v.refs--
+	if v.refs <= 0 {
+		delete(sh.watched, key)
+	}
+}
+
+// Version : Current version of watched key, key expired since it is watched
+// is deleted first so its version is increased
+func (ks *ShardedKeyspace) Version(key string) uint64 {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	sh.expireIfNeeded(key, ks.now())
+	if v, ok := sh.watched[key]; ok {
+		return v.version
+	}
+	return 0
+}
+
+// Take : Remove key and return its object
+func (ks *ShardedKeyspace) Take(key string) *Object {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	obj := sh.lookup(key, ks.now())
+	sh.remove(key)
+	return obj
+}
+
+// Put : Store object to key, false if nx is true and key exists
+func (ks *ShardedKeyspace) Put(key string, obj *Object, nx bool) bool {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	now := ks.now()
+	if nx && (nil != sh.lookup(key, now)) {
+		return false
+	}
+	if obj.isExpired(now) {
+		sh.remove(key)
+	} else {
+		sh.set(key, obj, now)
+	}
+	return true
+}
+
+// Mutate : Call function with object of key under write lock
+func (ks *ShardedKeyspace) Mutate(key string, function func(obj *Object) (*Object, error)) error {
+	sh := ks.getShard(key)
+	sh.mutex.Lock()
+	defer ks.unlock(sh)
+
+	now := ks.now()
+	obj, err := function(sh.lookup(key, now))
+	if nil != err {
+		return err
+	}
+	if (nil == obj) || obj.isExpired(now) {
+		sh.remove(key)
+	} else {
+		sh.set(key, obj, now)
+	}
+	return nil
+}
+
+// MutateMulti : Call function with objects of keys under write locks of
+// all their shards, shards are locked in order to avoid dead lock
+func (ks *ShardedKeyspace) MutateMulti(keys []string, function func(objs []*Object) ([]*Object, error)) error {
+	for _, index := range ks.getShardIndexes(keys) {
+		ks.shards[index].mutex.Lock()
+		defer ks.unlock(ks.shards[index])
+	}
+
+	now := ks.now()
+	objs := make([]*Object, len(keys))
+	for i, key := range keys {
+		objs[i] = ks.getShard(key).lookup(key, now)
+	}
+	objs, err := function(objs)
+	if nil != err {
+		return err
+	}
+	for i, key := range keys {
+		sh := ks.getShard(key)
+		if (nil == objs[i]) || objs[i].isExpired(now) {
+			sh.remove(key)
+		} else {
+			sh.set(key, objs[i], now)
+		}
+	}
+	return nil
+}
+
+// ViewMulti : Call function with objects of keys under read locks of all
+// their shards, shards are locked in order to avoid dead lock
+func (ks *ShardedKeyspace) ViewMulti(keys []string, function func(objs []*Object) error) error {
+	// Deferred first so keys not found are notified after shards are unlocked
+	missed := []string{}
+	defer func() {
+		ks.notifyMissed(missed...)
+	}()
+	for _, index := range ks.getShardIndexes(keys) {
+		ks.shards[index].mutex.RLock()
+		defer ks.shards[index].mutex.RUnlock()
+	}
+
+	now := ks.now()
+	objs := make([]*Object, len(keys))
+	for i, key := range keys {
+		if obj, ok := ks.getShard(key).get(key); ok && !obj.isExpired(now) {
+			obj.touch(now)
+			objs[i] = obj
+		} else {
+			missed = append(missed, key)
+		}
+	}
+	return function(objs)
+}
+
+// ActiveExpire : Sample keys with expire time of each shard and delete the
+// expired ones, sampling of a shard is repeated while more than a quarter of
+// sampled keys are expired
+func (ks *ShardedKeyspace) ActiveExpire(samples int) int {
+	count := 0
+	for _, sh := range ks.shards {
+		for {
+			now := ks.now()
+			checked := 0
+			expired := 0
+			sh.mutex.Lock()
+			// Iteration order of map is random, so keys are sampled randomly
+			for key, obj := range sh.expires {
+				if checked >= samples {
+					break
+				}
+				checked++
+				if obj.isExpired(now) {
+					sh.expire(key)
+					expired++
+				}
+			}
+			ks.unlock(sh)
+
+			count = count + expired
+			if (checked < samples) || (expired*4 <= samples) {
+				break
+			}
+		}
+	}
+	return count
+}
+
+// read : Call function with live entry of key under read lock, entry is nil
+// if key does not exist, expired key is deleted lazily afterwards, access
+// time of entry is updated if touch is true and key not found is notified if
+// notify is true. Lock is released even if function panics
+func (ks *ShardedKeyspace) read(key string, touch bool, notify bool, function func(obj *Object)) {
+	sh := ks.getShard(key)
+	now := ks.now()
+
+	var obj *Object
+	expired := false
+	// Deferred first so it runs after read lock is released
+	defer func() {
+		if expired {
+			sh.mutex.Lock()
+			sh.lookup(key, now)
+			ks.unlock(sh)
+		}
+		if notify && (nil == obj) {
+			ks.notifyMissed(key)
+		}
+	}()
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	obj, ok := sh.get(key)
+	expired = ok && obj.isExpired(now)
+	if expired {
+		obj = nil
+	} else if ok && touch {
+		obj.touch(now)
+	}
+	function(obj)
+}
+
+// unlock : Release write lock of shard, then notify keys expired and keys
+// created under it
+func (ks *ShardedKeyspace) unlock(sh *shard) {
+	expired, added := sh.expired, sh.added
+	sh.expired, sh.added = nil, nil
+	sh.mutex.Unlock()
+	if nil != ks.onExpired {
+		for _, key := range expired {
+			ks.onExpired(key)
+		}
+	}
+	if nil != ks.onAdded {
+		for _, key := range added {
+			ks.onAdded(key)
+		}
+	}
+}
+
+// notifyMissed : Notify keys not found by read, must be called out of lock
+func (ks *ShardedKeyspace) notifyMissed(keys ...string) {
+	if nil != ks.onMissed {
+		for _, key := range keys {
+			ks.onMissed(key)
+		}
+	}
+}
+
+func newShard() *shard {
+	return &shard{
+		items:   NewDict(),
+		expires: make(map[string]*Object),
+		watched: make(map[string]*keyVersion),
+	}
+}
+
+func (ks *ShardedKeyspace) getShard(key string) *shard {
+	return ks.shards[ks.getShardIndex(key)]
+}
+
+// getShardIndex : Shard of key by the high bits of the hash used by dict,
+// whose low bits pick bucket in dict of shard, so keys of a shard are still
+// spread over all of its buckets
+func (ks *ShardedKeyspace) getShardIndex(key string) int {
+	return int(hashKey(key) >> (64 - SHARD_BITS))
+}
+
+// getShardIndexes : Sorted distinct indexes of shards of keys
+func (ks *ShardedKeyspace) getShardIndexes(keys []string) []int {
+	indexes := make([]int, 0, len(keys))
+	seen := make(map[int]bool)
+	for _, key := range keys {
+		index := ks.getShardIndex(key)
+		if !seen[index] {
+			seen[index] = true
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func (ks *ShardedKeyspace) now() int64 {
+	return clock.UnixMs(ks.clock)
+}
+
+// lookup : Get live entry of key and update its access time, expired key
+// is deleted, must be called under write lock
+func (sh *shard) lookup(key string, now int64) *Object {
+	obj, ok := sh.get(key)
+	if !ok {
+		return nil
+	}
+	if obj.isExpired(now) {
+		sh.expire(key)
+		return nil
+	}
+	obj.touch(now)
+	return obj
+}
+
+// set : Store object to key and keep index of keys with expire time, key
+// created is kept to be notified by unlock, must be called under write lock
+func (sh *shard) set(key string, obj *Object, now int64) {
+	if 0 == obj.accessedAt {
+		obj.touch(now)
+	}
+	if sh.items.Set(key, obj) {
+		sh.added = append(sh.added, key)
+	}
+	sh.modified(key)
+	if 0 != obj.ExpireAt {
+		sh.expires[key] = obj
+	} else {
+		delete(sh.expires, key)
+	}
+}
+
+// get : Get object of key including expired one, must be called under lock
+func (sh *shard) get(key string) (*Object, bool) {
+	v, ok := sh.items.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Object), true
+}
+
+// remove : Delete key, must be called under write lock
+func (sh *shard) remove(key string) {
+	if sh.items.Delete(key) {
+		sh.modified(key)
+	}
+	delete(sh.expires, key)
+}
+
+// expire : Delete expired key and keep it to be notified by unlock, must be
+// called under write lock
+func (sh *shard) expire(key string) {
+	sh.remove(key)
+	sh.expired = append(sh.expired, key)
+}
+
+// expireIfNeeded : Delete key if it is expired without updating its access
+// time, must be called under write lock
+func (sh *shard) expireIfNeeded(key string, now int64) {
+	if obj, ok := sh.get(key); ok && obj.isExpired(now) {
+		sh.expire(key)
+	}
+}
+
+// modified : Increase version of key if it is watched, must be called under
+// write lock
+func (sh *shard) modified(key string) {
+	if v, ok := sh.watched[key]; ok {
+		v.version++
+	}
+}
+
+func (obj *Object) isExpired(now int64) bool {
+	return (0 != obj.ExpireAt) && (obj.ExpireAt <= now)
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"gredissimulate/core/clock"
+	"testing"
+	"time"
+)
+
+// callRecovered : Call function and recover its panic, as processor does
+// for commands
+func callRecovered(function func()) {
+	defer func() {
+		recover()
+	}()
+	function()
+}
+
+func TestReadUnlocksShardOnPanic(t *testing.T) {
+	ks := NewKeyspace(clock.NewFakeClock(time.Unix(1000, 0)))
+	ks.SetString("key", "value")
+
+	readers := map[string]func(){
+		"View": func() {
+			ks.View("key", TYPE_STRING, func(value interface{}) error {
+				panic("view")
+			})
+		},
+		"Range": func() {
+			ks.Range(func(key string, t string) bool {
+				panic("range")
+			})
+		},
+		"Scan": func() {
+			ks.Scan(0, 10, func(key string, t string) {
+				panic("scan")
+			})
+		},
+	}
+	for name, reader := range readers {
+		callRecovered(reader)
+
+		written := make(chan struct{})
+		go func() {
+			ks.SetString("key", name)
+			close(written)
+		}()
+		select {
+		case <-written:
+		case <-time.After(time.Second):
+			t.Fatalf("shard is still locked after panic in %s", name)
+		}
+	}
+}
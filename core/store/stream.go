@@ -0,0 +1,587 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// INVALID_ENTRIES_READ : Entries read of consumer group is unknown, lag of
+// the group can not be computed
+const INVALID_ENTRIES_READ = -1
+
+// StreamID : ID of stream entry, unix time in milliseconds and sequence
+// number of entries added in the same millisecond
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MaxStreamID : The greatest ID
+var MaxStreamID = StreamID{math.MaxUint64, math.MaxUint64}
+
+// ParseStreamID : Parse ID in form of `ms-seq` or `ms`, seq is missingSeq
+// if it is omitted
+func ParseStreamID(str string, missingSeq uint64) (StreamID, bool) {
+	var id StreamID
+	var err error
+	parts := strings.SplitN(str, "-", 2)
+	if id.Ms, err = strconv.ParseUint(parts[0], 10, 64); nil != err {
+		return id, false
+	}
+	if 1 == len(parts) {
+		id.Seq = missingSeq
+		return id, true
+	}
+	if id.Seq, err = strconv.ParseUint(parts[1], 10, 64); nil != err {
+		return id, false
+	}
+	return id, true
+}
+
+// String : Format ID as `ms-seq`
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare : -1 if id is less than other, 1 if greater, 0 if equal
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+// Less : Whether id is less than other
+func (id StreamID) Less(other StreamID) bool {
+	return id.Compare(other) < 0
+}
+
+// IsZero : Whether id is 0-0
+func (id StreamID) IsZero() bool {
+	return (0 == id.Ms) && (0 == id.Seq)
+}
+
+// Next : The least ID greater than id, false if id is the greatest
+func (id StreamID) Next() (StreamID, bool) {
+	if math.MaxUint64 != id.Seq {
+		return StreamID{id.Ms, id.Seq + 1}, true
+	}
+	if math.MaxUint64 != id.Ms {
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// Prev : The greatest ID less than id, false if id is 0-0
+func (id StreamID) Prev() (StreamID, bool) {
+	if 0 != id.Seq {
+		return StreamID{id.Ms, id.Seq - 1}, true
+	}
+	if 0 != id.Ms {
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// StreamEntry : Entry of stream, fields are pairs of field and value
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// Stream : Append-only log of entries ordered by ID, entries are kept in a
+// slice so ranges are found by binary search
+type Stream struct {
+	entries      []StreamEntry
+	lastID       StreamID // ID of the last entry ever added
+	maxDeletedID StreamID // The greatest ID deleted by XDEL
+	entriesAdded int64    // Count of entries ever added
+	groups       map[string]*ConsumerGroup
+}
+
+// NewStream : Create an empty stream
+func NewStream() *Stream {
+	return &Stream{groups: make(map[string]*ConsumerGroup)}
+}
+
+// Copy : Copy of stream with its consumer groups
+func (s *Stream) Copy() *Stream {
+	stream := &Stream{
+		entries:      append([]StreamEntry{}, s.entries...),
+		lastID:       s.lastID,
+		maxDeletedID: s.maxDeletedID,
+		entriesAdded: s.entriesAdded,
+		groups:       make(map[string]*ConsumerGroup, len(s.groups)),
+	}
+	for name, g := range s.groups {
+		group := stream.CreateGroup(name, g.LastID, g.EntriesRead)
+		for _, c := range g.consumers {
+			consumer := group.CreateConsumer(c.Name, c.SeenTime)
+			consumer.ActiveTime = c.ActiveTime
+		}
+		for _, p := range g.pending.entries {
+			pending := group.Claim(p.ID, group.consumers[p.Consumer.Name])
+			pending.DeliveryTime, pending.DeliveryCount = p.DeliveryTime, p.DeliveryCount
+		}
+	}
+	return stream
+}
+
+// Len : Count of entries
+func (s *Stream) Len() int {
+	return len(s.entries)
+}
+
+// LastID : ID of the last entry ever added, it is kept after entry is deleted
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// MaxDeletedID : The greatest ID deleted by Delete
+func (s *Stream) MaxDeletedID() StreamID {
+	return s.maxDeletedID
+}
+
+// EntriesAdded : Count of entries ever added
+func (s *Stream) EntriesAdded() int64 {
+	return s.entriesAdded
+}
+
+// FirstID : ID of the first entry, 0-0 if stream is empty
+func (s *Stream) FirstID() StreamID {
+	if 0 == len(s.entries) {
+		return StreamID{}
+	}
+	return s.entries[0].ID
+}
+
+// First : The first entry, false if stream is empty
+func (s *Stream) First() (StreamEntry, bool) {
+	if 0 == len(s.entries) {
+		return StreamEntry{}, false
+	}
+	return s.entries[0], true
+}
+
+// Last : The last entry, false if stream is empty
+func (s *Stream) Last() (StreamEntry, bool) {
+	if 0 == len(s.entries) {
+		return StreamEntry{}, false
+	}
+	return s.entries[len(s.entries)-1], true
+}
+
+// NextID : ID generated for entry added at ms, false if no ID is greater
+// than the last ID
+func (s *Stream) NextID(ms uint64) (StreamID, bool) {
+	if ms > s.lastID.Ms {
+		return StreamID{ms, 0}, true
+	}
+	return s.lastID.Next()
+}
+
+// Add : Append entry, id must be greater than the last ID
+func (s *Stream) Add(id StreamID, fields []string) {
+	s.entries = append(s.entries, StreamEntry{ID: id, Fields: fields})
+	s.lastID = id
+	s.entriesAdded++
+}
+
+// Restore : Set IDs and counter kept after entries are deleted, for stream
+// loaded with its entries added already
+func (s *Stream) Restore(lastID StreamID, maxDeletedID StreamID, entriesAdded int64) {
+	s.lastID = lastID
+	s.maxDeletedID = maxDeletedID
+	s.entriesAdded = entriesAdded
+}
+
+// Get : Get entry by ID
+func (s *Stream) Get(id StreamID) (StreamEntry, bool) {
+	i := s.search(id)
+	if (i < len(s.entries)) && (s.entries[i].ID == id) {
+		return s.entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+// Range : Call function for entries with ID from start to end inclusive in
+// ascending order, or descending order if reverse is true, until it
+// returns false
+func (s *Stream) Range(start StreamID, end StreamID, reverse bool, function func(entry StreamEntry) bool) {
+	if end.Less(start) {
+		return
+	}
+	first := s.search(start)
+	last := sort.Search(len(s.entries), func(i int) bool {
+		return end.Less(s.entries[i].ID)
+	}) - 1
+	if reverse {
+		for i := last; i >= first; i-- {
+			if !function(s.entries[i]) {
+				return
+			}
+		}
+		return
+	}
+	for i := first; i <= last; i++ {
+		if !function(s.entries[i]) {
+			return
+		}
+	}
+}
+
+// Delete : Delete entry by ID, false if it does not exist
+func (s *Stream) Delete(id StreamID) bool {
+	i := s.search(id)
+	if (i >= len(s.entries)) || (s.entries[i].ID != id) {
+		return false
+	}
+	s.entries = append(s.entries[:i], s.entries[i+1:]...)
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	return true
+}
+
+// TrimMaxLen : Delete the oldest entries until at most maxLen entries are
+// left, limit is the max count of entries deleted and 0 means no limit,
+// return count of entries deleted
+func (s *Stream) TrimMaxLen(maxLen int64, limit int64) int64 {
+	if int64(len(s.entries)) <= maxLen {
+		return 0
+	}
+	return s.trimHead(int64(len(s.entries))-maxLen, limit)
+}
+
+// TrimMinID : Delete entries with ID less than minID, limit is the max
+// count of entries deleted and 0 means no limit, return count of entries
+// deleted
+func (s *Stream) TrimMinID(minID StreamID, limit int64) int64 {
+	return s.trimHead(int64(s.search(minID)), limit)
+}
+
+func (s *Stream) trimHead(count int64, limit int64) int64 {
+	if (limit > 0) && (count > limit) {
+		count = limit
+	}
+	if count <= 0 {
+		return 0
+	}
+	for i := int64(0); i < count; i++ {
+		s.entries[i] = StreamEntry{}
+	}
+	s.entries = s.entries[count:]
+	return count
+}
+
+// search : Index of the first entry with ID greater than or equal to id
+func (s *Stream) search(id StreamID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].ID.Less(id)
+	})
+}
+
+// HasTombstones : Whether an entry with ID in range from start to end
+// inclusive has been deleted by Delete
+func (s *Stream) HasTombstones(start StreamID, end StreamID) bool {
+	if (0 == len(s.entries)) || s.maxDeletedID.IsZero() {
+		return false
+	}
+	return !s.maxDeletedID.Less(start) && !end.Less(s.maxDeletedID)
+}
+
+// EstimateEntriesRead : Count of entries added up to id, INVALID_ENTRIES_READ
+// if it can not be known because of deleted entries, same as redis
+func (s *Stream) EstimateEntriesRead(id StreamID) int64 {
+	if 0 == s.entriesAdded {
+		return 0
+	}
+	if (0 == len(s.entries)) && !s.lastID.Less(id) {
+		return s.entriesAdded
+	}
+	switch id.Compare(s.lastID) {
+	case 0:
+		return s.entriesAdded
+	case 1:
+		return INVALID_ENTRIES_READ
+	}
+	if s.maxDeletedID.IsZero() || s.maxDeletedID.Less(s.FirstID()) {
+		switch id.Compare(s.FirstID()) {
+		case -1:
+			return s.entriesAdded - int64(len(s.entries))
+		case 0:
+			return s.entriesAdded - int64(len(s.entries)) + 1
+		}
+	}
+	return INVALID_ENTRIES_READ
+}
+
+// Lag : Count of entries not delivered to group yet, false if it can not be known
+func (s *Stream) Lag(g *ConsumerGroup) (int64, bool) {
+	if 0 == s.entriesAdded {
+		return 0, true
+	}
+	if (INVALID_ENTRIES_READ != g.EntriesRead) && !s.HasTombstones(g.LastID, MaxStreamID) {
+		return s.entriesAdded - g.EntriesRead, true
+	}
+	if read := s.EstimateEntriesRead(g.LastID); INVALID_ENTRIES_READ != read {
+		return s.entriesAdded - read, true
+	}
+	return 0, false
+}
+
+// Group : Get consumer group by name, nil if it does not exist
+func (s *Stream) Group(name string) *ConsumerGroup {
+	return s.groups[name]
+}
+
+// CreateGroup : Create consumer group which delivers entries after lastID,
+// nil if group exists
+func (s *Stream) CreateGroup(name string, lastID StreamID, entriesRead int64) *ConsumerGroup {
+	if _, ok := s.groups[name]; ok {
+		return nil
+	}
+	g := &ConsumerGroup{Name: name, LastID: lastID, EntriesRead: entriesRead, consumers: make(map[string]*Consumer)}
+	s.groups[name] = g
+	return g
+}
+
+// DestroyGroup : Delete consumer group, false if it does not exist
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups : Consumer groups ordered by name
+func (s *Stream) Groups() []*ConsumerGroup {
+	groups := make([]*ConsumerGroup, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// MarkDelivered : Update last delivered ID and entries read of group after
+// entry with id is delivered to it
+func (s *Stream) MarkDelivered(g *ConsumerGroup, id StreamID) {
+	if (INVALID_ENTRIES_READ != g.EntriesRead) && !s.HasTombstones(id, MaxStreamID) {
+		g.EntriesRead++
+	} else if 0 != s.entriesAdded {
+		g.EntriesRead = s.EstimateEntriesRead(id)
+	}
+	g.LastID = id
+}
+
+// ConsumerGroup : Group of consumers sharing entries of stream, entries
+// delivered but not acknowledged are kept in pending entries list
+type ConsumerGroup struct {
+	Name        string
+	LastID      StreamID // ID of the last entry delivered
+	EntriesRead int64    // Count of entries delivered, INVALID_ENTRIES_READ if unknown
+	pending     pendingList
+	consumers   map[string]*Consumer
+}
+
+// Consumer : Consumer of group, owns entries delivered to it until they are
+// acknowledged or claimed by others
+type Consumer struct {
+	Name       string
+	SeenTime   int64 // Unix time in milliseconds of the last attempted interaction
+	ActiveTime int64 // Unix time in milliseconds of the last successful interaction, -1 if never
+	pending    pendingList
+}
+
+// PendingEntry : Entry delivered to consumer and not acknowledged yet
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      *Consumer
+	DeliveryTime  int64 // Unix time in milliseconds of the last delivery
+	DeliveryCount int64
+}
+
+// Consumer : Get consumer by name, nil if it does not exist
+func (g *ConsumerGroup) Consumer(name string) *Consumer {
+	return g.consumers[name]
+}
+
+// CreateConsumer : Create consumer, nil if it exists
+func (g *ConsumerGroup) CreateConsumer(name string, now int64) *Consumer {
+	if _, ok := g.consumers[name]; ok {
+		return nil
+	}
+	c := &Consumer{Name: name, SeenTime: now, ActiveTime: -1}
+	g.consumers[name] = c
+	return c
+}
+
+// LookupConsumer : Get consumer by name, it is created if it does not exist,
+// its seen time is updated
+func (g *ConsumerGroup) LookupConsumer(name string, now int64) *Consumer {
+	c := g.consumers[name]
+	if nil == c {
+		c = g.CreateConsumer(name, now)
+	}
+	c.SeenTime = now
+	return c
+}
+
+// DeleteConsumer : Delete consumer and its pending entries, return count of
+// pending entries deleted, false if consumer does not exist
+func (g *ConsumerGroup) DeleteConsumer(name string) (int, bool) {
+	c, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	for _, p := range c.pending.entries {
+		g.pending.remove(p.ID)
+	}
+	delete(g.consumers, name)
+	return len(c.pending.entries), true
+}
+
+// Consumers : Consumers ordered by name
+func (g *ConsumerGroup) Consumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		consumers = append(consumers, c)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers
+}
+
+// PendingLen : Count of pending entries of group
+func (g *ConsumerGroup) PendingLen() int {
+	return len(g.pending.entries)
+}
+
+// Pending : Get pending entry by ID, nil if it is not pending
+func (g *ConsumerGroup) Pending(id StreamID) *PendingEntry {
+	return g.pending.get(id)
+}
+
+// RangePending : Call function for pending entries of group with ID from
+// start to end inclusive in ascending order until it returns false
+func (g *ConsumerGroup) RangePending(start StreamID, end StreamID, function func(p *PendingEntry) bool) {
+	g.pending.rangeFrom(start, end, function)
+}
+
+// Deliver : Add entry to pending entries of consumer, entry owned by other
+// consumer is moved to consumer and its delivery count is reset
+func (g *ConsumerGroup) Deliver(id StreamID, c *Consumer, now int64) {
+	p := g.pending.get(id)
+	if nil == p {
+		p = &PendingEntry{ID: id}
+		g.pending.add(p)
+	} else {
+		p.Consumer.pending.remove(id)
+	}
+	p.Consumer = c
+	p.DeliveryTime = now
+	p.DeliveryCount = 1
+	c.pending.add(p)
+}
+
+// Claim : Move pending entry to consumer, new pending entry is created if
+// it does not exist
+func (g *ConsumerGroup) Claim(id StreamID, c *Consumer) *PendingEntry {
+	p := g.pending.get(id)
+	if nil == p {
+		p = &PendingEntry{ID: id}
+		g.pending.add(p)
+	} else if p.Consumer != c {
+		p.Consumer.pending.remove(id)
+	} else {
+		return p
+	}
+	p.Consumer = c
+	c.pending.add(p)
+	return p
+}
+
+// Ack : Remove entry from pending entries, false if it is not pending
+func (g *ConsumerGroup) Ack(id StreamID) bool {
+	p := g.pending.get(id)
+	if nil == p {
+		return false
+	}
+	g.pending.remove(id)
+	p.Consumer.pending.remove(id)
+	return true
+}
+
+// PendingLen : Count of pending entries of consumer
+func (c *Consumer) PendingLen() int {
+	return len(c.pending.entries)
+}
+
+// RangePending : Call function for pending entries of consumer with ID from
+// start to end inclusive in ascending order until it returns false
+func (c *Consumer) RangePending(start StreamID, end StreamID, function func(p *PendingEntry) bool) {
+	c.pending.rangeFrom(start, end, function)
+}
+
+// pendingList : Pending entries ordered by ID
+type pendingList struct {
+	entries []*PendingEntry
+}
+
+func (l *pendingList) search(id StreamID) int {
+	return sort.Search(len(l.entries), func(i int) bool {
+		return !l.entries[i].ID.Less(id)
+	})
+}
+
+func (l *pendingList) get(id StreamID) *PendingEntry {
+	i := l.search(id)
+	if (i < len(l.entries)) && (l.entries[i].ID == id) {
+		return l.entries[i]
+	}
+	return nil
+}
+
+func (l *pendingList) add(p *PendingEntry) {
+	i := l.search(p.ID)
+	l.entries = append(l.entries, nil)
+	copy(l.entries[i+1:], l.entries[i:])
+	l.entries[i] = p
+}
+
+func (l *pendingList) remove(id StreamID) {
+	i := l.search(id)
+	if (i < len(l.entries)) && (l.entries[i].ID == id) {
+		copy(l.entries[i:], l.entries[i+1:])
+		l.entries[len(l.entries)-1] = nil
+		l.entries = l.entries[:len(l.entries)-1]
+	}
+}
+
+// rangeFrom : Function may remove the pending entry it is called with
+func (l *pendingList) rangeFrom(start StreamID, end StreamID, function func(p *PendingEntry) bool) {
+	i := l.search(start)
+	for i < len(l.entries) {
+		p := l.entries[i]
+		if end.Less(p.ID) || !function(p) {
+			return
+		}
+		if (i < len(l.entries)) && (l.entries[i] == p) {
+			i++
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package store
+
+import "math/rand"
+
+// Parameters of skiplist, same as zset of redis
+const (
+	ZSKIPLIST_MAXLEVEL = 32
+	ZSKIPLIST_P        = 0.25
+)
+
+// ScoreBound : Bound of score range, min or max of ZRANGEBYSCORE
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound : Bound of lexicographical range, min or max of ZRANGEBYLEX
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int // -1 if bound is `-`, 1 if bound is `+`, 0 otherwise
+}
+
+// ZSet : Sorted set, members are mapped to scores by a dict and ordered by
+// score then member in a skiplist, so members are accessed by rank or score
+// in O(log(N))
+type ZSet struct {
+	dict *Dict
+	zsl  *skiplist
+}
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int // Count of nodes skipped by forward
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+// NewZSet : Create an empty sorted set
+func NewZSet() *ZSet {
+	return &ZSet{dict: NewDict(), zsl: newSkiplist()}
+}
+
+// Len : Count of members
+func (z *ZSet) Len() int {
+	return z.zsl.length
+}
+
+// Dict : Dict of members to float64 scores, used to scan or pick random
+// members, must not be modified
+func (z *ZSet) Dict() *Dict {
+	return z.dict
+}
+
+// Copy : Copy of sorted set
+func (z *ZSet) Copy() *ZSet {
+	zset := NewZSet()
+	for x := z.zsl.header.level[0].forward; nil != x; x = x.level[0].forward {
+		zset.Add(x.member, x.score)
+	}
+	return zset
+}
+
+// Score : Get score of member
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, ok := z.dict.Get(member)
+	if !ok {
+		return 0, false
+	}
+	return score.(float64), true
+}
+
+// Add : Set score of member, return true if member is created
+func (z *ZSet) Add(member string, score float64) bool {
+	if old, ok := z.Score(member); ok {
+		if old != score {
+			z.zsl.delete(old, member)
+			z.zsl.insert(score, member)
+			z.dict.Set(member, score)
+		}
+		return false
+	}
+	z.zsl.insert(score, member)
+	z.dict.Set(member, score)
+	return true
+}
+
+// Delete : Delete member, return true if member exists
+func (z *ZSet) Delete(member string) bool {
+	score, ok := z.Score(member)
+	if !ok {
+		return false
+	}
+	z.zsl.delete(score, member)
+	z.dict.Delete(member)
+	return true
+}
+
+// Rank : Get rank of member counted from 0 in ascending order
+func (z *ZSet) Rank(member string) (int, bool) {
+	score, ok := z.Score(member)
+	if !ok {
+		return 0, false
+	}
+	return z.zsl.getRank(score, member) - 1, true
+}
+
+// Range : Call function for members with rank from start to stop inclusive
+// in ascending order, or descending order from stop to start if reverse is
+// true, ranks must be in range [0, Len())
+func (z *ZSet) Range(start int, stop int, reverse bool, function func(member string, score float64)) {
+	if start > stop {
+		return
+	}
+	if reverse {
+		x := z.zsl.getByRank(stop + 1)
+		for i := stop; i >= start; i-- {
+			function(x.member, x.score)
+			x = x.backward
+		}
+		return
+	}
+	x := z.zsl.getByRank(start + 1)
+	for i := start; i <= stop; i++ {
+		function(x.member, x.score)
+		x = x.level[0].forward
+	}
+}
+
+// ScoreRange : Get ranks of the first and the last member in score range,
+// first is greater than last if no member is in range
+func (z *ZSet) ScoreRange(min ScoreBound, max ScoreBound) (first int, last int) {
+	first = z.zsl.countWhile(func(x *skiplistNode) bool {
+		return (x.score < min.Value) || (min.Exclusive && (x.score == min.Value))
+	})
+	last = z.zsl.countWhile(func(x *skiplistNode) bool {
+		return (x.score < max.Value) || (!max.Exclusive && (x.score == max.Value))
+	}) - 1
+	return
+}
+
+// LexRange : Get ranks of the first and the last member in lexicographical
+// range, first is greater than last if no member is in range, members are
+// expected to have the same score
+func (z *ZSet) LexRange(min LexBound, max LexBound) (first int, last int) {
+	first = z.zsl.countWhile(func(x *skiplistNode) bool {
+		return !min.gte(x.member)
+	})
+	last = z.zsl.countWhile(func(x *skiplistNode) bool {
+		return max.lte(x.member)
+	}) - 1
+	return
+}
+
+// DeleteRange : Delete members with rank from start to stop inclusive,
+// return count of members deleted
+func (z *ZSet) DeleteRange(start int, stop int) int {
+	if start > stop {
+		return 0
+	}
+	members := make([]string, 0, stop-start+1)
+	z.Range(start, stop, false, func(member string, score float64) {
+		members = append(members, member)
+	})
+	for _, member := range members {
+		z.Delete(member)
+	}
+	return len(members)
+}
+
+// gte : Whether member is greater than or equal to bound as a min bound
+func (bound LexBound) gte(member string) bool {
+	if 0 != bound.Inf {
+		return bound.Inf < 0
+	}
+	if bound.Exclusive {
+		return member > bound.Value
+	}
+	return member >= bound.Value
+}
+
+// lte : Whether member is less than or equal to bound as a max bound
+func (bound LexBound) lte(member string) bool {
+	if 0 != bound.Inf {
+		return bound.Inf > 0
+	}
+	if bound.Exclusive {
+		return member < bound.Value
+	}
+	return member <= bound.Value
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, ZSKIPLIST_MAXLEVEL)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for (level < ZSKIPLIST_MAXLEVEL) && (rand.Float64() < ZSKIPLIST_P) {
+		level++
+	}
+	return level
+}
+
+// less : Whether node is ordered before score and member
+func (x *skiplistNode) less(score float64, member string) bool {
+	return (x.score < score) || ((x.score == score) && (x.member < member))
+}
+
+// insert : Insert member, member must not exist
+func (zsl *skiplist) insert(score float64, member string) {
+	var update [ZSKIPLIST_MAXLEVEL]*skiplistNode
+	var rank [ZSKIPLIST_MAXLEVEL]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for (nil != x.level[i].forward) && x.level[i].forward.less(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if nil != x.level[0].forward {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+}
+
+// delete : Delete member with score, false if it is not found
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [ZSKIPLIST_MAXLEVEL]*skiplistNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for (nil != x.level[i].forward) && x.level[i].forward.less(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if (nil == x) || (x.score != score) || (x.member != member) {
+		return false
+	}
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if nil != x.level[0].forward {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for (zsl.level > 1) && (nil == zsl.header.level[zsl.level-1].forward) {
+		zsl.level--
+	}
+	zsl.length--
+	return true
+}
+
+// getRank : Get rank of member counted from 1, 0 if it is not found
+func (zsl *skiplist) getRank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for (nil != x.level[i].forward) && !(score < x.level[i].forward.score ||
+			((score == x.level[i].forward.score) && (member < x.level[i].forward.member))) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if (x != zsl.header) && (x.member == member) {
+			return rank
+		}
+	}
+	return 0
+}
+
+// getByRank : Get node by rank counted from 1
+func (zsl *skiplist) getByRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for (nil != x.level[i].forward) && (traversed+x.level[i].span <= rank) {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// countWhile : Count of leading nodes matching predicate, predicate must
+// match a prefix of the list
+func (zsl *skiplist) countWhile(predicate func(x *skiplistNode) bool) int {
+	count := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for (nil != x.level[i].forward) && predicate(x.level[i].forward) {
+			count += x.level[i].span
+			x = x.level[i].forward
+		}
+	}
+	return count
+}
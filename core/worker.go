@@ -2,26 +2,54 @@ package core
 
 import (
 	"bufio"
+	"bytes"
 	"context"
-	"errors"
+	"gredissimulate/core/clock"
 	"gredissimulate/core/processor"
 	"gredissimulate/core/proto"
+	"gredissimulate/core/store"
 	"gredissimulate/logger"
+	"io"
 	"net"
 	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 )
 
+// WRITE_BUFFER_SIZE : Size of buffered writer of worker
+const WRITE_BUFFER_SIZE = 64 * 1024
+
+// WRITE_FLUSH_THRESHOLD : Flush buffered responses once they reach this size
+// even if there are still pipelined commands waiting to be processed
+const WRITE_FLUSH_THRESHOLD = 32 * 1024
+
+// CLOSE_WRITE_TIMEOUT : Responses queued when connection ends are dropped
+// if they can not be written in this time
+const CLOSE_WRITE_TIMEOUT = 5 * time.Second
+
 // Worker : worker for client
 type Worker struct {
-	ctx         context.Context
-	conn        net.Conn
-	newProcFunc processor.Create
-	needAuth    bool
-	passwd      string
-	scanner     *bufio.Scanner
-	readOnly    bool
-	slaveModel  bool
-	readBytes   int
+	ctx        context.Context
+	conn       net.Conn
+	proc       processor.Processor
+	session    *processor.Session
+	reader     *bufio.Reader
+	writer     *bufio.Writer
+	encoder    *proto.Encoder
+	output     *outputQueue
+	written    chan struct{} // Closed when writer goroutine exits
+	readOnly   bool
+	slaveModel bool
+	readBytes  int
+
+	// Writer is shared by replies and responses pushed by other goroutines,
+	// pushed ones are kept encoded in pending while writer holds replies not
+	// queued yet, so they never interleave with or get ahead of replies
+	writeMutex sync.Mutex
+	busy       bool
+	pending    []byte
 }
 
 // WorkerConf : worker config
@@ -29,28 +57,69 @@ type WorkerConf struct {
 	Passwd      string
 	ReadOnly    bool
 	NewProcFunc processor.Create
+	Databases   *store.Databases
+	Clock       clock.Clock
+	EnableDebug bool
 }
 
-// NewWorker : Create new worker instance
+// NewWorker : Create new worker instance, processor and session are created
+// once and live as long as the connection
 func NewWorker(ctx context.Context, conn net.Conn, conf WorkerConf) (*Worker, error) {
-	passwd := conf.Passwd
-	needAuth := false
-	if "" != conf.Passwd {
-		needAuth = true
+	output := newOutputQueue(OUTPUT_QUEUE_LIMIT)
+	writer := bufio.NewWriterSize(output, WRITE_BUFFER_SIZE)
+	procConf := processor.ProcConf{
+		Passwd:      conf.Passwd,
+		Databases:   conf.Databases,
+		Clock:       conf.Clock,
+		EnableDebug: conf.EnableDebug,
 	}
 	worker := &Worker{
-		ctx:         ctx,
-		conn:        conn,
-		newProcFunc: conf.NewProcFunc,
-		needAuth:    needAuth,
-		passwd:      passwd,
-		scanner:     bufio.NewScanner(conn),
-		readOnly:    conf.ReadOnly,
-		readBytes:   0,
+		ctx:       ctx,
+		conn:      conn,
+		proc:      conf.NewProcFunc(procConf),
+		session:   processor.NewSession(conn.RemoteAddr().String(), conf.Passwd),
+		reader:    bufio.NewReader(conn),
+		writer:    writer,
+		encoder:   proto.NewEncoder(writer, proto.PROTO_RESP2),
+		output:    output,
+		written:   make(chan struct{}),
+		readOnly:  conf.ReadOnly,
+		readBytes: 0,
+	}
+	worker.session.SetConnWatcher(worker.watchConn)
+	if !conf.ReadOnly {
+		worker.session.SetPusher(worker.push)
 	}
 	return worker, nil
 }
 
+// watchConn : Watch connection while command is blocked, pending responses
+// are flushed first so client gets them before the blocked one, and pushed
+// responses are written at once until command is unblocked
+func (worker *Worker) watchConn() (<-chan struct{}, func()) {
+	worker.setBusy(false)
+	closed := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		// Peek returns at once if client sends more commands, which are
+		// read after the blocked command is done
+		_, err := worker.reader.Peek(1)
+		if nil != err {
+			if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+				close(closed)
+			}
+		}
+	}()
+	stop := func() {
+		worker.conn.SetReadDeadline(time.Now())
+		<-exited
+		worker.conn.SetReadDeadline(time.Time{})
+		worker.setBusy(true)
+	}
+	return closed, stop
+}
+
 // NetError : NetError
 type NetError struct {
 	s string
@@ -69,83 +138,214 @@ func (e *NetError) Error() string {
 
 // DoServe : Do the worker's work
 func (worker *Worker) DoServe() {
+	go worker.writeOutput()
 	defer func() {
+		if r := recover(); nil != r {
+			logger.LogError("Panic in worker of", worker.conn.RemoteAddr(), ":", r, "\n"+string(debug.Stack()))
+		}
 		logger.LogInfo("Remote client disconnect: ", worker.conn.RemoteAddr())
+		worker.session.Close()
+		worker.Flush()
+		worker.output.Close()
+		worker.conn.SetWriteDeadline(time.Now().Add(CLOSE_WRITE_TIMEOUT))
+		<-worker.written
 		worker.conn.Close()
 	}()
 
 	for {
-		proc := worker.newProcFunc(worker.passwd)
-		err := worker.ProcessMultiCmd(proc)
+		err := worker.ProcessCmd()
 		if nil != err {
 			break
 		}
 	}
 }
 
-// ProcessMultiCmd : Process multi commands
-func (worker *Worker) ProcessMultiCmd(proc processor.Processor) error {
+// writeOutput : Write bytes of output queue to socket until queue is closed,
+// connection is closed if writing fails
+func (worker *Worker) writeOutput() {
+	defer close(worker.written)
 	for {
-		parser := proto.NewParser()
-		request, err := parser.ParseCmd(worker)
-		var response *proto.Response
-		if nil != err {
-			if "proto.NetError" == reflect.TypeOf(err).String() {
-				return err
-			}
-
-			response = proto.NewErrorRes("Parse cmd fail")
-		} else {
-			if worker.NeedAuth() {
-				if "AUTH" == request.Cmd {
-					response, err = proc.AUTH(request)
-
-					if nil == err {
-						worker.needAuth = false
-					} else {
-						worker.needAuth = true
-					}
-				} else {
-					response = proto.NewErrorRes("NOAUTH Authentication required.")
-				}
-			} else {
-				// Use processor
-				response, err = processor.ProcessReq(proc, request)
-				if nil != err {
-					logger.LogError(err)
-				}
-			}
+		data := worker.output.Take()
+		if nil == data {
+			return
+		}
+		if _, err := worker.conn.Write(data); nil != err {
+			logger.LogError("Write to", worker.conn.RemoteAddr(), "fail:", err)
+			worker.output.Abort()
+			worker.conn.Close()
+			return
 		}
+	}
+}
 
-		if false == worker.readOnly {
-			worker.conn.Write([]byte(proto.BuildResBinary(response)))
+// ProcessCmd : Process one command, replies of client not reading them wait
+// here before next command is read
+func (worker *Worker) ProcessCmd() error {
+	worker.output.Wait()
+	parser := proto.NewParser()
+	request, err := parser.ParseCmd(worker)
+	var response *proto.Response
+	var protoErr error
+	if nil != err {
+		if "proto.NetError" == reflect.TypeOf(err).String() {
+			return err
 		}
 
-		if !proc.IsMulti() {
-			break
+		// Rest of input is out of sync after any parse error, close
+		// connection after reply
+		response = proto.NewErrorRes("ERR " + err.Error())
+		protoErr = err
+	} else {
+		// Use processor
+		worker.setBusy(true)
+		response, err = processor.ProcessReq(worker.proc, worker.session, request)
+		if nil != err {
+			logger.LogError(err)
 		}
 	}
 
+	if worker.readOnly {
+		return protoErr
+	}
+
+	// Responses are queued when reading next command would block, so
+	// pipelined commands get their responses in one write
+	worker.writeMutex.Lock()
+	defer worker.writeMutex.Unlock()
+	worker.busy = true
+	worker.encoder.SetProtoVer(worker.session.ProtoVer)
+	err = worker.encoder.Encode(response)
+	if nil != err {
+		return err
+	}
+	err = worker.writePending()
+	if nil != err {
+		return err
+	}
+	if nil != protoErr {
+		return protoErr
+	}
+	if worker.writer.Buffered() >= WRITE_FLUSH_THRESHOLD {
+		return worker.flush()
+	}
 	return nil
 }
 
+// Flush : Queue buffered responses and pending pushed ones to be written to
+// socket, pushed responses are queued at once afterwards until next command
+func (worker *Worker) Flush() error {
+	worker.writeMutex.Lock()
+	defer worker.writeMutex.Unlock()
+	worker.busy = false
+	err := worker.writePending()
+	if nil == err {
+		err = worker.flush()
+	}
+	return err
+}
+
+// push : Queue response pushed by other goroutines without waiting, it is
+// kept pending while a command is being processed or its reply is buffered,
+// and queued after the reply. Client is disconnected if it does not read
+// responses fast enough, so a slow subscriber never stalls publishers
+func (worker *Worker) push(res *proto.Response) {
+	worker.writeMutex.Lock()
+	defer worker.writeMutex.Unlock()
+	var buffer bytes.Buffer
+	proto.NewEncoder(&buffer, worker.encoder.GetProtoVer()).Encode(res)
+
+	var err error
+	if !worker.busy {
+		err = worker.output.Push(buffer.Bytes())
+	} else if len(worker.pending)+buffer.Len() > OUTPUT_QUEUE_LIMIT {
+		err = ErrOutputOverflow
+	} else {
+		worker.pending = append(worker.pending, buffer.Bytes()...)
+	}
+	if ErrOutputOverflow == err {
+		logger.LogError("Push to", worker.conn.RemoteAddr(), "fail:", err)
+		worker.output.Abort()
+		worker.conn.Close()
+	}
+}
+
+// setBusy : Mark whether a command is being processed, buffered replies and
+// pending responses are queued when it is not
+func (worker *Worker) setBusy(busy bool) {
+	if !busy {
+		worker.Flush()
+		return
+	}
+	worker.writeMutex.Lock()
+	defer worker.writeMutex.Unlock()
+	worker.busy = true
+}
+
+// writePending : Write pending pushed responses, must be called under write
+// lock
+func (worker *Worker) writePending() error {
+	if 0 == len(worker.pending) {
+		return nil
+	}
+	_, err := worker.writer.Write(worker.pending)
+	worker.pending = nil
+	return err
+}
+
+// flush : Queue buffered responses, must be called under write lock
+func (worker *Worker) flush() error {
+	if 0 == worker.writer.Buffered() {
+		return nil
+	}
+	return worker.writer.Flush()
+}
+
 // ReadLine : Readline of stream from socket
 func (worker *Worker) ReadLine() (content string, err error) {
-	if worker.scanner.Scan() {
-		content = worker.scanner.Text()
-		worker.readBytes = worker.readBytes + len([]byte(content))
-	} else {
-		err = worker.scanner.Err()
-		if nil == err {
-			err = errors.New("EOF")
+	buffered, _ := worker.reader.Peek(worker.reader.Buffered())
+	if bytes.IndexByte(buffered, '\n') < 0 {
+		// Input drained, client is waiting for responses
+		err = worker.Flush()
+		if nil != err {
+			return
 		}
 	}
+
+	content, err = worker.reader.ReadString('\n')
+	worker.readBytes = worker.readBytes + len(content)
+	if nil != err {
+		return "", err
+	}
+	content = strings.TrimSuffix(content[:len(content)-1], "\r")
 	return
 }
 
+// ReadFull : Read exactly n bytes of stream from socket
+func (worker *Worker) ReadFull(n int) ([]byte, error) {
+	if worker.reader.Buffered() < n {
+		err := worker.Flush()
+		if nil != err {
+			return nil, err
+		}
+	}
+
+	buffer := make([]byte, n)
+	readLen, err := io.ReadFull(worker.reader, buffer)
+	worker.readBytes = worker.readBytes + readLen
+	if nil != err {
+		return nil, err
+	}
+	return buffer, nil
+}
+
 // NeedAuth : is worker need auth
 func (worker *Worker) NeedAuth() bool {
-	return worker.needAuth && "" != worker.passwd
+	return !worker.session.IsAuthenticated()
+}
+
+// GetSession : get session of connection
+func (worker *Worker) GetSession() *processor.Session {
+	return worker.session
 }
 
 // GetReadLen : get read byte length
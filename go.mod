@@ -2,7 +2,4 @@ module gredissimulate
 
 go 1.13
 
-require (
-	github.com/MagicYH/rdb v0.0.0-20200929172128-c64e68c3d290
-	gopkg.in/yaml.v2 v2.3.0
-)
+require gopkg.in/yaml.v2 v2.3.0
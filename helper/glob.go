@@ -0,0 +1,105 @@
+package helper
+
+// GlobMatch : Check if str matches glob-style pattern, same as stringmatch
+// of redis, supports `*`, `?`, `[abc]`, `[^abc]`, `[a-z]` and `\` escape
+func GlobMatch(pattern string, str string, nocase bool) bool {
+	return globMatch([]byte(pattern), []byte(str), nocase)
+}
+
+func globMatch(pattern []byte, str []byte, nocase bool) bool {
+	for (len(pattern) > 0) && (len(str) > 0) {
+		switch pattern[0] {
+		case '*':
+			for (len(pattern) > 1) && ('*' == pattern[1]) {
+				pattern = pattern[1:]
+			}
+			if 1 == len(pattern) {
+				return true
+			}
+			for len(str) > 0 {
+				if globMatch(pattern[1:], str, nocase) {
+					return true
+				}
+				str = str[1:]
+			}
+			return false
+		case '?':
+			str = str[1:]
+		case '[':
+			pattern = pattern[1:]
+			not := (len(pattern) > 0) && ('^' == pattern[0])
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for {
+				if 0 == len(pattern) {
+					break
+				} else if ('\\' == pattern[0]) && (len(pattern) >= 2) {
+					pattern = pattern[1:]
+					if equalByte(pattern[0], str[0], nocase) {
+						match = true
+					}
+				} else if ']' == pattern[0] {
+					break
+				} else if (len(pattern) >= 3) && ('-' == pattern[1]) {
+					start, end, c := pattern[0], pattern[2], str[0]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLower(start), toLower(end), toLower(c)
+					}
+					pattern = pattern[2:]
+					if (c >= start) && (c <= end) {
+						match = true
+					}
+				} else if equalByte(pattern[0], str[0], nocase) {
+					match = true
+				}
+				pattern = pattern[1:]
+			}
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			str = str[1:]
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if !equalByte(pattern[0], str[0], nocase) {
+				return false
+			}
+			str = str[1:]
+		}
+		if len(pattern) > 0 {
+			pattern = pattern[1:]
+		}
+		if 0 == len(str) {
+			for (len(pattern) > 0) && ('*' == pattern[0]) {
+				pattern = pattern[1:]
+			}
+			break
+		}
+	}
+	return (0 == len(pattern)) && (0 == len(str))
+}
+
+func equalByte(a byte, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
+
+func toLower(c byte) byte {
+	if (c >= 'A') && (c <= 'Z') {
+		return c + ('a' - 'A')
+	}
+	return c
+}
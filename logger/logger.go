@@ -3,7 +3,6 @@ package logger
 import (
 	"context"
 	"errors"
-	"gredissimulate/config"
 	"gredissimulate/helper"
 	"log"
 	"os"
@@ -33,7 +32,7 @@ type Log struct {
 
 // LogConf : Config infomation of logger
 type LogConf struct {
-	LogPath   string // Log path
+	LogPath   string // Log path, log/run.log under directory of executable if empty
 	CacheSize int    // Channel cache size
 }
 
@@ -50,7 +49,10 @@ func NewServer(conf LogConf) (*Log, error) {
 
 	logPath := conf.LogPath
 	if "" == logPath {
-		logPath = config.GetAppPath() + "/log/run.log"
+		// The config package is not imported so that packages logging
+		// through logger can be loaded without config file, such as in tests
+		appPath, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+		logPath = appPath + "/log/run.log"
 	}
 
 	logDir := filepath.Dir(logPath)
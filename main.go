@@ -4,9 +4,11 @@ import (
 	"context"
 	"runtime"
 	"sync"
+	"time"
 
 	"gredissimulate/config"
 	"gredissimulate/core"
+	"gredissimulate/core/clock"
 	"gredissimulate/core/processor"
 	"gredissimulate/logger"
 )
@@ -19,9 +21,16 @@ func main() {
 
 	// Create a new server with Simple command processor
 	serverConf := core.ServerConf{
-		Port:    config.GetListenPort(),
-		Passwd:  config.GetPasswd(),
-		SlaveOf: config.GetSlave(),
+		Port:        config.GetListenPort(),
+		Passwd:      config.GetPasswd(),
+		SlaveOf:     config.GetSlave(),
+		Databases:   config.GetDatabases(),
+		EnableDebug: config.GetEnableDebug(),
+
+		NotifyKeyspaceEvents: config.GetNotifyKeyspaceEvents(),
+	}
+	if config.GetFakeClock() {
+		serverConf.Clock = clock.NewFakeClock(time.Now())
 	}
 	server, err := core.NewServer(serverConf, processor.NewSimpleProc)
 	if nil != err {
@@ -29,17 +38,16 @@ func main() {
 	}
 
 	var wg sync.WaitGroup
+	wg.Add(2)
 
 	// Start logger service
 	go func() {
-		wg.Add(1)
 		log.Start(ctx)
 		wg.Done()
 	}()
 
 	// Start redis service
 	go func() {
-		wg.Add(1)
 		server.Start(ctx)
 		wg.Done()
 	}()